@@ -14,6 +14,9 @@ import (
 	"altpocket/internal/fetcher"
 	"altpocket/internal/logger"
 	"altpocket/internal/store"
+	"altpocket/internal/subscription"
+	"altpocket/internal/urlnorm"
+	"altpocket/internal/webhook"
 	"log/slog"
 )
 
@@ -34,7 +37,19 @@ func main() {
 	if fullLimit < 100 {
 		fullLimit = 100
 	}
+	guard, err := fetcher.NewGuard(cfg.FetchAllowlist)
+	if err != nil {
+		log.Error("fetch_allowlist_invalid", "error", err)
+		os.Exit(1)
+	}
 	f := fetcher.New(1_000_000, fullLimit, cfg.ContentSearchLimit)
+	f.Client.Transport = guard.Transport()
+	poller := &subscription.Poller{Client: f.Client, MaxBytes: 5_000_000}
+	sender := webhook.NewSender(f.Client.Transport)
+	pollInterval := time.Duration(cfg.SubscriptionPollMinutes) * time.Minute
+	if pollInterval < 5*time.Minute {
+		pollInterval = 5 * time.Minute
+	}
 
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -46,7 +61,14 @@ func main() {
 		select {
 		case <-ticker.C:
 			cleanupSessions(ctx, st, log)
+			cleanupOAuthRequests(ctx, st, log)
+			cleanupExtensionTokens(ctx, st, log)
+			purgeTrash(ctx, st, cfg.TrashRetentionDays, log)
+			purgeWebhookDeliveries(ctx, st, log)
+			runImports(ctx, st, log)
+			runSubscriptions(ctx, st, poller, pollInterval, log)
 			runOnce(ctx, st, f, log)
+			runWebhooks(ctx, st, sender, log)
 		case <-done:
 			log.Info("worker_shutdown")
 			return
@@ -65,6 +87,160 @@ func cleanupSessions(ctx context.Context, st *store.Store, log *slog.Logger) {
 	}
 }
 
+func cleanupOAuthRequests(ctx context.Context, st *store.Store, log *slog.Logger) {
+	removed, err := st.CleanupExpiredOAuthRequests(ctx)
+	if err != nil {
+		log.Error("oauth_request_cleanup_failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		log.Info("oauth_request_cleanup", "removed", removed)
+	}
+}
+
+func cleanupExtensionTokens(ctx context.Context, st *store.Store, log *slog.Logger) {
+	removed, err := st.CleanupExtensionTokens(ctx)
+	if err != nil {
+		log.Error("extension_token_cleanup_failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		log.Info("extension_token_cleanup", "removed", removed)
+	}
+}
+
+func purgeTrash(ctx context.Context, st *store.Store, retentionDays int, log *slog.Logger) {
+	if retentionDays <= 0 {
+		return
+	}
+	removed, err := st.PurgeTrash(ctx, time.Duration(retentionDays)*24*time.Hour)
+	if err != nil {
+		log.Error("trash_purge_failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		log.Info("trash_purge", "removed", removed)
+	}
+}
+
+// webhookLogRetention is how long finished deliveries stay in the log.
+const webhookLogRetention = 14 * 24 * time.Hour
+
+func purgeWebhookDeliveries(ctx context.Context, st *store.Store, log *slog.Logger) {
+	removed, err := st.PurgeWebhookDeliveries(ctx, webhookLogRetention)
+	if err != nil {
+		log.Error("webhook_purge_failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		log.Info("webhook_purge", "removed", removed)
+	}
+}
+
+// importBatchSize caps how many rows of one job are imported per tick; every user's
+// oldest running job gets a batch each tick.
+const importBatchSize = 500
+
+func runImports(ctx context.Context, st *store.Store, log *slog.Logger) {
+	jobs, err := st.ListRunnableImportJobs(ctx)
+	if err != nil {
+		log.Error("import_list_failed", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		updated, err := st.RunImportBatch(ctx, job.ID, importBatchSize)
+		if err != nil {
+			// The batch rolled back; it is retried on the next tick.
+			log.Error("import_batch_failed", "job_id", job.ID, "error", err)
+			continue
+		}
+		if updated.Status == store.ImportStatusDone {
+			log.Info("import_done", "job_id", job.ID, "created", updated.CreatedCount, "duplicates", updated.DuplicateCount, "invalid", updated.InvalidCount)
+		}
+	}
+}
+
+// subscriptionBatchSize caps how many due feeds are polled per tick; the rest stay due
+// for the next one.
+const subscriptionBatchSize = 50
+
+func runSubscriptions(ctx context.Context, st *store.Store, p *subscription.Poller, interval time.Duration, log *slog.Logger) {
+	subs, err := st.ClaimDueSubscriptions(ctx, subscriptionBatchSize, 10*time.Minute)
+	if err != nil {
+		log.Error("subscription_claim_failed", "error", err)
+		return
+	}
+
+	sem := make(chan struct{}, 5)
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		sub := sub
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pollSubscription(ctx, st, p, sub, interval, log)
+		}()
+	}
+	wg.Wait()
+}
+
+func pollSubscription(ctx context.Context, st *store.Store, p *subscription.Poller, sub store.Subscription, interval time.Duration, log *slog.Logger) {
+	ctxPoll, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	fail := func(reason string) {
+		_ = st.UpdateSubscriptionFailure(ctx, sub.ID, reason, subscription.NextPoll(time.Now(), interval, sub.ErrorCount+1))
+		log.Info("subscription_poll_failed", "subscription_id", sub.ID, "reason", reason)
+	}
+
+	res, err := p.Poll(ctxPoll, sub.FeedURL, sub.ETag, sub.LastModified)
+	if err != nil {
+		fail(classifyPollError(err))
+		return
+	}
+	poll := store.SubscriptionPoll{ETag: res.ETag, LastModified: res.LastModified}
+	if !res.NotModified {
+		poll.Title, poll.SiteURL = res.Feed.Title, res.Feed.SiteURL
+		poll.SeenEntryIDs = subscription.SeenIDs(res.Feed, sub.SeenEntryIDs)
+		// The first successful poll only records what the feed already lists, so that
+		// following a feed does not import its whole backlog.
+		if sub.LastSuccessAt != nil {
+			for _, e := range subscription.NewEntries(res.Feed, sub.SeenEntryIDs) {
+				created, err := saveEntry(ctx, st, sub, e)
+				if err != nil {
+					// Seen entries are not advanced, so the rest are retried next poll.
+					log.Error("subscription_save_failed", "subscription_id", sub.ID, "error", err)
+					fail("save_failed")
+					return
+				}
+				if created {
+					poll.Saved++
+				}
+			}
+		}
+	}
+	if err := st.UpdateSubscriptionSuccess(ctx, sub.ID, poll, time.Now().Add(interval)); err != nil {
+		log.Error("subscription_update_failed", "subscription_id", sub.ID, "error", err)
+		return
+	}
+	if poll.Saved > 0 {
+		log.Info("subscription_saved", "subscription_id", sub.ID, "created", poll.Saved)
+	}
+}
+
+// saveEntry creates the item the way the API does: the URL is canonicalized so that an
+// entry already saved by hand is not duplicated. Entries with unusable URLs are skipped.
+func saveEntry(ctx context.Context, st *store.Store, sub store.Subscription, e subscription.Entry) (bool, error) {
+	canonicalURL, canonicalHash, err := urlnorm.Canonicalize(e.URL)
+	if err != nil {
+		return false, nil
+	}
+	_, created, err := st.CreateItem(ctx, sub.UserID, "", e.URL, canonicalURL, canonicalHash, sub.Tags)
+	return created, err
+}
+
 func runOnce(ctx context.Context, st *store.Store, f *fetcher.Fetcher, log *slog.Logger) {
 	items, err := st.ClaimItemsForFetch(ctx, 50)
 	if err != nil {
@@ -95,7 +271,8 @@ func runOnce(ctx context.Context, st *store.Store, f *fetcher.Fetcher, log *slog
 				log.Info("worker_fetch_failed", "item_id", it.ID, "reason", reason)
 				return
 			}
-			err = st.UpdateFetchSuccess(ctx, it.ID, res.Title, res.Excerpt, res.ContentFull, res.ContentSearch, res.ContentBytes)
+			meta := store.ItemMetadata{SiteName: res.SiteName, Author: res.Author, PublishedAt: res.PublishedAt, ImageURL: res.ImageURL, Language: res.Language}
+			err = st.UpdateFetchSuccess(ctx, it.ID, res.Title, res.Excerpt, res.ContentFull, res.ContentHTML, res.ContentSearch, res.ContentBytes, res.Charset, meta)
 			if err != nil {
 				log.Error("worker_db_update_failed", "item_id", it.ID, "error", err)
 				return
@@ -103,12 +280,70 @@ func runOnce(ctx context.Context, st *store.Store, f *fetcher.Fetcher, log *slog
 			if it.RefetchRequested {
 				log.Info("refetch_consumed", "item_id", it.ID)
 			}
-			log.Info("worker_fetch_success", "item_id", it.ID)
+			log.Info("worker_fetch_success", "item_id", it.ID, "charset", res.Charset)
+		}()
+	}
+	wg.Wait()
+}
+
+// webhookBatchSize caps the deliveries sent per tick.
+const webhookBatchSize = 200
+
+func runWebhooks(ctx context.Context, st *store.Store, sender *webhook.Sender, log *slog.Logger) {
+	deliveries, err := st.ClaimWebhookDeliveries(ctx, webhookBatchSize, 5*time.Minute)
+	if err != nil {
+		log.Error("webhook_claim_failed", "error", err)
+		return
+	}
+
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		d := d
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			status, err := sender.Send(ctx, d.WebhookURL, d.Secret, d.Event, d.ID, d.Payload, time.Now())
+			if err == nil {
+				if err := st.MarkWebhookDelivered(ctx, d.ID, status); err != nil {
+					log.Error("webhook_update_failed", "delivery_id", d.ID, "error", err)
+				}
+				return
+			}
+			attempts := d.Attempts + 1
+			var retryAt *time.Time
+			if attempts < webhook.MaxAttempts {
+				next := time.Now().Add(webhook.Backoff(attempts))
+				retryAt = &next
+			}
+			reason := classifyWebhookError(err)
+			if err := st.MarkWebhookFailed(ctx, d.ID, status, reason, retryAt); err != nil {
+				log.Error("webhook_update_failed", "delivery_id", d.ID, "error", err)
+				return
+			}
+			log.Info("webhook_delivery_failed", "delivery_id", d.ID, "webhook_id", d.WebhookID, "attempts", attempts, "reason", reason, "gave_up", retryAt == nil)
 		}()
 	}
 	wg.Wait()
 }
 
+func classifyWebhookError(err error) string {
+	if errors.Is(err, webhook.ErrBadStatus) {
+		return "bad_status"
+	}
+	return classifyFetchError(err)
+}
+
+func classifyPollError(err error) string {
+	if errors.Is(err, subscription.ErrNotFeed) {
+		return "not_a_feed"
+	}
+	return classifyFetchError(err)
+}
+
 func classifyFetchError(err error) string {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return "timeout"
@@ -119,6 +354,9 @@ func classifyFetchError(err error) string {
 	if errors.Is(err, fetcher.ErrTooManyRedir) {
 		return "redirect_limit"
 	}
+	if errors.Is(err, fetcher.ErrBlockedDestination) {
+		return "blocked_destination"
+	}
 	if errors.Is(err, fetcher.ErrBadStatus) {
 		return "bad_status"
 	}
@@ -11,6 +11,8 @@ const suggestionsEl = document.getElementById('suggestions');
 
 let tags = [];
 let token = null;
+let refreshToken = null;
+let refreshing = null;
 
 function setStatus(msg, level = 'info') {
   const classes = {
@@ -105,6 +107,70 @@ function addTag(value) {
   renderTags();
 }
 
+function deviceName() {
+  const platform = globalThis.navigator && globalThis.navigator.userAgentData
+    ? globalThis.navigator.userAgentData.platform
+    : '';
+  return platform ? `altpocket extension on ${platform}` : 'altpocket extension';
+}
+
+async function storeTokens(data, extra = {}) {
+  token = data.token;
+  refreshToken = typeof data.refresh_token === 'string' && data.refresh_token ? data.refresh_token : null;
+  await chrome.storage.local.set({ ...extra, token, refreshToken });
+}
+
+async function clearTokens() {
+  token = null;
+  refreshToken = null;
+  await chrome.storage.local.set({ token: null, refreshToken: null });
+}
+
+// refreshTokens trades the refresh token for new tokens. Concurrent callers share one
+// request, since the server signs the extension out when a refresh token is reused.
+function refreshTokens(apiBase) {
+  if (!refreshing) {
+    refreshing = (async () => {
+      try {
+        const res = await fetch(`${apiBase}/v1/auth/extension/refresh`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        const { data } = await readResponseBody(res);
+        if (!res.ok || !data || typeof data.token !== 'string' || data.token === '') {
+          if (res.status === 400 || res.status === 401) {
+            await clearTokens();
+          }
+          return false;
+        }
+        await storeTokens(data);
+        return true;
+      } finally {
+        refreshing = null;
+      }
+    })();
+  }
+  return refreshing;
+}
+
+// apiFetch sends an authorized request and, when the access token has expired, refreshes
+// it once and retries.
+async function apiFetch(apiBase, path, options = {}) {
+  const send = () => fetch(`${apiBase}${path}`, {
+    ...options,
+    headers: { ...(options.headers || {}), 'Authorization': `Bearer ${token}` },
+  });
+  const res = await send();
+  if (res.status !== 401 || !refreshToken) {
+    return res;
+  }
+  if (!(await refreshTokens(apiBase))) {
+    return res;
+  }
+  return send();
+}
+
 function parseFragment(fragment) {
   if (!fragment) return '';
   const params = new URLSearchParams(fragment.replace(/^#/, ''));
@@ -151,7 +217,7 @@ async function login() {
       res = await fetch(`${apiBase}/v1/auth/extension/exchange`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ id_token: idToken }),
+        body: JSON.stringify({ id_token: idToken, device_name: deviceName() }),
       });
     } catch (err) {
       setError(`Exchange request failed: ${errorMessage(err, 'network error')}`);
@@ -168,8 +234,7 @@ async function login() {
       return;
     }
 
-    token = data.token;
-    await chrome.storage.local.set({ apiBase, token });
+    await storeTokens(data, { apiBase });
     setSuccess('Logged in');
   } catch (err) {
     setError(`Login error: ${errorMessage(err, 'unexpected error')}`);
@@ -193,12 +258,9 @@ async function saveCurrentTab() {
 
     let res;
     try {
-      res = await fetch(`${apiBase}/v1/items`, {
+      res = await apiFetch(apiBase, '/v1/items', {
         method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-          'Authorization': `Bearer ${token}`,
-        },
+        headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ url: tab.url, tags }),
       });
     } catch (err) {
@@ -207,6 +269,10 @@ async function saveCurrentTab() {
     }
 
     if (!res.ok) {
+      if (res.status === 401 && !token) {
+        setError('Session expired. Log in again');
+        return;
+      }
       const { data } = await readResponseBody(res);
       setError(apiErrorMessage(res.status, data, 'Save failed'));
       return;
@@ -259,9 +325,7 @@ tagInput.addEventListener('input', async () => {
     return;
   }
   try {
-    const res = await fetch(`${apiBase}/v1/tags?q=${encodeURIComponent(q)}`, {
-      headers: { 'Authorization': `Bearer ${token}` },
-    });
+    const res = await apiFetch(apiBase, `/v1/tags?q=${encodeURIComponent(q)}`);
     if (!res.ok) {
       suggestionsEl.innerHTML = '';
       return;
@@ -282,10 +346,11 @@ tagInput.addEventListener('blur', () => {
 
 (async () => {
   try {
-    const data = await chrome.storage.local.get(['apiBase', 'token']);
+    const data = await chrome.storage.local.get(['apiBase', 'token', 'refreshToken']);
     if (data.apiBase) apiBaseInput.value = normalizeAPIBase(data.apiBase);
     if (data.token) {
       token = data.token;
+      refreshToken = data.refreshToken || null;
       setSuccess('Ready');
       return;
     }
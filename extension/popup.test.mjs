@@ -220,7 +220,7 @@ test('login requires API base URL', async () => {
 
 test('login exchanges id token and stores API token', async () => {
   const env = await loadPopupScript({
-    fetchHandlers: [jsonResponse(200, { token: 'jwt-token' })],
+    fetchHandlers: [jsonResponse(200, { token: 'jwt-token', refresh_token: 'refresh-1' })],
   });
 
   env.elements.apiBase.value = 'https://api.example.test';
@@ -239,6 +239,7 @@ test('login exchanges id token and stores API token', async () => {
   assert.equal(env.storageSetCalls[0].apiBase, 'https://api.example.test');
   assert.equal(env.storageSetCalls[0].token, 'jwt-token');
   assert.equal(env.storageData.token, 'jwt-token');
+  assert.equal(env.storageData.refreshToken, 'refresh-1');
 });
 
 test('save requires login token', async () => {
@@ -347,3 +348,50 @@ test('tag suggestions fetch and click-to-add work', async () => {
   assert.equal(env.elements.tags.children[0].textContent, 'go');
   assert.equal(env.elements.tagInput.value, '');
 });
+
+test('save refreshes an expired token once and retries', async () => {
+  const env = await loadPopupScript({
+    storageData: {
+      apiBase: 'https://api.example.test',
+      token: 'expired-token',
+      refreshToken: 'refresh-1',
+    },
+    fetchHandlers: [
+      jsonResponse(401, { error: 'unauthorized' }),
+      jsonResponse(200, { token: 'fresh-token', refresh_token: 'refresh-2' }),
+      jsonResponse(200, {}),
+    ],
+  });
+
+  await env.elements.save.click();
+
+  assert.equal(env.fetchCalls.length, 3);
+  assert.equal(env.fetchCalls[1].url, 'https://api.example.test/v1/auth/extension/refresh');
+  assert.equal(JSON.parse(env.fetchCalls[1].options.body).refresh_token, 'refresh-1');
+  assert.equal(env.fetchCalls[2].options.headers.Authorization, 'Bearer fresh-token');
+  assert.equal(env.storageData.token, 'fresh-token');
+  assert.equal(env.storageData.refreshToken, 'refresh-2');
+  assert.equal(env.elements.status.textContent, 'Saved');
+});
+
+test('save asks to log in again when the refresh token is rejected', async () => {
+  const env = await loadPopupScript({
+    storageData: {
+      apiBase: 'https://api.example.test',
+      token: 'expired-token',
+      refreshToken: 'revoked',
+    },
+    fetchHandlers: [
+      jsonResponse(401, { error: 'unauthorized' }),
+      jsonResponse(401, { error: 'invalid_grant' }),
+    ],
+  });
+
+  await env.elements.save.click();
+
+  assert.equal(env.fetchCalls.length, 2);
+  assert.equal(env.storageData.token, null);
+  assert.equal(env.storageData.refreshToken, null);
+  assert.equal(env.elements.status.className, 'status status-error');
+  assert.equal(env.elements.status.textContent, 'Session expired. Log in again');
+});
@@ -3,7 +3,9 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -18,6 +20,27 @@ type User struct {
 	AvatarURL string
 }
 
+// Scopes limit what a credential may do. Each scope includes the ones before it: read
+// allows GET requests, write allows changes to items and lists, and admin also allows
+// managing tokens and webhooks. Sessions and extension tokens carry admin.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+func ValidScope(scope string) bool {
+	return scopeRank[scope] > 0
+}
+
+// ScopeAllows reports whether a credential granted scope may perform an action that
+// requires required.
+func ScopeAllows(granted, required string) bool {
+	return ValidScope(granted) && scopeRank[granted] >= scopeRank[required]
+}
+
 var userKey = &struct{}{}
 
 func ContextWithUser(ctx context.Context, user User) context.Context {
@@ -42,32 +65,67 @@ func RandomString(n int) (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func IssueJWT(secret, userID string, ttl time.Duration) (string, int64, error) {
+// HashToken returns the hex SHA-256 digest under which long-lived secrets are stored.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Claims are the fields of an access token. ID is the jti under which the token can be
+// denylisted; DeviceID names the extension install the token was issued to.
+type Claims struct {
+	ID        string
+	UserID    string
+	DeviceID  string
+	ExpiresAt time.Time
+}
+
+// IssueJWT signs an HS256 access token for userID with a random jti.
+func IssueJWT(secret, userID, deviceID string, ttl time.Duration) (string, Claims, error) {
+	jti, err := RandomString(16)
+	if err != nil {
+		return "", Claims{}, err
+	}
 	now := time.Now()
 	exp := now.Add(ttl)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	mc := jwt.MapClaims{
+		"jti": jti,
 		"sub": userID,
 		"iat": now.Unix(),
 		"exp": exp.Unix(),
-	})
-	s, err := token.SignedString([]byte(secret))
+	}
+	if deviceID != "" {
+		mc["did"] = deviceID
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mc).SignedString([]byte(secret))
 	if err != nil {
-		return "", 0, err
+		return "", Claims{}, err
 	}
-	return s, exp.Unix(), nil
+	return s, Claims{ID: jti, UserID: userID, DeviceID: deviceID, ExpiresAt: time.Unix(exp.Unix(), 0)}, nil
 }
 
-func ParseJWT(secret, tokenString string) (string, error) {
+// ParseJWT verifies an access token. Tokens without a jti cannot be revoked and are
+// rejected.
+func ParseJWT(secret, tokenString string) (Claims, error) {
 	claims := jwt.MapClaims{}
 	parsed, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secret), nil
 	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil || !parsed.Valid {
-		return "", errors.New("invalid token")
+		return Claims{}, errors.New("invalid token")
 	}
 	sub, _ := claims["sub"].(string)
 	if sub == "" {
-		return "", errors.New("missing sub")
+		return Claims{}, errors.New("missing sub")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return Claims{}, errors.New("missing jti")
+	}
+	did, _ := claims["did"].(string)
+	c := Claims{ID: jti, UserID: sub, DeviceID: did}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		c.ExpiresAt = exp.Time
 	}
-	return sub, nil
+	return c, nil
 }
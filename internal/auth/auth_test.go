@@ -12,22 +12,53 @@ func TestIssueAndParseJWTRoundTrip(t *testing.T) {
 	secret := "test-secret"
 	userID := "user-123"
 
-	token, _, err := IssueJWT(secret, userID, 5*time.Minute)
+	token, issued, err := IssueJWT(secret, userID, "device-1", 5*time.Minute)
 	if err != nil {
 		t.Fatalf("IssueJWT failed: %v", err)
 	}
 
-	gotUserID, err := ParseJWT(secret, token)
+	claims, err := ParseJWT(secret, token)
 	if err != nil {
 		t.Fatalf("ParseJWT failed: %v", err)
 	}
-	if gotUserID != userID {
-		t.Fatalf("expected user_id %q, got %q", userID, gotUserID)
+	if claims.UserID != userID {
+		t.Fatalf("expected user_id %q, got %q", userID, claims.UserID)
+	}
+	if claims.DeviceID != "device-1" || claims.ID == "" || claims.ID != issued.ID {
+		t.Fatalf("unexpected claims %+v, issued %+v", claims, issued)
+	}
+	if !claims.ExpiresAt.Equal(issued.ExpiresAt) {
+		t.Fatalf("expected expiry %v, got %v", issued.ExpiresAt, claims.ExpiresAt)
+	}
+
+	_, other, err := IssueJWT(secret, userID, "device-1", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+	if other.ID == issued.ID {
+		t.Fatalf("expected a fresh jti per token")
+	}
+}
+
+func TestParseJWTRejectsMissingJTI(t *testing.T) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-123",
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	})
+	tokenString, err := token.SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("sign token failed: %v", err)
+	}
+
+	if _, err := ParseJWT("test-secret", tokenString); err == nil || !strings.Contains(err.Error(), "missing jti") {
+		t.Fatalf("expected missing jti error, got: %v", err)
 	}
 }
 
 func TestParseJWTRejectsWrongSecret(t *testing.T) {
-	token, _, err := IssueJWT("secret-a", "user-123", 5*time.Minute)
+	token, _, err := IssueJWT("secret-a", "user-123", "", 5*time.Minute)
 	if err != nil {
 		t.Fatalf("IssueJWT failed: %v", err)
 	}
@@ -38,7 +69,7 @@ func TestParseJWTRejectsWrongSecret(t *testing.T) {
 }
 
 func TestParseJWTRejectsTamperedToken(t *testing.T) {
-	token, _, err := IssueJWT("test-secret", "user-123", 5*time.Minute)
+	token, _, err := IssueJWT("test-secret", "user-123", "", 5*time.Minute)
 	if err != nil {
 		t.Fatalf("IssueJWT failed: %v", err)
 	}
@@ -50,7 +81,7 @@ func TestParseJWTRejectsTamperedToken(t *testing.T) {
 }
 
 func TestParseJWTRejectsExpiredToken(t *testing.T) {
-	token, _, err := IssueJWT("test-secret", "user-123", -1*time.Minute)
+	token, _, err := IssueJWT("test-secret", "user-123", "", -1*time.Minute)
 	if err != nil {
 		t.Fatalf("IssueJWT failed: %v", err)
 	}
@@ -109,3 +140,36 @@ func TestParseJWTRejectsNoneAlgorithm(t *testing.T) {
 		t.Fatalf("expected ParseJWT to reject none-algorithm token")
 	}
 }
+
+func TestHashTokenIsStableAndHex(t *testing.T) {
+	a := HashToken("secret-token")
+	if a != HashToken("secret-token") {
+		t.Fatalf("expected stable hash")
+	}
+	if len(a) != 64 || strings.Trim(a, "0123456789abcdef") != "" {
+		t.Fatalf("expected hex sha256 digest, got %q", a)
+	}
+	if a == HashToken("other-token") {
+		t.Fatalf("expected different tokens to hash differently")
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	cases := []struct {
+		granted, required string
+		want              bool
+	}{
+		{ScopeAdmin, ScopeWrite, true},
+		{ScopeWrite, ScopeWrite, true},
+		{ScopeWrite, ScopeAdmin, false},
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeWrite, false},
+		{"", ScopeRead, false},
+		{"owner", ScopeRead, false},
+	}
+	for _, tc := range cases {
+		if got := ScopeAllows(tc.granted, tc.required); got != tc.want {
+			t.Fatalf("ScopeAllows(%q, %q) = %v, want %v", tc.granted, tc.required, got, tc.want)
+		}
+	}
+}
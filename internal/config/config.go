@@ -18,6 +18,9 @@ type Config struct {
 	PublicBaseURL     string
 	ContentFullLimit  int
 	ContentSearchLimit int
+	TrashRetentionDays int
+	SubscriptionPollMinutes int
+	FetchAllowlist string
 }
 
 func Load() Config {
@@ -33,6 +36,9 @@ func Load() Config {
 		PublicBaseURL:      mustEnv("PUBLIC_BASE_URL"),
 		ContentFullLimit:   getEnvInt("CONTENT_FULL_LIMIT_BYTES", 1_000_000),
 		ContentSearchLimit: getEnvInt("CONTENT_SEARCH_LIMIT_BYTES", 16_384),
+		TrashRetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
+		SubscriptionPollMinutes: getEnvInt("SUBSCRIPTION_POLL_MINUTES", 60),
+		FetchAllowlist: os.Getenv("FETCH_ALLOWLIST"),
 	}
 }
 
@@ -0,0 +1,157 @@
+package fetcher
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// metaScanBytes is how far into the document a <meta> charset declaration is looked for.
+// The HTML spec stops at 1024 bytes, but real pages often put it after long <script> blocks.
+const metaScanBytes = 4096
+
+// sniffBytes caps the sample decoded when guessing an undeclared encoding.
+const sniffBytes = 64 << 10
+
+// metaCharsetRe matches both <meta charset="..."> and the http-equiv form, whose
+// content attribute carries "text/html; charset=...".
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta\s[^>]*?charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`)
+
+// sniffCandidates are the legacy encodings tried, in order, when a page declares
+// nothing and is not UTF-8.
+var sniffCandidates = []encoding.Encoding{japanese.ShiftJIS, japanese.EUCJP}
+
+// decodeHTML returns body transcoded to UTF-8 and the name of the charset it was read as.
+// The charset is taken from the BOM, then the Content-Type header, then a <meta>
+// declaration, and otherwise guessed from the bytes. If decoding fails the body is
+// returned unchanged.
+func decodeHTML(body []byte, contentType string) ([]byte, string) {
+	enc, name, offset := detectCharset(body, contentType)
+	if name == "utf-8" {
+		return body[offset:], name
+	}
+	decoded, err := enc.NewDecoder().Bytes(body[offset:])
+	if err != nil {
+		return body, name
+	}
+	return decoded, name
+}
+
+func detectCharset(body []byte, contentType string) (encoding.Encoding, string, int) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8, "utf-8", 3
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "utf-16le", 2
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "utf-16be", 2
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, name, ok := lookupCharset(params["charset"]); ok {
+			return enc, name, 0
+		}
+	}
+	head := body
+	if len(head) > metaScanBytes {
+		head = head[:metaScanBytes]
+	}
+	if m := metaCharsetRe.FindSubmatch(head); m != nil {
+		if enc, name, ok := lookupCharset(string(m[1])); ok {
+			// A <meta> declaration can only be read because the page is ASCII-compatible,
+			// so a UTF-16 label there means UTF-8.
+			if strings.HasPrefix(name, "utf-16") {
+				return unicode.UTF8, "utf-8", 0
+			}
+			return enc, name, 0
+		}
+	}
+	enc, name := sniffCharset(body)
+	return enc, name, 0
+}
+
+func lookupCharset(label string) (encoding.Encoding, string, bool) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, "", false
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, "", false
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return nil, "", false
+	}
+	return enc, name, true
+}
+
+// sniffCharset guesses the encoding of an undeclared page: UTF-8 if the bytes are valid
+// UTF-8, ISO-2022-JP if they contain its escape sequences, otherwise the legacy Japanese
+// encoding whose decoding looks most like Japanese text, falling back to windows-1252.
+func sniffCharset(body []byte) (encoding.Encoding, string) {
+	sample := body
+	if len(sample) > sniffBytes {
+		sample = sample[:sniffBytes]
+	}
+	if bytes.Contains(sample, []byte("\x1b$B")) || bytes.Contains(sample, []byte("\x1b$@")) {
+		return japanese.ISO2022JP, "iso-2022-jp"
+	}
+	if validUTF8(sample) {
+		return unicode.UTF8, "utf-8"
+	}
+	var best encoding.Encoding
+	bestScore := 0
+	for _, enc := range sniffCandidates {
+		decoded, err := enc.NewDecoder().Bytes(sample)
+		if err != nil {
+			continue
+		}
+		if score := japaneseScore(decoded); score > bestScore {
+			best, bestScore = enc, score
+		}
+	}
+	if best == nil {
+		best, _ = htmlindex.Get("windows-1252")
+	}
+	name, _ := htmlindex.Name(best)
+	return best, name
+}
+
+// validUTF8 is utf8.Valid, except that a body cut at MaxBytes may end inside a character.
+func validUTF8(b []byte) bool {
+	if utf8.Valid(b) {
+		return true
+	}
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return !utf8.FullRune(b[i:]) && utf8.Valid(b[:i])
+		}
+	}
+	return false
+}
+
+// japaneseScore rewards kana and kanji and penalises replacement characters and
+// half-width katakana, which is what EUC-JP bytes look like when read as Shift_JIS.
+func japaneseScore(b []byte) int {
+	score := 0
+	for _, r := range string(b) {
+		switch {
+		case r == utf8.RuneError:
+			score -= 8
+		case r >= 0x3040 && r <= 0x30FF:
+			score += 2
+		case r >= 0x4E00 && r <= 0x9FFF, r >= 0x3000 && r <= 0x303F, r >= 0xFF01 && r <= 0xFF5E:
+			score++
+		case r >= 0xFF61 && r <= 0xFF9F:
+			score -= 2
+		}
+	}
+	return score
+}
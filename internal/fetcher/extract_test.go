@@ -0,0 +1,86 @@
+package fetcher
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "rewrite the testdata golden files")
+
+// TestExtractGolden runs the extractor over the saved pages in testdata and compares the
+// text with the .golden file next to each page. After an intended change, check the diff
+// and rerun with -update.
+func TestExtractGolden(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Fatal("no pages in testdata")
+	}
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := goquery.NewDocumentFromReader(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, _ := extractReadableContent(doc)
+			got := text + "\n"
+
+			golden := strings.TrimSuffix(page, ".html") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("extracted text differs from %s\n%s", golden, blockDiff(string(want), got))
+			}
+		})
+	}
+}
+
+// blockDiff lists the blocks missing from got and the ones it added.
+func blockDiff(want, got string) string {
+	wantBlocks := strings.Split(strings.TrimSpace(want), "\n\n")
+	gotBlocks := strings.Split(strings.TrimSpace(got), "\n\n")
+	inGot := map[string]bool{}
+	for _, b := range gotBlocks {
+		inGot[b] = true
+	}
+	inWant := map[string]bool{}
+	for _, b := range wantBlocks {
+		inWant[b] = true
+	}
+	var out strings.Builder
+	for _, b := range wantBlocks {
+		if !inGot[b] {
+			out.WriteString("- " + b + "\n")
+		}
+	}
+	for _, b := range gotBlocks {
+		if !inWant[b] {
+			out.WriteString("+ " + b + "\n")
+		}
+	}
+	if out.Len() == 0 {
+		return "(same blocks, different order or spacing)"
+	}
+	return out.String()
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"altpocket/internal/sanitize"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -25,6 +27,17 @@ type Result struct {
 	ContentFull   string
 	ContentSearch string
 	ContentBytes  int
+	// Charset is the encoding the page was decoded from, e.g. "shift_jis".
+	Charset string
+	// ContentHTML is the article as sanitized HTML for the reader view. It is empty when
+	// the article is too large to keep.
+	ContentHTML string
+	// Page metadata, empty when the page does not declare it.
+	SiteName    string
+	Author      string
+	PublishedAt *time.Time
+	ImageURL    string
+	Language    string
 }
 
 type Fetcher struct {
@@ -34,9 +47,13 @@ type Fetcher struct {
 	ContentSearchLimit int
 }
 
+// New returns a fetcher whose client only reaches public addresses. Use NewGuard and
+// Guard.Transport to allow internal hosts.
 func New(maxBytes int64, contentFullLimit, contentSearchLimit int) *Fetcher {
+	guard, _ := NewGuard("")
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Transport: guard.Transport(),
+		Timeout:   10 * time.Second,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return ErrTooManyRedir
@@ -72,23 +89,52 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) (Result, error) {
 		buf = buf[:f.MaxBytes]
 	}
 
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(buf))
+	body, charset := decodeHTML(buf, resp.Header.Get("Content-Type"))
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return Result{}, err
 	}
+	// Relative image URLs resolve against the page after redirects.
+	base := req.URL
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
 	title := strings.TrimSpace(doc.Find("title").First().Text())
-	contentText := extractReadableContent(doc)
+	meta := extractMetadata(doc, base)
+	if meta.Title != "" && poorTitle(title, meta.SiteName) {
+		title = meta.Title
+	}
+	contentText, article := extractReadableContent(doc)
 	contentFull := truncateUTF8(contentText, f.ContentFullLimit)
+	contentHTML := ""
+	if article != nil && article.Length() > 0 {
+		contentHTML = sanitize.Children(article.Get(0), base)
+	}
+	// Markup roughly doubles the text; an article far beyond that is dropped rather than
+	// cut, since truncated HTML would lose its tail anyway.
+	if len(contentHTML) > 2*f.ContentFullLimit {
+		contentHTML = ""
+	}
 	searchText := normalizeText(contentFull)
 	contentSearch := truncateUTF8(searchText, f.ContentSearchLimit)
 	excerpt := truncateUTF8(searchText, 200)
+	if meta.Description != "" {
+		excerpt = truncateUTF8(meta.Description, 200)
+	}
 
 	return Result{
 		Title:         title,
 		Excerpt:       excerpt,
 		ContentFull:   contentFull,
+		ContentHTML:   contentHTML,
 		ContentSearch: contentSearch,
 		ContentBytes:  len([]byte(contentFull)),
+		Charset:       charset,
+		SiteName:      meta.SiteName,
+		Author:        meta.Author,
+		PublishedAt:   meta.PublishedAt,
+		ImageURL:      meta.ImageURL,
+		Language:      meta.Language,
 	}, nil
 }
 
@@ -153,7 +199,22 @@ var contentSelectors = []string{
 	".markdown-body",
 }
 
-func extractReadableContent(doc *goquery.Document) string {
+// extractReadableContent returns the article text as blocks separated by blank lines,
+// and the element holding the article. It uses the scoring extractor and falls back to
+// the selector-based one when that finds no article. It modifies doc.
+func extractReadableContent(doc *goquery.Document) (string, *goquery.Selection) {
+	fallback := goquery.CloneDocument(doc)
+	if article := extractArticle(doc); article != nil {
+		if blocks := extractBlocks(article); len(blocks) > 0 {
+			return strings.Join(blocks, "\n\n"), article
+		}
+	}
+	return extractBySelectors(fallback)
+}
+
+// extractBySelectors prunes likely boilerplate and takes the longest of a list of
+// common content containers.
+func extractBySelectors(doc *goquery.Document) (string, *goquery.Selection) {
 	pruneNonContent(doc)
 	root := selectContentRoot(doc)
 	if root.Length() == 0 {
@@ -165,9 +226,9 @@ func extractReadableContent(doc *goquery.Document) string {
 
 	blocks := extractBlocks(root)
 	if len(blocks) == 0 {
-		return normalizeText(root.Text())
+		return normalizeText(root.Text()), root
 	}
-	return strings.Join(blocks, "\n\n")
+	return strings.Join(blocks, "\n\n"), root
 }
 
 func pruneNonContent(doc *goquery.Document) {
@@ -3,10 +3,18 @@ package fetcher
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestFetchSuccess(t *testing.T) {
@@ -151,6 +159,351 @@ func TestFetchTruncatesOversizedResponse(t *testing.T) {
 	}
 }
 
+func TestFetchDecodesLegacyCharsets(t *testing.T) {
+	const title = "日本語のタイトル"
+	const text = "これは日本語の記事本文です。文字化けせずに検索できることを確認します。"
+	page := func(head string) string {
+		return "<html><head>" + head + "<title>" + title + "</title></head><body><p>" + text + "</p></body></html>"
+	}
+	encode := func(enc encoding.Encoding, s string) []byte {
+		b, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatalf("encode fixture: %v", err)
+		}
+		return b
+	}
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		charset     string
+	}{
+		{"shift_jis header", "text/html; charset=Shift_JIS", encode(japanese.ShiftJIS, page("")), "shift_jis"},
+		{"euc-jp header", "text/html; charset=EUC-JP", encode(japanese.EUCJP, page("")), "euc-jp"},
+		{"shift_jis meta charset", "text/html", encode(japanese.ShiftJIS, page(`<meta charset="x-sjis">`)), "shift_jis"},
+		{"euc-jp meta http-equiv", "text/html", encode(japanese.EUCJP, page(`<meta http-equiv="Content-Type" content="text/html; charset=euc-jp">`)), "euc-jp"},
+		{"header wins over meta", "text/html; charset=euc-jp", encode(japanese.EUCJP, page(`<meta charset="shift_jis">`)), "euc-jp"},
+		{"shift_jis sniffed", "text/html", encode(japanese.ShiftJIS, page("")), "shift_jis"},
+		{"euc-jp sniffed", "", encode(japanese.EUCJP, page("")), "euc-jp"},
+		{"iso-2022-jp sniffed", "text/html", encode(japanese.ISO2022JP, page("")), "iso-2022-jp"},
+		{"utf-8 bom", "text/html; charset=shift_jis", append([]byte("\xef\xbb\xbf"), page("")...), "utf-8"},
+		{"utf-16le bom", "text/html", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), page("")), "utf-16le"},
+		{"utf-8 undeclared", "text/html", []byte(page("")), "utf-8"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewReader(tc.body)),
+						Header:     http.Header{"Content-Type": []string{tc.contentType}},
+					}, nil
+				}),
+			}
+			f := New(1_000_000, 4096, 1024)
+			f.Client = client
+			parsed, err := f.Fetch(context.Background(), "http://example.jp/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed.Charset != tc.charset {
+				t.Fatalf("charset = %q, want %q", parsed.Charset, tc.charset)
+			}
+			if parsed.Title != title {
+				t.Fatalf("title mismatch: %q", parsed.Title)
+			}
+			if !strings.Contains(parsed.ContentSearch, text) {
+				t.Fatalf("expected decoded text in content_search, got %q", parsed.ContentSearch)
+			}
+		})
+	}
+}
+
+func TestFetchDecodesWindows1252(t *testing.T) {
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte("<html><head><title>Caf\xe9</title></head><body><p>Cr\xe8me br\xfbl\xe9e</p></body></html>"))),
+				Header:     http.Header{"Content-Type": []string{"text/html; charset=iso-8859-1"}},
+			}, nil
+		}),
+	}
+	f := New(1_000_000, 1024, 512)
+	f.Client = client
+	parsed, err := f.Fetch(context.Background(), "http://example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// iso-8859-1 is an alias of windows-1252 in the HTML spec.
+	if parsed.Charset != "windows-1252" {
+		t.Fatalf("charset = %q", parsed.Charset)
+	}
+	if parsed.Title != "Café" || !strings.Contains(parsed.ContentFull, "Crème brûlée") {
+		t.Fatalf("unexpected decoding: %q / %q", parsed.Title, parsed.ContentFull)
+	}
+}
+
+func TestValidUTF8AllowsTruncatedTail(t *testing.T) {
+	b := []byte("日本語")
+	if !validUTF8(b[:len(b)-1]) {
+		t.Fatalf("expected a body cut inside the last character to count as UTF-8")
+	}
+	if validUTF8([]byte{'a', 0x82, 0xa0, 'b'}) {
+		t.Fatalf("expected Shift_JIS bytes to be rejected")
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":      true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"224.0.0.1":          false,
+		"255.255.255.255":    false,
+		"::1":                false,
+		"::":                 false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"::ffff:127.0.0.1":   false,
+		"64:ff9b::a9fe:a9fe": false,
+	}
+	for raw, want := range cases {
+		if got := publicAddr(netip.MustParseAddr(raw)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestFetchBlocksInternalDestinations(t *testing.T) {
+	f := New(1_000_000, 1024, 512)
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+		"http://localhost:8080/",
+		"http://example.com:5432/",
+		"ftp://example.com/",
+	} {
+		_, err := f.Fetch(context.Background(), target)
+		if !errors.Is(err, ErrBlockedDestination) {
+			t.Errorf("Fetch(%s): expected ErrBlockedDestination, got %v", target, err)
+		}
+	}
+}
+
+func TestFetchRechecksRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			_, _ = w.Write([]byte("<html><head><title>Allowed</title></head><body>hi</body></html>"))
+			return
+		}
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	guard, err := NewGuard(" 127.0.0.1/32 , wiki.internal")
+	if err != nil {
+		t.Fatalf("NewGuard: %v", err)
+	}
+	f := New(1_000_000, 1024, 512)
+	f.Client.Transport = guard.Transport()
+
+	parsed, err := f.Fetch(context.Background(), srv.URL+"/ok")
+	if err != nil {
+		t.Fatalf("expected allowlisted host to be fetched, got %v", err)
+	}
+	if parsed.Title != "Allowed" {
+		t.Fatalf("title mismatch: %s", parsed.Title)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/redirect"); !errors.Is(err, ErrBlockedDestination) {
+		t.Fatalf("expected redirect to be blocked, got %v", err)
+	}
+}
+
+func TestNewGuardRejectsInvalidRange(t *testing.T) {
+	if _, err := NewGuard("10.0.0.0/33"); err == nil {
+		t.Fatalf("expected an error for an invalid CIDR")
+	}
+}
+
+func TestFetchExtractsMetadata(t *testing.T) {
+	body := []byte(`<html lang="en_us"><head>
+<title>Home</title>
+<meta property="og:title" content="Deploying Go services">
+<meta property="og:description" content="A short guide to zero-downtime deploys.">
+<meta property="og:site_name" content="Example Engineering">
+<meta property="og:image" content="/img/lead.png">
+<meta property="article:author" content="https://example.com/authors/ann">
+<meta property="article:published_time" content="2024-03-05T09:30:00+09:00">
+<meta name="author" content="Fallback Author">
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+  {"@type":"WebSite","name":"Ignored"},
+  {"@type":["NewsArticle"],"author":[{"@type":"Person","name":"Ann Lee"},{"@type":"Person","name":"Bo Kim"}],"datePublished":"2020-01-01"}
+]}</script>
+</head><body><article><p>Deploys should not drop requests. This is the body of the article.</p></article></body></html>`)
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(body)),
+				Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+				Request:    req,
+			}, nil
+		}),
+	}
+	f := New(1_000_000, 4096, 1024)
+	f.Client = client
+	parsed, err := f.Fetch(context.Background(), "https://example.com/blog/deploys")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Title != "Deploying Go services" {
+		t.Fatalf("expected og:title to replace a generic title, got %q", parsed.Title)
+	}
+	if parsed.Excerpt != "A short guide to zero-downtime deploys." {
+		t.Fatalf("expected og:description as excerpt, got %q", parsed.Excerpt)
+	}
+	if parsed.SiteName != "Example Engineering" {
+		t.Fatalf("site name mismatch: %q", parsed.SiteName)
+	}
+	if parsed.Author != "Ann Lee, Bo Kim" {
+		t.Fatalf("expected JSON-LD authors over a profile URL, got %q", parsed.Author)
+	}
+	if parsed.PublishedAt == nil || !parsed.PublishedAt.Equal(time.Date(2024, 3, 5, 0, 30, 0, 0, time.UTC)) {
+		t.Fatalf("published_at mismatch: %v", parsed.PublishedAt)
+	}
+	if parsed.ImageURL != "https://example.com/img/lead.png" {
+		t.Fatalf("expected absolute image URL, got %q", parsed.ImageURL)
+	}
+	if parsed.Language != "en-US" {
+		t.Fatalf("language mismatch: %q", parsed.Language)
+	}
+}
+
+func TestFetchReadsJSONLDAndMetaFallbacks(t *testing.T) {
+	body := []byte(`<html><head>
+<title>Why trigram search works | Example</title>
+<meta property="og:title" content="Ignored because the title is fine">
+<meta name="description" content="Plain meta description.">
+<meta name="author" content="Meta Author">
+<meta property="og:locale" content="ja_JP">
+<script type="application/ld+json">not json</script>
+<script type="application/ld+json">{"@type":"BlogPosting","headline":"h","datePublished":"2023-11-02T08:00:00",
+  "image":{"@type":"ImageObject","url":"https://cdn.example.com/a.jpg"},"publisher":{"@type":"Organization","name":"Example"}}</script>
+</head><body><p>Body text of the post.</p></body></html>`)
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(body)),
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+			}, nil
+		}),
+	}
+	f := New(1_000_000, 4096, 1024)
+	f.Client = client
+	parsed, err := f.Fetch(context.Background(), "https://example.com/post")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Title != "Why trigram search works | Example" {
+		t.Fatalf("expected a good <title> to be kept, got %q", parsed.Title)
+	}
+	if parsed.Excerpt != "Plain meta description." {
+		t.Fatalf("excerpt mismatch: %q", parsed.Excerpt)
+	}
+	if parsed.Author != "Meta Author" || parsed.SiteName != "Example" {
+		t.Fatalf("author/site mismatch: %q / %q", parsed.Author, parsed.SiteName)
+	}
+	if parsed.PublishedAt == nil || !parsed.PublishedAt.Equal(time.Date(2023, 11, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("published_at mismatch: %v", parsed.PublishedAt)
+	}
+	if parsed.ImageURL != "https://cdn.example.com/a.jpg" || parsed.Language != "ja-JP" {
+		t.Fatalf("image/language mismatch: %q / %q", parsed.ImageURL, parsed.Language)
+	}
+}
+
+func TestPoorTitle(t *testing.T) {
+	cases := []struct {
+		title, site string
+		want        bool
+	}{
+		{"", "", true},
+		{"Home", "", true},
+		{"Example News", "example news", true},
+		{"ab", "", true},
+		{"An actual headline", "Example News", false},
+		{"日本語の記事", "", false},
+	}
+	for _, tc := range cases {
+		if got := poorTitle(tc.title, tc.site); got != tc.want {
+			t.Errorf("poorTitle(%q, %q) = %v, want %v", tc.title, tc.site, got, tc.want)
+		}
+	}
+}
+
+func TestFetchProducesSanitizedHTML(t *testing.T) {
+	body := []byte(`<html><head><title>Profiling Go services</title></head><body>
+<nav><a href="/">Home</a></nav>
+<article class="post">
+<h1>Profiling Go services</h1>
+<p>Profiling a service in production starts with <a href="/docs/pprof" onclick="track()">pprof</a>, which ships with the standard library and costs very little to leave enabled.</p>
+<script>alert(1)</script>
+<h2 style="color:red">Reading a profile</h2>
+<p>The flame graph shows where time goes, and the widest frames are usually the ones worth optimising first, before anything else.</p>
+<img src="../img/flame.png" alt="Flame graph" onerror="steal()">
+<pre><code>go tool pprof -http=:8080 cpu.out
+  top 10</code></pre>
+<ul><li>CPU profiles, sampled every ten milliseconds by default.</li><li>Heap profiles, which show live allocations.</li></ul>
+<p><a href="javascript:alert(1)">A bad link</a> should keep its text but lose the link, like the rest of them here.</p>
+</article></body></html>`)
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(body)),
+				Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+				Request:    req,
+			}, nil
+		}),
+	}
+	f := New(1_000_000, 4096, 1024)
+	f.Client = client
+	parsed, err := f.Fetch(context.Background(), "https://example.com/blog/profiling")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	html := parsed.ContentHTML
+	for _, want := range []string{
+		`<a href="https://example.com/docs/pprof" rel="noopener noreferrer nofollow" target="_blank">pprof</a>`,
+		`<h2>Reading a profile</h2>`,
+		`<img src="https://example.com/img/flame.png" loading="lazy" referrerpolicy="no-referrer" alt="Flame graph">`,
+		"<pre><code>go tool pprof -http=:8080 cpu.out\n  top 10</code></pre>",
+		`<li>Heap profiles, which show live allocations.</li>`,
+		`<a>A bad link</a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected %q in content html:\n%s", want, html)
+		}
+	}
+	for _, banned := range []string{"<script", "alert(1)", "onclick", "onerror", "style=", "javascript:", "Home"} {
+		if strings.Contains(html, banned) {
+			t.Fatalf("unexpected %q in content html:\n%s", banned, html)
+		}
+	}
+	if strings.Contains(parsed.ContentFull, "<") {
+		t.Fatalf("content_full should stay plain text: %q", parsed.ContentFull)
+	}
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -0,0 +1,186 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedDestination is returned when a request, or one of its redirects, would reach
+// a scheme, port or address that outgoing requests may not use.
+var ErrBlockedDestination = errors.New("blocked_destination")
+
+// allowedPorts are the ports reachable without an allowlist entry.
+var allowedPorts = map[string]bool{"80": true, "443": true, "8080": true, "8443": true}
+
+// blockedPrefixes are special-purpose ranges that netip's predicates do not cover.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds an IPv4 address
+}
+
+// Guard keeps outgoing requests away from the internal network. Every connection is
+// checked against the address actually dialled, so redirects and DNS answers that change
+// between lookups (rebinding) are caught as well. Hosts and ranges on the allowlist skip
+// the address and port checks.
+type Guard struct {
+	allowHosts map[string]bool
+	allowNets  []netip.Prefix
+}
+
+// NewGuard parses a comma-separated allowlist of hostnames, IP addresses and CIDR
+// ranges, e.g. "wiki.internal,10.20.0.0/16".
+func NewGuard(allowlist string) (*Guard, error) {
+	g := &Guard{allowHosts: map[string]bool{}}
+	for _, entry := range strings.Split(allowlist, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q: %w", entry, err)
+			}
+			g.allowNets = append(g.allowNets, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			g.allowNets = append(g.allowNets, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		g.allowHosts[strings.TrimSuffix(entry, ".")] = true
+	}
+	return g, nil
+}
+
+// Transport returns an http.Transport that only reaches permitted destinations. It
+// ignores proxy settings, since a proxy would make the dialled address meaningless.
+func (g *Guard) Transport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = g.dialContext
+	return &guardTransport{guard: g, base: t}
+}
+
+// checkURL reports whether a request to scheme://host:port may be attempted. The
+// address is checked again when the connection is made.
+func (g *Guard) checkURL(scheme, host, port string) error {
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedDestination, scheme)
+	}
+	if g.hostAllowed(host) {
+		return nil
+	}
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	if !allowedPorts[port] {
+		return fmt.Errorf("%w: port %s", ErrBlockedDestination, port)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !g.addrAllowed(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedDestination, addr)
+	}
+	return nil
+}
+
+func (g *Guard) hostAllowed(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if g.allowHosts[host] {
+		return true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return g.inAllowNets(addr.Unmap())
+	}
+	return false
+}
+
+func (g *Guard) inAllowNets(addr netip.Addr) bool {
+	for _, p := range g.allowNets {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrAllowed reports whether addr is a public unicast address or on the allowlist.
+func (g *Guard) addrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if g.inAllowNets(addr) {
+		return true
+	}
+	return publicAddr(addr)
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Guard) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !g.hostAllowed(host) {
+		// Control runs after name resolution for each address tried, so it sees the
+		// address the connection really goes to.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedDestination, address)
+			}
+			if !g.addrAllowed(ap.Addr()) {
+				return fmt.Errorf("%w: %s resolves to %s", ErrBlockedDestination, host, ap.Addr())
+			}
+			return nil
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// guardTransport checks the scheme and port of each request, including every redirect,
+// before handing it to the dialling transport.
+type guardTransport struct {
+	guard *Guard
+	base  http.RoundTripper
+}
+
+func (t *guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.checkURL(req.URL.Scheme, req.URL.Hostname(), req.URL.Port()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
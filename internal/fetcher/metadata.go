@@ -0,0 +1,316 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Length caps for metadata fields, which come straight from the page.
+const (
+	maxMetaText   = 500
+	maxMetaURL    = 2048
+	maxMetaLocale = 35
+)
+
+// genericTitles are <title> values that say nothing about the page.
+var genericTitles = map[string]bool{
+	"home": true, "index": true, "untitled": true, "untitled document": true,
+	"document": true, "article": true, "blog": true, "news": true, "loading...": true,
+}
+
+// metadata is what a page declares about itself through OpenGraph, Twitter card,
+// JSON-LD, microdata and plain <meta> tags. Fields are empty when not declared.
+type metadata struct {
+	Title       string
+	Description string
+	SiteName    string
+	Author      string
+	PublishedAt *time.Time
+	ImageURL    string
+	Language    string
+}
+
+// extractMetadata reads the page metadata. For each field the first source that has a
+// value wins, in the order OpenGraph, Twitter card, JSON-LD, microdata, plain <meta>;
+// the language comes from <html lang> first. Relative image URLs are resolved against base.
+func extractMetadata(doc *goquery.Document, base *url.URL) metadata {
+	ld := findJSONLDArticle(doc)
+	md := doc.Find("[itemscope][itemtype*='Article'], [itemscope][itemtype*='BlogPosting']").First()
+	itemprop := func(name string) string {
+		sel := md.Find("[itemprop='" + name + "']").First()
+		if v, ok := sel.Attr("content"); ok {
+			return strings.TrimSpace(v)
+		}
+		if v, ok := sel.Attr("datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+		if v, ok := sel.Attr("src"); ok {
+			return strings.TrimSpace(v)
+		}
+		return normalizeText(sel.Text())
+	}
+
+	var m metadata
+	m.Title = firstNonEmpty(metaContent(doc, "og:title"), metaContent(doc, "twitter:title"), ldString(ld["headline"]), itemprop("headline"))
+	m.Description = firstNonEmpty(metaContent(doc, "og:description"), metaContent(doc, "twitter:description"), ldString(ld["description"]), metaContent(doc, "description"))
+	m.SiteName = firstNonEmpty(metaContent(doc, "og:site_name"), ldName(ld["publisher"]), metaContent(doc, "application-name"))
+	m.Author = firstNonEmpty(nonURL(metaContent(doc, "article:author")), ldName(ld["author"]), itemprop("author"), metaContent(doc, "author"))
+	m.PublishedAt = parseMetaTime(firstNonEmpty(metaContent(doc, "article:published_time"), ldString(ld["datePublished"]), itemprop("datePublished"), metaContent(doc, "date")))
+	m.ImageURL = absoluteHTTPURL(base, firstNonEmpty(
+		metaContent(doc, "og:image:secure_url"), metaContent(doc, "og:image"), metaContent(doc, "og:image:url"),
+		metaContent(doc, "twitter:image"), metaContent(doc, "twitter:image:src"),
+		ldImage(ld["image"]), itemprop("image"), linkHref(doc, "image_src"),
+	))
+	lang, _ := doc.Find("html").First().Attr("lang")
+	m.Language = normalizeLocale(firstNonEmpty(lang, metaContent(doc, "og:locale"), ldString(ld["inLanguage"]), httpEquiv(doc, "content-language")))
+
+	m.Title = truncateUTF8(m.Title, maxMetaText)
+	m.Description = truncateUTF8(m.Description, maxMetaText)
+	m.SiteName = truncateUTF8(m.SiteName, maxMetaText)
+	m.Author = truncateUTF8(m.Author, maxMetaText)
+	if len(m.ImageURL) > maxMetaURL {
+		m.ImageURL = ""
+	}
+	m.Language = truncateUTF8(m.Language, maxMetaLocale)
+	return m
+}
+
+// poorTitle reports whether a <title> is missing, generic, too short to be useful, or
+// only the site name.
+func poorTitle(title, siteName string) bool {
+	t := strings.ToLower(strings.TrimSpace(title))
+	if utf8.RuneCountInString(t) < 4 || genericTitles[t] {
+		return true
+	}
+	return siteName != "" && t == strings.ToLower(strings.TrimSpace(siteName))
+}
+
+// metaContent returns the content of <meta property=name> or <meta name=name>.
+func metaContent(doc *goquery.Document, name string) string {
+	var value string
+	doc.Find("meta[property], meta[name]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		key, ok := s.Attr("property")
+		if !ok {
+			key, _ = s.Attr("name")
+		}
+		if !strings.EqualFold(strings.TrimSpace(key), name) {
+			return true
+		}
+		content, _ := s.Attr("content")
+		value = normalizeText(content)
+		return value == ""
+	})
+	return value
+}
+
+func httpEquiv(doc *goquery.Document, name string) string {
+	var value string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		key, _ := s.Attr("http-equiv")
+		if !strings.EqualFold(strings.TrimSpace(key), name) {
+			return true
+		}
+		content, _ := s.Attr("content")
+		value = strings.TrimSpace(content)
+		return value == ""
+	})
+	return value
+}
+
+func linkHref(doc *goquery.Document, rel string) string {
+	href, _ := doc.Find("link[rel='" + rel + "']").First().Attr("href")
+	return strings.TrimSpace(href)
+}
+
+// jsonLDArticleTypes are the schema.org types read from JSON-LD.
+var jsonLDArticleTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "BlogPosting": true, "ReportageNewsArticle": true,
+	"AnalysisNewsArticle": true, "OpinionNewsArticle": true, "TechArticle": true, "ScholarlyArticle": true,
+}
+
+// findJSONLDArticle returns the first article object in the page's JSON-LD blocks,
+// looking inside arrays and @graph. Invalid blocks are skipped.
+func findJSONLDArticle(doc *goquery.Document) map[string]interface{} {
+	var found map[string]interface{}
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &v); err != nil {
+			return true
+		}
+		found = findArticleNode(v, 0)
+		return found == nil
+	})
+	return found
+}
+
+func findArticleNode(v interface{}, depth int) map[string]interface{} {
+	if depth > 4 {
+		return nil
+	}
+	switch node := v.(type) {
+	case []interface{}:
+		for _, child := range node {
+			if found := findArticleNode(child, depth+1); found != nil {
+				return found
+			}
+		}
+	case map[string]interface{}:
+		if isArticleType(node["@type"]) {
+			return node
+		}
+		if graph, ok := node["@graph"]; ok {
+			return findArticleNode(graph, depth+1)
+		}
+	}
+	return nil
+}
+
+func isArticleType(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return jsonLDArticleTypes[t]
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && jsonLDArticleTypes[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ldString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return normalizeText(t)
+	case []interface{}:
+		if len(t) > 0 {
+			return ldString(t[0])
+		}
+	}
+	return ""
+}
+
+// ldName reads a Person or Organization, given as a name, an object with a name, or a
+// list of either. Several names are joined with commas.
+func ldName(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return nonURL(normalizeText(t))
+	case map[string]interface{}:
+		return ldString(t["name"])
+	case []interface{}:
+		names := []string{}
+		for _, item := range t {
+			if name := ldName(item); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// ldImage reads an ImageObject, a URL, or a list of either.
+func ldImage(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]interface{}:
+		return ldString(t["url"])
+	case []interface{}:
+		if len(t) > 0 {
+			return ldImage(t[0])
+		}
+	}
+	return ""
+}
+
+// nonURL drops values that are profile links rather than names, as article:author
+// often is.
+func nonURL(s string) string {
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return ""
+	}
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var metaTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseMetaTime parses the ISO 8601 forms pages use. Times without a zone are taken as
+// UTC, and implausible dates are dropped.
+func parseMetaTime(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range metaTimeLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if t.Year() < 1990 || t.After(time.Now().Add(48*time.Hour)) {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+	return nil
+}
+
+// absoluteHTTPURL resolves ref against base and keeps it only if it is http(s).
+func absoluteHTTPURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// normalizeLocale turns "ja_JP" or "EN-us" into a BCP 47 style tag like "ja-JP".
+func normalizeLocale(s string) string {
+	s = strings.TrimSpace(strings.SplitN(s, ",", 2)[0])
+	parts := strings.Split(strings.ReplaceAll(s, "_", "-"), "-")
+	for i, p := range parts {
+		if p == "" {
+			return ""
+		}
+		if i == 0 {
+			parts[i] = strings.ToLower(p)
+		} else if len(p) == 2 {
+			parts[i] = strings.ToUpper(p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,441 @@
+package fetcher
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The extractor below follows Mozilla Readability: paragraphs are scored by length and
+// commas, their scores flow to the enclosing elements, the best-scoring element (after a
+// penalty for link-heavy text) is taken as the article, and siblings that look like part
+// of the same article are merged in. The patterns are Readability's, plus ad class names.
+var (
+	unlikelyCandidateRe = regexp.MustCompile(`(?i)-ad-|\bads?\b|\badvert|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	maybeCandidateRe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveRe          = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeRe          = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|footer|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+)
+
+// removedTags never hold article text. Forms are kept because some sites wrap the whole
+// page in one.
+var removedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Iframe: true,
+	atom.Canvas: true, atom.Svg: true, atom.Object: true, atom.Embed: true, atom.Nav: true,
+	atom.Aside: true, atom.Footer: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true,
+}
+
+var unlikelyRoles = map[string]bool{
+	"menu": true, "menubar": true, "complementary": true, "navigation": true,
+	"alert": true, "alertdialog": true, "dialog": true, "search": true, "contentinfo": true,
+}
+
+// blockTags make a <div> a container rather than a paragraph.
+var blockTags = map[atom.Atom]bool{
+	atom.Blockquote: true, atom.Dl: true, atom.Div: true, atom.Img: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Table: true, atom.Ul: true, atom.Section: true, atom.Article: true,
+	atom.Figure: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// scoredTags are the elements whose text is scored.
+var scoredTags = map[atom.Atom]bool{atom.P: true, atom.Pre: true, atom.Td: true, atom.Blockquote: true}
+
+// minParagraphRunes is the shortest paragraph that counts towards a score.
+const minParagraphRunes = 25
+
+// extractArticle finds the article in doc and returns it wrapped in a detached <div>,
+// or nil if no paragraph was long enough to score. It modifies doc.
+func extractArticle(doc *goquery.Document) *goquery.Selection {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return nil
+	}
+	root := body.Get(0)
+	prepareNodes(root)
+
+	scores := map[*html.Node]float64{}
+	candidates := scoreParagraphs(root, scores)
+	top := topCandidate(root, candidates, scores)
+	if top == nil {
+		return nil
+	}
+
+	article := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for _, n := range articleNodes(top, scores) {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+		article.AppendChild(n)
+	}
+	cleanArticle(article)
+	return goquery.NewDocumentFromNode(article).Selection
+}
+
+// prepareNodes removes elements that are hidden or unlikely to be content, and turns
+// <div>s used as paragraphs into <p>s so that they are scored.
+func prepareNodes(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			if unlikelyNode(c) {
+				n.RemoveChild(c)
+			} else {
+				prepareNodes(c)
+				if c.DataAtom == atom.Div {
+					divToParagraphs(c)
+				}
+			}
+		} else if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+func unlikelyNode(n *html.Node) bool {
+	if removedTags[n.DataAtom] {
+		return true
+	}
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	style, _ := attr(n, "style")
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+	if role, _ := attr(n, "role"); unlikelyRoles[strings.ToLower(role)] {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Body, atom.A, atom.Article, atom.Main:
+		return false
+	}
+	match := classAndID(n)
+	return unlikelyCandidateRe.MatchString(match) && !maybeCandidateRe.MatchString(match) && !hasAncestor(n, atom.Table, atom.Code)
+}
+
+// divToParagraphs renames a <div> with no block children to <p>. Otherwise runs of
+// loose text and inline elements between its blocks are wrapped in <p>s.
+func divToParagraphs(div *html.Node) {
+	if !hasBlockDescendant(div) {
+		div.Data, div.DataAtom = "p", atom.P
+		return
+	}
+	var run []*html.Node
+	flush := func(before *html.Node) {
+		if len(run) > 0 && strings.TrimSpace(nodesText(run)) != "" {
+			p := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+			div.InsertBefore(p, before)
+			for _, n := range run {
+				div.RemoveChild(n)
+				p.AppendChild(n)
+			}
+		}
+		run = run[:0]
+	}
+	for c := div.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && (blockTags[c.DataAtom] || hasBlockDescendant(c)) {
+			flush(c)
+		} else if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			// A <br> between loose text ends the paragraph.
+			flush(c)
+			div.RemoveChild(c)
+		} else {
+			run = append(run, c)
+		}
+		c = next
+	}
+	flush(nil)
+}
+
+func hasBlockDescendant(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (blockTags[c.DataAtom] || hasBlockDescendant(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreParagraphs adds each paragraph's score to its parent, grandparent and up to three
+// further ancestors, with less weight the further up it goes. It returns every element
+// that received a score.
+func scoreParagraphs(root *html.Node, scores map[*html.Node]float64) []*html.Node {
+	var candidates []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			walk(c)
+			if !scoredTags[c.DataAtom] {
+				continue
+			}
+			text := innerText(c)
+			length := utf8.RuneCountInString(text)
+			if length < minParagraphRunes {
+				continue
+			}
+			score := 1 + float64(commaCount(text)) + math.Min(float64(length/100), 3)
+			level := 0
+			for anc := c.Parent; anc != nil && anc.Type == html.ElementNode && level < 5; anc = anc.Parent {
+				if _, ok := scores[anc]; !ok {
+					scores[anc] = initialScore(anc)
+					candidates = append(candidates, anc)
+				}
+				divider := 1.0
+				if level == 1 {
+					divider = 2
+				} else if level > 1 {
+					divider = float64(level * 3)
+				}
+				scores[anc] += score / divider
+				level++
+			}
+		}
+	}
+	walk(root)
+	return candidates
+}
+
+// topCandidate applies the link density penalty to every candidate and returns the best
+// one, moved up to an ancestor when that holds more of the article.
+func topCandidate(body *html.Node, candidates []*html.Node, scores map[*html.Node]float64) *html.Node {
+	var top *html.Node
+	for _, c := range candidates {
+		scores[c] *= 1 - linkDensity(c)
+		if top == nil || scores[c] > scores[top] {
+			top = c
+		}
+	}
+	if top == nil || scores[top] <= 0 {
+		return nil
+	}
+
+	// A parent scoring higher than the candidate holds more of the article, e.g. when the
+	// text is split over several sibling containers.
+	topScore := scores[top]
+	for parent := top.Parent; parent != nil && parent != body && parent.Type == html.ElementNode; parent = parent.Parent {
+		score, ok := scores[parent]
+		if !ok {
+			continue
+		}
+		if score < topScore/3 {
+			break
+		}
+		if score > topScore {
+			top = parent
+			break
+		}
+	}
+	// An only child says no more than its parent.
+	for top.Parent != nil && top.Parent != body && top.Parent.Type == html.ElementNode && elementChildren(top.Parent) == 1 {
+		if _, ok := scores[top.Parent]; !ok {
+			scores[top.Parent] = scores[top]
+		}
+		top = top.Parent
+	}
+	return top
+}
+
+// articleNodes returns top together with the siblings that belong to the same article:
+// those that scored well, share top's class, or are plain text paragraphs.
+func articleNodes(top *html.Node, scores map[*html.Node]float64) []*html.Node {
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+	topScore := scores[top]
+	threshold := math.Max(10, topScore*0.2)
+	topClass, _ := attr(top, "class")
+
+	var nodes []*html.Node
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s.Type != html.ElementNode {
+			continue
+		}
+		if s == top {
+			nodes = append(nodes, s)
+			continue
+		}
+		bonus := 0.0
+		if class, _ := attr(s, "class"); class != "" && class == topClass {
+			bonus = topScore * 0.2
+		}
+		if score, ok := scores[s]; ok && score+bonus >= threshold {
+			nodes = append(nodes, s)
+			continue
+		}
+		if s.DataAtom == atom.P {
+			text := innerText(s)
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(s)
+			if (length > 80 && density < 0.25) || (length > 0 && length <= 80 && density == 0 && endsSentence(text)) {
+				nodes = append(nodes, s)
+			}
+		}
+	}
+	return nodes
+}
+
+// cleanArticle drops link lists and tables left inside the article, such as "read more"
+// blocks and tag clouds.
+func cleanArticle(article *html.Node) {
+	var walk func(*html.Node, bool)
+	walk = func(n *html.Node, top bool) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode {
+				switch c.DataAtom {
+				case atom.Ul, atom.Ol, atom.Div, atom.Section, atom.Table:
+					if !top && (linkDensity(c) > 0.5 || classWeight(c) < 0 && utf8.RuneCountInString(innerText(c)) < 200) {
+						n.RemoveChild(c)
+						c = next
+						continue
+					}
+				}
+				walk(c, false)
+			}
+			c = next
+		}
+	}
+	walk(article, true)
+}
+
+func initialScore(n *html.Node) float64 {
+	score := float64(classWeight(n))
+	switch n.DataAtom {
+	case atom.Div:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	return score
+}
+
+func classWeight(n *html.Node) int {
+	weight := 0
+	for _, key := range []string{"class", "id"} {
+		v, _ := attr(n, key)
+		if v == "" {
+			continue
+		}
+		if negativeRe.MatchString(v) {
+			weight -= 25
+		}
+		if positiveRe.MatchString(v) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of n's text inside links. In-page links count less, as they
+// are usually footnotes or a table of contents.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(innerText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0.0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.DataAtom == atom.A {
+				weight := 1.0
+				if href, _ := attr(c, "href"); strings.HasPrefix(href, "#") {
+					weight = 0.3
+				}
+				linked += float64(utf8.RuneCountInString(innerText(c))) * weight
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return linked / float64(total)
+}
+
+// commaCount counts ASCII, full-width and ideographic commas, so that Japanese and
+// Chinese paragraphs score like English ones.
+func commaCount(text string) int {
+	return strings.Count(text, ",") + strings.Count(text, "，") + strings.Count(text, "、")
+}
+
+func endsSentence(text string) bool {
+	return strings.HasSuffix(text, ".") || strings.Contains(text, ". ") || strings.ContainsRune(text, '。')
+}
+
+func innerText(n *html.Node) string {
+	return normalizeText(nodesText([]*html.Node{n}))
+}
+
+func nodesText(nodes []*html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func elementChildren(n *html.Node) int {
+	count := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			count++
+		}
+	}
+	return count
+}
+
+func classAndID(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return class + " " + id
+}
+
+func hasAncestor(n *html.Node, tags ...atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		for _, t := range tags {
+			if p.DataAtom == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+// Package highlight anchors quoted text ranges in an item's plain-text content.
+// Offsets are counted in Unicode code points.
+package highlight
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContextLength is how many code points of surrounding text are kept on each side of a
+// quote to tell repeated occurrences apart.
+const ContextLength = 32
+
+// Locate finds quote in content. When the quote occurs more than once, the occurrence
+// whose surrounding text best matches prefix and suffix wins, then the one closest to
+// hint. It returns the code point range of the match.
+func Locate(content, quote, prefix, suffix string, hint int) (start, end int, ok bool) {
+	if quote == "" {
+		return 0, 0, false
+	}
+	quoteLen := utf8.RuneCountInString(quote)
+	bestScore, bestDistance := -1, 0
+
+	runePos, bytePos := 0, 0
+	for {
+		idx := strings.Index(content[bytePos:], quote)
+		if idx < 0 {
+			break
+		}
+		runePos += utf8.RuneCountInString(content[bytePos : bytePos+idx])
+		bytePos += idx
+
+		score := commonSuffix(content[:bytePos], prefix) + commonPrefix(content[bytePos+len(quote):], suffix)
+		distance := runePos - hint
+		if distance < 0 {
+			distance = -distance
+		}
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			bestScore, bestDistance = score, distance
+			start, end, ok = runePos, runePos+quoteLen, true
+		}
+
+		_, size := utf8.DecodeRuneInString(content[bytePos:])
+		bytePos += size
+		runePos++
+	}
+	return start, end, ok
+}
+
+// Context returns up to ContextLength code points before start and after end.
+func Context(content string, start, end int) (prefix, suffix string) {
+	runes := []rune(content)
+	if start < 0 || end > len(runes) || start > end {
+		return "", ""
+	}
+	return string(runes[max(0, start-ContextLength):start]), string(runes[end:min(len(runes), end+ContextLength)])
+}
+
+// Span is an anchored highlight to render.
+type Span struct {
+	ID    string
+	Note  string
+	Start int
+	End   int
+}
+
+// Segment is a run of content that is either plain (ID empty) or part of a highlight.
+type Segment struct {
+	Text string
+	ID   string
+	Note string
+}
+
+// Segments splits content into plain and highlighted runs. Spans that are out of range
+// or overlap an earlier span are skipped.
+func Segments(content string, spans []Span) []Segment {
+	runes := []rune(content)
+	sorted := make([]Span, len(spans))
+	copy(sorted, spans)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var segments []Segment
+	pos := 0
+	for _, sp := range sorted {
+		if sp.Start < pos || sp.End <= sp.Start || sp.End > len(runes) {
+			continue
+		}
+		if sp.Start > pos {
+			segments = append(segments, Segment{Text: string(runes[pos:sp.Start])})
+		}
+		segments = append(segments, Segment{Text: string(runes[sp.Start:sp.End]), ID: sp.ID, Note: sp.Note})
+		pos = sp.End
+	}
+	if pos < len(runes) {
+		segments = append(segments, Segment{Text: string(runes[pos:])})
+	}
+	return segments
+}
+
+// Overlaps reports whether the range [start, end) intersects any span.
+func Overlaps(spans []Span, start, end int) bool {
+	for _, sp := range spans {
+		if start < sp.End && sp.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func commonSuffix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
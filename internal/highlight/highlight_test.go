@@ -0,0 +1,69 @@
+package highlight
+
+import "testing"
+
+func TestLocatePrefersMatchingContext(t *testing.T) {
+	content := "the cat sat. a dog ran. the cat slept."
+	start, end, ok := Locate(content, "the cat", "ran. ", " slept", 0)
+	if !ok || start != 24 || end != 31 {
+		t.Fatalf("Locate = %d,%d,%v; want 24,31,true", start, end, ok)
+	}
+}
+
+func TestLocateUsesHintWithoutContext(t *testing.T) {
+	content := "ab ab ab"
+	if start, _, ok := Locate(content, "ab", "", "", 4); !ok || start != 3 {
+		t.Fatalf("expected the occurrence nearest the hint, got %d %v", start, ok)
+	}
+}
+
+func TestLocateCountsCodePoints(t *testing.T) {
+	content := "日本語の文章です。大事な部分。"
+	start, end, ok := Locate(content, "大事な部分", "", "", 0)
+	if !ok || start != 9 || end != 14 {
+		t.Fatalf("Locate = %d,%d,%v; want 9,14,true", start, end, ok)
+	}
+	prefix, suffix := Context(content, start, end)
+	if prefix != "日本語の文章です。" || suffix != "。" {
+		t.Fatalf("Context = %q,%q", prefix, suffix)
+	}
+}
+
+func TestLocateMissingQuote(t *testing.T) {
+	if _, _, ok := Locate("rewritten article", "old sentence", "", "", 0); ok {
+		t.Fatalf("expected missing quote to fail")
+	}
+	if _, _, ok := Locate("text", "", "", "", 0); ok {
+		t.Fatalf("expected empty quote to fail")
+	}
+}
+
+func TestSegments(t *testing.T) {
+	content := "héllo big world"
+	got := Segments(content, []Span{
+		{ID: "b", Start: 10, End: 15},
+		{ID: "a", Note: "n", Start: 0, End: 5},
+		{ID: "overlap", Start: 3, End: 8},
+		{ID: "out", Start: 12, End: 40},
+	})
+	want := []Segment{
+		{Text: "héllo", ID: "a", Note: "n"},
+		{Text: " big "},
+		{Text: "world", ID: "b"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Segments = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("segment %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	spans := []Span{{Start: 5, End: 10}}
+	if !Overlaps(spans, 8, 12) || Overlaps(spans, 10, 12) || Overlaps(spans, 0, 5) {
+		t.Fatalf("unexpected overlap result")
+	}
+}
@@ -0,0 +1,212 @@
+// Package importer parses Pocket export files (ril_export.html and the CSV export).
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"altpocket/internal/tag"
+	"altpocket/internal/urlnorm"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	FormatPocketHTML = "pocket_html"
+	FormatPocketCSV  = "pocket_csv"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown_format")
+	ErrNoEntries     = errors.New("no_entries")
+	ErrMalformed     = errors.New("malformed_export")
+)
+
+type Entry struct {
+	URL           string
+	CanonicalURL  string
+	CanonicalHash string
+	Title         string
+	TimeAdded     time.Time
+	Tags          []string
+	Archived      bool
+}
+
+type Result struct {
+	Format  string
+	Entries []Entry
+	// Invalid counts rows that were skipped because their URL could not be used.
+	Invalid int
+}
+
+// Parse detects the export format from the file name, falling back to sniffing the
+// content, and returns the entries with canonicalized URLs and normalized tags.
+func Parse(filename string, data []byte) (Result, error) {
+	format := detectFormat(filename, data)
+	var raw []Entry
+	var err error
+	switch format {
+	case FormatPocketHTML:
+		raw, err = parseHTML(bytes.NewReader(data))
+	case FormatPocketCSV:
+		raw, err = parseCSV(bytes.NewReader(data))
+	default:
+		return Result{}, ErrUnknownFormat
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	res := Result{Format: format, Entries: make([]Entry, 0, len(raw))}
+	for _, e := range raw {
+		if !isWebURL(e.URL) {
+			res.Invalid++
+			continue
+		}
+		canonicalURL, canonicalHash, err := urlnorm.Canonicalize(e.URL)
+		if err != nil {
+			res.Invalid++
+			continue
+		}
+		e.CanonicalURL = canonicalURL
+		e.CanonicalHash = canonicalHash
+		res.Entries = append(res.Entries, e)
+	}
+	if len(res.Entries) == 0 && res.Invalid == 0 {
+		return Result{}, ErrNoEntries
+	}
+	return res, nil
+}
+
+func detectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm":
+		return FormatPocketHTML
+	case ".csv":
+		return FormatPocketCSV
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return FormatPocketHTML
+	}
+	if bytes.HasPrefix(bytes.ToLower(trimmed), []byte("title,url")) {
+		return FormatPocketCSV
+	}
+	return ""
+}
+
+// parseHTML reads ril_export.html, where links are grouped under "Unread" and
+// "Read Archive" headings and carry time_added and comma-separated tags attributes.
+func parseHTML(r io.Reader) ([]Entry, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	archived := false
+	doc.Find("h1, a").Each(func(_ int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "h1" {
+			archived = strings.Contains(strings.ToLower(s.Text()), "archive")
+			return
+		}
+		href, ok := s.Attr("href")
+		if !ok {
+			return
+		}
+		tagsAttr, _ := s.Attr("tags")
+		timeAdded, _ := s.Attr("time_added")
+		entries = append(entries, Entry{
+			URL:       strings.TrimSpace(href),
+			Title:     strings.TrimSpace(s.Text()),
+			TimeAdded: parseUnix(timeAdded),
+			Tags:      normalizeTags(strings.Split(tagsAttr, ",")),
+			Archived:  archived,
+		})
+	})
+	return entries, nil
+}
+
+// parseCSV reads the newer CSV export with the header title,url,time_added,tags,status,
+// where tags are separated by "|" and status is "unread" or "archive".
+func parseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := cols["url"]; !ok {
+		return nil, ErrUnknownFormat
+	}
+	field := func(record []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entries := []Entry{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			URL:       field(record, "url"),
+			Title:     field(record, "title"),
+			TimeAdded: parseUnix(field(record, "time_added")),
+			Tags:      normalizeTags(strings.Split(field(record, "tags"), "|")),
+			Archived:  strings.EqualFold(field(record, "status"), "archive"),
+		})
+	}
+	return entries, nil
+}
+
+func parseUnix(v string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+func normalizeTags(raw []string) []string {
+	out := make([]string, 0, len(raw))
+	seen := map[string]struct{}{}
+	for _, t := range raw {
+		norm := tag.Normalize(t)
+		if norm == "" {
+			continue
+		}
+		if _, ok := seen[norm]; ok {
+			continue
+		}
+		seen[norm] = struct{}{}
+		out = append(out, norm)
+	}
+	return out
+}
+
+func isWebURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
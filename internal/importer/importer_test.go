@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const pocketHTML = `<!DOCTYPE html>
+<html>
+<head><title>Pocket Export</title></head>
+<body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://example.com/a?utm_source=x" time_added="1600000000" tags="Go,reading">Article A</a></li>
+<li><a href="javascript:alert(1)" time_added="1600000001" tags="">Bad</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://example.com/b" time_added="1500000000" tags="">Article B</a></li>
+</ul>
+</body>
+</html>`
+
+const pocketCSV = "title,url,time_added,tags,status\n" +
+	"Article A,https://example.com/a,1600000000,Go|reading|go,unread\n" +
+	"\"Comma, title\",https://example.com/b,1500000000,,archive\n" +
+	"No URL,,1500000000,,unread\n"
+
+func TestParseHTML(t *testing.T) {
+	res, err := Parse("ril_export.html", []byte(pocketHTML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatPocketHTML {
+		t.Fatalf("unexpected format: %s", res.Format)
+	}
+	if len(res.Entries) != 2 || res.Invalid != 1 {
+		t.Fatalf("expected 2 entries and 1 invalid, got %d and %d", len(res.Entries), res.Invalid)
+	}
+
+	a := res.Entries[0]
+	if a.Title != "Article A" || a.Archived {
+		t.Fatalf("unexpected first entry: %#v", a)
+	}
+	if a.CanonicalURL != "https://example.com/a" || a.CanonicalHash == "" {
+		t.Fatalf("expected canonicalized url, got %q", a.CanonicalURL)
+	}
+	if !a.TimeAdded.Equal(time.Unix(1600000000, 0)) {
+		t.Fatalf("unexpected time_added: %v", a.TimeAdded)
+	}
+	if len(a.Tags) != 2 || a.Tags[0] != "go" || a.Tags[1] != "reading" {
+		t.Fatalf("unexpected tags: %#v", a.Tags)
+	}
+
+	b := res.Entries[1]
+	if !b.Archived || len(b.Tags) != 0 {
+		t.Fatalf("expected archived entry without tags, got %#v", b)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	res, err := Parse("part_000000.csv", []byte(pocketCSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Format != FormatPocketCSV || len(res.Entries) != 2 || res.Invalid != 1 {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+	if tags := res.Entries[0].Tags; len(tags) != 2 || tags[0] != "go" || tags[1] != "reading" {
+		t.Fatalf("expected deduplicated tags, got %#v", tags)
+	}
+	if res.Entries[1].Title != "Comma, title" || !res.Entries[1].Archived {
+		t.Fatalf("unexpected archived entry: %#v", res.Entries[1])
+	}
+}
+
+func TestParseDetectsFormatFromContent(t *testing.T) {
+	res, err := Parse("export", []byte("\ufeff"+pocketCSV))
+	if err != nil || res.Format != FormatPocketCSV {
+		t.Fatalf("expected csv to be sniffed, got %q, %v", res.Format, err)
+	}
+	res, err = Parse("", []byte(pocketHTML))
+	if err != nil || res.Format != FormatPocketHTML {
+		t.Fatalf("expected html to be sniffed, got %q, %v", res.Format, err)
+	}
+	if _, err := Parse("notes.txt", []byte("hello")); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestParseRejectsEmptyExport(t *testing.T) {
+	if _, err := Parse("part_000000.csv", []byte("title,url,time_added,tags,status\n")); !errors.Is(err, ErrNoEntries) {
+		t.Fatalf("expected ErrNoEntries, got %v", err)
+	}
+	if _, err := Parse("part_000000.csv", []byte("foo,bar\n1,2\n")); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package sanitize renders untrusted article HTML through a strict allowlist for the
+// reader view. Only simple formatting, links, lists, tables, code and images survive;
+// scripts, styles, embeds, forms, event handlers, classes and inline styles never do.
+// Other elements are unwrapped so that their text is kept.
+package sanitize
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags maps each kept element to the attributes it may carry.
+var allowedTags = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.A:      {"href", "title"},
+	atom.Strong: nil, atom.B: nil, atom.Em: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Del: nil, atom.Ins: nil, atom.Mark: nil, atom.Small: nil, atom.Sub: nil, atom.Sup: nil,
+	atom.Code: nil, atom.Pre: nil, atom.Kbd: nil, atom.Samp: nil, atom.Var: nil,
+	atom.Blockquote: nil, atom.Q: nil, atom.Cite: nil, atom.Abbr: {"title"}, atom.Time: {"datetime"},
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Figure: nil, atom.Figcaption: nil,
+	atom.Img:   {"src", "alt", "title", "width", "height"},
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan", "scope"}, atom.Td: {"colspan", "rowspan"},
+}
+
+// droppedTags are removed together with everything inside them.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Iframe: true,
+	atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true, atom.Applet: true,
+	atom.Svg: true, atom.Math: true, atom.Canvas: true, atom.Video: true, atom.Audio: true,
+	atom.Source: true, atom.Track: true, atom.Form: true, atom.Input: true, atom.Button: true,
+	atom.Select: true, atom.Textarea: true, atom.Option: true, atom.Head: true, atom.Title: true,
+	atom.Meta: true, atom.Link: true, atom.Base: true, atom.Dialog: true,
+}
+
+var voidTags = map[atom.Atom]bool{atom.Br: true, atom.Hr: true, atom.Img: true}
+
+// lazySrcAttrs hold the real image URL on pages that lazy-load images.
+var lazySrcAttrs = []string{"data-src", "data-original", "data-lazy-src", "src"}
+
+// Children returns the sanitized HTML of n's children. Relative link and image URLs are
+// resolved against base; with a nil base only absolute URLs are kept.
+func Children(n *html.Node, base *url.URL) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(&b, c, base, false)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// HTML sanitizes an HTML fragment, such as stored article HTML, again before display.
+func HTML(s string) string {
+	div := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(s), div)
+	if err != nil {
+		return ""
+	}
+	for _, n := range nodes {
+		div.AppendChild(n)
+	}
+	return Children(div, nil)
+}
+
+func render(b *strings.Builder, n *html.Node, base *url.URL, inPre bool) {
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if !inPre {
+			text = collapseSpace(text)
+		}
+		b.WriteString(html.EscapeString(text))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if droppedTags[n.DataAtom] {
+		return
+	}
+	attrs, ok := allowedTags[n.DataAtom]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(b, c, base, inPre)
+		}
+		return
+	}
+
+	tag := n.DataAtom.String()
+	// The page title is already the reader view's heading.
+	if n.DataAtom == atom.H1 {
+		tag = "h2"
+	}
+	var out []html.Attribute
+	switch n.DataAtom {
+	case atom.A:
+		if href, ok := resolveURL(base, attr(n, "href"), "http", "https", "mailto"); ok {
+			out = append(out, html.Attribute{Key: "href", Val: href},
+				html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"},
+				html.Attribute{Key: "target", Val: "_blank"})
+		}
+	case atom.Img:
+		src, ok := imageSource(n, base)
+		if !ok {
+			return
+		}
+		out = append(out, html.Attribute{Key: "src", Val: src},
+			html.Attribute{Key: "loading", Val: "lazy"},
+			html.Attribute{Key: "referrerpolicy", Val: "no-referrer"})
+	}
+	for _, key := range attrs {
+		if key == "href" || key == "src" {
+			continue
+		}
+		v, ok := cleanAttr(key, attr(n, key))
+		if ok {
+			out = append(out, html.Attribute{Key: key, Val: v})
+		}
+	}
+
+	b.WriteString("<" + tag)
+	for _, a := range out {
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	b.WriteString(">")
+	if voidTags[n.DataAtom] {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c, base, inPre || n.DataAtom == atom.Pre)
+	}
+	b.WriteString("</" + tag + ">")
+}
+
+// cleanAttr validates a non-URL attribute. Sizes and counts must be small numbers.
+func cleanAttr(key, v string) (string, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", false
+	}
+	switch key {
+	case "width", "height", "colspan", "rowspan", "start":
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 10000 {
+			return "", false
+		}
+		return strconv.Itoa(n), true
+	case "scope":
+		switch v {
+		case "row", "col", "rowgroup", "colgroup":
+			return v, true
+		}
+		return "", false
+	}
+	if len(v) > 500 {
+		// Cut at a rune boundary so that the output stays valid UTF-8.
+		n := 500
+		for n > 0 && !utf8.RuneStart(v[n]) {
+			n--
+		}
+		v = v[:n]
+	}
+	return v, true
+}
+
+func imageSource(n *html.Node, base *url.URL) (string, bool) {
+	for _, key := range lazySrcAttrs {
+		if src, ok := resolveURL(base, attr(n, key), "http", "https"); ok {
+			return src, true
+		}
+	}
+	return "", false
+}
+
+// resolveURL resolves raw against base and keeps it only if its scheme is allowed.
+// In-page links are dropped, since the ids they point to are not kept.
+func resolveURL(base *url.URL, raw string, schemes ...string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	for _, s := range schemes {
+		if scheme != s {
+			continue
+		}
+		if s != "mailto" && u.Host == "" {
+			return "", false
+		}
+		return u.String(), true
+	}
+	return "", false
+}
+
+// collapseSpace shortens runs of whitespace to one space, keeping a space at either end
+// so that words in neighbouring elements stay apart.
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeft(s, " \t\n\r\f") != s {
+		out = " " + out
+	}
+	if strings.TrimRight(s, " \t\n\r\f") != s {
+		out += " "
+	}
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+func TestHTML(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"keeps formatting", `<p>Some <strong>bold</strong> and <em>italic</em> text.</p>`, `<p>Some <strong>bold</strong> and <em>italic</em> text.</p>`},
+		{"drops scripts and styles", `<p>a</p><script>alert(1)</script><style>p{}</style><p>b</p>`, `<p>a</p><p>b</p>`},
+		{"drops event handlers and styles", `<p onclick="x()" style="color:red" class="lead" id="p1">a</p>`, `<p>a</p>`},
+		{"unwraps unknown tags", `<div><section><span>kept</span></section></div>`, `kept`},
+		{"drops embeds with content", `<p>a</p><iframe src="https://e.x"><p>fallback</p></iframe><svg><text>b</text></svg>`, `<p>a</p>`},
+		{"drops forms", `<form action="/x"><input name="q"><button>Go</button></form><p>a</p>`, `<p>a</p>`},
+		{"demotes h1", `<h1>Title</h1>`, `<h2>Title</h2>`},
+		{"safe link", `<a href="https://e.x/a?b=1&amp;c=2" title="t">x</a>`, `<a href="https://e.x/a?b=1&amp;c=2" rel="noopener noreferrer nofollow" target="_blank" title="t">x</a>`},
+		{"mailto link", `<a href="mailto:a@e.x">mail</a>`, `<a href="mailto:a@e.x" rel="noopener noreferrer nofollow" target="_blank">mail</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"obfuscated javascript link", `<a href=" JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"fragment link", `<a href="#top">x</a>`, `<a>x</a>`},
+		{"relative link without base", `<a href="/a">x</a>`, `<a>x</a>`},
+		{"data image", `<img src="data:image/png;base64,AAAA" alt="x">`, ``},
+		{"image", `<img src="https://e.x/i.png" alt="A &quot;pic&quot;" width="640" height="abc" onerror="x()">`, `<img src="https://e.x/i.png" loading="lazy" referrerpolicy="no-referrer" alt="A &#34;pic&#34;" width="640">`},
+		{"table", `<table><tr><th scope="col" colspan="2">h</th></tr><tr><td rowspan="x">d</td></tr></table>`, `<table><tbody><tr><th colspan="2" scope="col">h</th></tr><tr><td>d</td></tr></tbody></table>`},
+		{"collapses whitespace", "<p>a\n\n   b <em> c </em></p>", `<p>a b <em> c </em></p>`},
+		{"keeps pre whitespace", "<pre><code>a\n  b &lt;c&gt;</code></pre>", "<pre><code>a\n  b &lt;c&gt;</code></pre>"},
+		{"escapes text", `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HTML(tc.in); got != tc.want {
+				t.Fatalf("HTML(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChildrenResolvesURLs(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<body><p><a href="../b">link</a>
+<img data-src="/lazy.png" src="data:image/gif;base64,R0lGOD">
+<img src="img/c.png"></p></body>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := doc.FirstChild.LastChild
+	base, _ := url.Parse("https://e.x/blog/post/")
+	got := Children(body, base)
+	for _, want := range []string{
+		`href="https://e.x/blog/b"`,
+		`src="https://e.x/lazy.png"`,
+		`src="https://e.x/blog/post/img/c.png"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %q", want, got)
+		}
+	}
+}
+
+func TestHTMLIsIdempotent(t *testing.T) {
+	in := `<h2>T</h2><p>a <a href="https://e.x/">b</a></p><img src="https://e.x/i.png" alt="x"><pre> x
+ y</pre>`
+	once := HTML(in)
+	if twice := HTML(once); twice != once {
+		t.Fatalf("sanitizing stored HTML changed it:\n%q\n%q", once, twice)
+	}
+}
+
+func TestHTMLTruncatesLongAttributesOnRuneBoundaries(t *testing.T) {
+	// 499 ASCII bytes leave a 3-byte rune straddling the 500-byte limit.
+	alt := strings.Repeat("a", 499) + strings.Repeat("日", 10)
+	got := HTML(`<img src="https://e.x/i.png" alt="` + alt + `">`)
+	if !utf8.ValidString(got) {
+		t.Fatalf("invalid UTF-8 in %q", got)
+	}
+	if !strings.Contains(got, `alt="`+strings.Repeat("a", 499)+`"`) {
+		t.Fatalf("alt not cut before the straddling rune: %q", got)
+	}
+}
@@ -270,3 +270,20 @@ func TestCheckCSRFSkipsBearerRequests(t *testing.T) {
 		t.Fatalf("expected csrf check to be skipped for bearer auth, got: %v", err)
 	}
 }
+
+func TestHandleExtensionRefreshMissingToken(t *testing.T) {
+	s := newAuthTestServer()
+	for _, body := range []string{"{", `{}`, `{"refresh_token":""}`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/extension/refresh", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		s.handleExtensionRefresh(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "invalid_request") {
+			t.Fatalf("%s: expected invalid_request body, got %q", body, rr.Body.String())
+		}
+	}
+}
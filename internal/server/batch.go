@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/tag"
+)
+
+// maxBatchItems caps the item/action pairs in one batch request.
+const maxBatchItems = 500
+
+var (
+	errBatchEmpty         = errors.New("empty_batch")
+	errBatchTooLarge      = errors.New("too_many_items")
+	errBatchUnknownAction = errors.New("unknown_action")
+	errBatchMissingTags   = errors.New("missing_tags")
+)
+
+type batchAction struct {
+	Action  string   `json:"action"`
+	ItemIDs []string `json:"item_ids"`
+	Tags    []string `json:"tags"`
+}
+
+// handleBatchItems applies several actions over many items in one transaction. It
+// costs a single rate-limit token regardless of how many items it touches.
+func (s *Server) handleBatchItems(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	var req struct {
+		Actions []batchAction `json:"actions"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	ops, err := batchOps(req.Actions)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	results, err := s.store.ApplyItemBatch(r.Context(), user.ID, ops)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	failed := 0
+	for _, res := range results {
+		if !res.OK {
+			failed++
+		}
+	}
+	s.logger.Info("items.batch",
+		slog.String("user_id", user.ID),
+		slog.Int("actions", len(ops)),
+		slog.Int("results", len(results)),
+		slog.Int("failed", failed),
+		slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// batchOps validates the requested actions and converts them to store operations.
+func batchOps(actions []batchAction) ([]store.BatchOp, error) {
+	if len(actions) == 0 {
+		return nil, errBatchEmpty
+	}
+	total := 0
+	ops := make([]store.BatchOp, 0, len(actions))
+	for _, a := range actions {
+		op := store.BatchOp{Action: a.Action, ItemIDs: uniqueStrings(a.ItemIDs)}
+		switch a.Action {
+		case store.BatchAddTags, store.BatchRemoveTags:
+			op.Tags = tag.NormalizeAll(a.Tags)
+			if len(op.Tags) == 0 {
+				return nil, errBatchMissingTags
+			}
+		case store.BatchDelete, store.BatchArchive, store.BatchRefetch:
+		default:
+			return nil, errBatchUnknownAction
+		}
+		total += len(op.ItemIDs)
+		ops = append(ops, op)
+	}
+	if total == 0 {
+		return nil, errBatchEmpty
+	}
+	if total > maxBatchItems {
+		return nil, errBatchTooLarge
+	}
+	return ops, nil
+}
+
+func uniqueStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"altpocket/internal/store"
+)
+
+func TestBatchOpsValidatesActions(t *testing.T) {
+	ops, err := batchOps([]batchAction{
+		{Action: "add_tags", ItemIDs: []string{"a", "b", "a", ""}, Tags: []string{" Go ", "go"}},
+		{Action: "archive", ItemIDs: []string{"c"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops) != 2 || len(ops[0].ItemIDs) != 2 || ops[0].Action != store.BatchAddTags {
+		t.Fatalf("unexpected ops: %#v", ops)
+	}
+	if len(ops[0].Tags) != 1 || ops[0].Tags[0] != "go" {
+		t.Fatalf("expected normalized tags, got %#v", ops[0].Tags)
+	}
+
+	cases := []struct {
+		actions []batchAction
+		want    error
+	}{
+		{nil, errBatchEmpty},
+		{[]batchAction{{Action: "archive"}}, errBatchEmpty},
+		{[]batchAction{{Action: "explode", ItemIDs: []string{"a"}}}, errBatchUnknownAction},
+		{[]batchAction{{Action: "remove_tags", ItemIDs: []string{"a"}, Tags: []string{" "}}}, errBatchMissingTags},
+	}
+	for _, tc := range cases {
+		if _, err := batchOps(tc.actions); !errors.Is(err, tc.want) {
+			t.Fatalf("batchOps(%#v) = %v, want %v", tc.actions, err, tc.want)
+		}
+	}
+}
+
+func TestBatchOpsCapsItemCount(t *testing.T) {
+	ids := make([]string, 0, maxBatchItems+1)
+	for i := 0; i <= maxBatchItems; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	if _, err := batchOps([]batchAction{{Action: "delete", ItemIDs: ids}}); !errors.Is(err, errBatchTooLarge) {
+		t.Fatalf("expected errBatchTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,445 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxCollectionTitle       = 200
+	maxCollectionDescription = 2000
+	// maxCollectionPageItems caps how many items the collection page lists for reordering.
+	maxCollectionPageItems = 500
+)
+
+var errInvalidCollection = errors.New("invalid_collection")
+
+func (s *Server) handleListCollections(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	collections, err := s.store.ListCollections(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": collections})
+}
+
+func (s *Server) handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	title, description, err := collectionFields(&req.Title, &req.Description)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c, err := s.store.CreateCollection(r.Context(), user.ID, *title, *description)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// handleGetCollection returns the collection with a page of its items in manual order.
+func (s *Server) handleGetCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	c, err := s.store.GetCollection(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	perPage := perPageValue(r.URL.Query().Get("per_page"))
+	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, store.ItemFilter{Collection: c.ID, Sort: "position"})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collection": c, "items": items, "pagination": pag})
+}
+
+func (s *Server) handleUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	title, description, err := collectionFields(req.Title, req.Description)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c, err := s.store.UpdateCollection(r.Context(), user.ID, chi.URLParam(r, "id"), title, description)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) handleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.DeleteCollection(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAddCollectionItems(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	itemIDs, ok := decodeItemIDs(w, r)
+	if !ok {
+		return
+	}
+	added, err := s.store.AddCollectionItems(r.Context(), user.ID, chi.URLParam(r, "id"), itemIDs)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"added": added})
+}
+
+func (s *Server) handleRemoveCollectionItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.RemoveCollectionItem(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "itemID")); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReorderCollection sets the manual order. Items missing from the list keep their
+// relative order after the listed ones.
+func (s *Server) handleReorderCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	itemIDs, ok := decodeItemIDs(w, r)
+	if !ok {
+		return
+	}
+	collectionID := chi.URLParam(r, "id")
+	if err := s.store.ReorderCollection(r.Context(), user.ID, collectionID, itemIDs); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	s.logger.Info("collections.reorder",
+		slog.String("collection_id", collectionID),
+		slog.Int("items", len(itemIDs)),
+		slog.String("request_id", s.requestID(r.Context())))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeItemIDs reads {"item_ids": [...]} and writes the error response itself.
+func decodeItemIDs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var req struct {
+		ItemIDs []string `json:"item_ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return nil, false
+	}
+	ids := uniqueStrings(req.ItemIDs)
+	if len(ids) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "empty_batch"})
+		return nil, false
+	}
+	if len(ids) > maxBatchItems {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "too_many_items"})
+		return nil, false
+	}
+	return ids, true
+}
+
+func writeCollectionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+}
+
+// collectionFields trims and validates the title and description. Nil values are
+// passed through for partial updates; a title, when given, must not be empty.
+func collectionFields(title, description *string) (*string, *string, error) {
+	if title != nil {
+		t := strings.TrimSpace(*title)
+		if t == "" || utf8.RuneCountInString(t) > maxCollectionTitle {
+			return nil, nil, errInvalidCollection
+		}
+		title = &t
+	}
+	if description != nil {
+		d := strings.TrimSpace(*description)
+		if utf8.RuneCountInString(d) > maxCollectionDescription {
+			return nil, nil, errInvalidCollection
+		}
+		description = &d
+	}
+	return title, description, nil
+}
+
+func (s *Server) handleUICollections(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	collections, err := s.store.ListCollections(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":       "Collections",
+		"User":        user,
+		"CSRFToken":   s.csrfFromContext(r.Context()),
+		"Notice":      collectionsNotice(r.URL.Query().Get("notice")),
+		"Collections": collections,
+	}
+	if err := s.renderer.Render(w, "collections", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c, err := s.store.GetCollection(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	items, total, err := s.store.ListItemsRange(r.Context(), user.ID, store.ItemFilter{Collection: c.ID, Sort: "position"}, 0, maxCollectionPageItems)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":      c.Title,
+		"User":       user,
+		"CSRFToken":  s.csrfFromContext(r.Context()),
+		"Notice":     collectionsNotice(r.URL.Query().Get("notice")),
+		"Collection": c,
+		"Items":      items,
+		"Truncated":  total > len(items),
+	}
+	if err := s.renderer.Render(w, "collection", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICreateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/collections?notice=rate_limited", http.StatusFound)
+		return
+	}
+	title, description := r.PostFormValue("title"), r.PostFormValue("description")
+	t, d, err := collectionFields(&title, &description)
+	if err != nil {
+		http.Redirect(w, r, "/ui/collections?notice=invalid", http.StatusFound)
+		return
+	}
+	c, err := s.store.CreateCollection(r.Context(), user.ID, *t, *d)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/collections/"+c.ID+"?notice=created", http.StatusFound)
+}
+
+func (s *Server) handleUIUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	back := "/ui/collections/" + id + "?notice="
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, back+"rate_limited", http.StatusFound)
+		return
+	}
+	title, description := r.PostFormValue("title"), r.PostFormValue("description")
+	t, d, err := collectionFields(&title, &description)
+	if err != nil {
+		http.Redirect(w, r, back+"invalid", http.StatusFound)
+		return
+	}
+	if _, err := s.store.UpdateCollection(r.Context(), user.ID, id, t, d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, back+"updated", http.StatusFound)
+}
+
+func (s *Server) handleUIDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/collections?notice=rate_limited", http.StatusFound)
+		return
+	}
+	if err := s.store.DeleteCollection(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/collections?notice=deleted", http.StatusFound)
+}
+
+// handleUIAddItemToCollection adds the item from its detail page.
+func (s *Server) handleUIAddItemToCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	itemID := chi.URLParam(r, "id")
+	back := "/ui/items/" + itemID + "?notice="
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, back+"rate_limited", http.StatusFound)
+		return
+	}
+	collectionID := r.PostFormValue("collection_id")
+	if collectionID == "" {
+		http.Redirect(w, r, "/ui/items/"+itemID, http.StatusFound)
+		return
+	}
+	if _, err := s.store.AddCollectionItems(r.Context(), user.ID, collectionID, []string{itemID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, back+"collected", http.StatusFound)
+}
+
+func collectionsNotice(state string) string {
+	switch state {
+	case "created":
+		return "Collection created. Add items from their detail pages."
+	case "updated":
+		return "Collection updated."
+	case "deleted":
+		return "Collection deleted. Its items were not changed."
+	case "invalid":
+		return "A title is required (200 characters max); descriptions are limited to 2000 characters."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
@@ -5,4 +5,5 @@ type ctxKey int
 const (
 	requestIDKey ctxKey = iota
 	csrfKey
+	workspaceKey
 )
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// extensionAccessTTL is short because refreshing is cheap; a leaked access token
+	// that was not denylisted stays valid at most this long.
+	extensionAccessTTL = time.Hour
+	// extensionRefreshTTL is extended on every refresh, so only installs left unused
+	// this long are signed out.
+	extensionRefreshTTL = 60 * 24 * time.Hour
+
+	maxDeviceNameLength = 100
+)
+
+// deviceName trims the name the extension reports for itself.
+func deviceName(raw string) string {
+	name := strings.Join(strings.Fields(raw), " ")
+	if name == "" {
+		return "Browser extension"
+	}
+	for len(name) > maxDeviceNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// writeExtensionTokens issues an access token for the device and answers with it and the
+// device's current refresh token.
+func (s *Server) writeExtensionTokens(w http.ResponseWriter, r *http.Request, userID, deviceID, refreshToken string) {
+	token, claims, err := auth.IssueJWT(s.cfg.JWTSecret, userID, deviceID, extensionAccessTTL)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_error"})
+		return
+	}
+	if err := s.store.SetDeviceAccessToken(r.Context(), deviceID, claims.ID, claims.ExpiresAt); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token":              token,
+		"expires_in":         int64(extensionAccessTTL.Seconds()),
+		"refresh_token":      refreshToken,
+		"refresh_expires_in": int64(extensionRefreshTTL.Seconds()),
+	})
+}
+
+// handleExtensionRefresh trades a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working; presenting it again signs the device out.
+func (s *Server) handleExtensionRefresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	next, err := s.randomString(32)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_error"})
+		return
+	}
+	userID, deviceID, err := s.store.RotateRefreshToken(r.Context(), auth.HashToken(req.RefreshToken), auth.HashToken(next), extensionRefreshTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRefreshTokenReused):
+			s.logger.Warn("auth.refresh_token_reused", slog.String("request_id", s.requestID(r.Context())))
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant"})
+		case errors.Is(err, pgx.ErrNoRows):
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		}
+		return
+	}
+	s.writeExtensionTokens(w, r, userID, deviceID, next)
+}
+
+// handleExtensionRevoke signs out the device holding the refresh token. Like OAuth token
+// revocation it succeeds for unknown tokens.
+func (s *Server) handleExtensionRevoke(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := s.store.RevokeRefreshToken(r.Context(), auth.HashToken(req.RefreshToken)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUIRevokeExtensionDevice(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if err := s.store.RevokeExtensionDevice(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=device_revoked", http.StatusFound)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/urlnorm"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxTitleLength   = 500
+	maxExcerptLength = 2000
+	maxNoteLength    = 10000
+)
+
+var (
+	errEditEmpty   = errors.New("empty_edit")
+	errEditTooLong = errors.New("too_long")
+)
+
+// handleUpdateItem edits the user-controlled fields of an item. An empty title or
+// excerpt reverts to the fetched value.
+func (s *Server) handleUpdateItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	var req struct {
+		Title   *string `json:"title"`
+		Excerpt *string `json:"excerpt"`
+		Note    *string `json:"note"`
+		URL     *string `json:"url"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	edit, err := itemEdit(req.Title, req.Excerpt, req.Note, req.URL)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := s.updateItem(r, user.ID, id, edit); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		case errors.Is(err, store.ErrItemExists):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "item_exists"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		}
+		return
+	}
+	item, err := s.store.GetItemDetail(r.Context(), user.ID, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// handleUIUpdateItem saves the detail page edit form. Only fields that differ from the
+// stored item are applied, so an untouched title does not become an override.
+func (s *Server) handleUIUpdateItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	back := "/ui/items/" + id + "?notice="
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, back+"rate_limited", http.StatusFound)
+		return
+	}
+	item, err := s.store.GetItemDetail(r.Context(), user.ID, id)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	changed := func(name, current string) *string {
+		v := r.PostFormValue(name)
+		if strings.TrimSpace(v) == current {
+			return nil
+		}
+		return &v
+	}
+	title := changed("title", item.Title)
+	if title != nil && *title == "" && !item.TitleEdited {
+		title = nil
+	}
+	excerpt := changed("excerpt", item.Excerpt)
+	if excerpt != nil && *excerpt == "" && !item.ExcerptEdited {
+		excerpt = nil
+	}
+	edit, err := itemEdit(title, excerpt, changed("note", item.Note), changed("url", item.URL))
+	if err == nil {
+		err = s.updateItem(r, user.ID, id, edit)
+	}
+	switch {
+	case err == nil:
+		http.Redirect(w, r, back+"saved", http.StatusFound)
+	case errors.Is(err, errEditEmpty):
+		http.Redirect(w, r, "/ui/items/"+id, http.StatusFound)
+	case errors.Is(err, errInvalidURL):
+		http.Redirect(w, r, back+"invalid_url", http.StatusFound)
+	case errors.Is(err, errEditTooLong):
+		http.Redirect(w, r, back+"too_long", http.StatusFound)
+	case errors.Is(err, store.ErrItemExists):
+		http.Redirect(w, r, back+"exists", http.StatusFound)
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, "db error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) updateItem(r *http.Request, userID, itemID string, edit store.ItemEdit) error {
+	if err := s.store.UpdateItem(r.Context(), userID, itemID, edit); err != nil {
+		return err
+	}
+	s.logger.Info("items.update",
+		slog.String("item_id", itemID),
+		slog.Bool("title", edit.Title != nil),
+		slog.Bool("excerpt", edit.Excerpt != nil),
+		slog.Bool("note", edit.Note != nil),
+		slog.Bool("url", edit.CanonicalHash != ""),
+		slog.String("request_id", s.requestID(r.Context())))
+	return nil
+}
+
+// itemEdit validates the requested changes. Nil arguments leave the field unchanged.
+func itemEdit(title, excerpt, note, rawURL *string) (store.ItemEdit, error) {
+	if title == nil && excerpt == nil && note == nil && rawURL == nil {
+		return store.ItemEdit{}, errEditEmpty
+	}
+	var edit store.ItemEdit
+	trimmed := func(v *string, limit int) (*string, error) {
+		if v == nil {
+			return nil, nil
+		}
+		t := strings.TrimSpace(*v)
+		if utf8.RuneCountInString(t) > limit {
+			return nil, errEditTooLong
+		}
+		return &t, nil
+	}
+	var err error
+	if edit.Title, err = trimmed(title, maxTitleLength); err != nil {
+		return store.ItemEdit{}, err
+	}
+	if edit.Excerpt, err = trimmed(excerpt, maxExcerptLength); err != nil {
+		return store.ItemEdit{}, err
+	}
+	if edit.Note, err = trimmed(note, maxNoteLength); err != nil {
+		return store.ItemEdit{}, err
+	}
+	if rawURL != nil {
+		edit.URL = strings.TrimSpace(*rawURL)
+		u, err := url.Parse(edit.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return store.ItemEdit{}, errInvalidURL
+		}
+		if edit.CanonicalURL, edit.CanonicalHash, err = urlnorm.Canonicalize(edit.URL); err != nil {
+			return store.ItemEdit{}, errInvalidURL
+		}
+	}
+	return edit, nil
+}
+
+func itemNotice(state string) string {
+	switch state {
+	case "saved":
+		return "Changes saved."
+	case "exists":
+		return "Another saved item already has that URL."
+	case "invalid_url":
+		return "Enter a valid http or https URL."
+	case "too_long":
+		return "The title, excerpt or note is too long."
+	case "collected":
+		return "Added to the collection."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+)
+
+// itemExporter writes a library export one item at a time.
+type itemExporter interface {
+	begin() error
+	write(store.ExportItem) error
+	end() error
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	s.serveExport(w, r, format)
+}
+
+// handleExportMarkdown serves a zip of one Markdown file per item, laid out so the
+// archive can be unpacked straight into an Obsidian vault.
+func (s *Server) handleExportMarkdown(w http.ResponseWriter, r *http.Request) {
+	s.serveExport(w, r, "markdown")
+}
+
+func (s *Server) serveExport(w http.ResponseWriter, r *http.Request, format string) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	includeContent := parseBool(r.URL.Query().Get("include_content"))
+
+	var exp itemExporter
+	var contentType string
+	ext := format
+	switch format {
+	case "json":
+		exp, contentType = &jsonExporter{w: w}, "application/json; charset=utf-8"
+	case "csv":
+		exp, contentType = newCSVExporter(w, includeContent), "text/csv; charset=utf-8"
+	case "html":
+		exp, contentType = &bookmarksExporter{w: w}, "text/html; charset=utf-8"
+	case "markdown":
+		exp, contentType, ext = newMarkdownExporter(w, includeContent), "application/zip", "zip"
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_format"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	started := false
+	count := 0
+	err := s.store.ExportItems(r.Context(), user.ID, includeContent, func(it store.ExportItem) error {
+		if !started {
+			started = true
+			writeExportHeaders(w, contentType, ext)
+			if err := exp.begin(); err != nil {
+				return err
+			}
+		}
+		count++
+		return exp.write(it)
+	})
+	if err == nil && !started {
+		started = true
+		writeExportHeaders(w, contentType, ext)
+		err = exp.begin()
+	}
+	if err == nil {
+		err = exp.end()
+	}
+	if err != nil {
+		s.logger.Error("export.failed",
+			slog.String("user_id", user.ID),
+			slog.String("format", format),
+			slog.Int("items", count),
+			slog.String("error", err.Error()),
+			slog.String("request_id", s.requestID(r.Context())))
+		if !started {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		}
+		// Once the body has started there is no way to report the error; the client
+		// sees a truncated file.
+		return
+	}
+	s.logger.Info("export.done",
+		slog.String("user_id", user.ID),
+		slog.String("format", format),
+		slog.Int("items", count),
+		slog.String("request_id", s.requestID(r.Context())))
+}
+
+func writeExportHeaders(w http.ResponseWriter, contentType, ext string) {
+	filename := fmt.Sprintf("altpocket-export-%s.%s", time.Now().UTC().Format("20060102"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// jsonExporter writes {"items":[...]} without holding the array in memory.
+type jsonExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExporter) begin() error {
+	_, err := io.WriteString(e.w, `{"items":[`)
+	return err
+}
+
+func (e *jsonExporter) write(it store.ExportItem) error {
+	if it.Tags == nil {
+		it.Tags = []string{}
+	}
+	if it.Highlights == nil {
+		it.Highlights = []store.ExportHighlight{}
+	}
+	b, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExporter) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// csvExporter uses the same "|" tag separator as Pocket's CSV export.
+type csvExporter struct {
+	w              *csv.Writer
+	includeContent bool
+}
+
+func newCSVExporter(w io.Writer, includeContent bool) *csvExporter {
+	return &csvExporter{w: csv.NewWriter(w), includeContent: includeContent}
+}
+
+func (e *csvExporter) begin() error {
+	header := []string{"url", "canonical_url", "title", "excerpt", "tags", "created_at", "fetch_status", "state", "favorite"}
+	if e.includeContent {
+		header = append(header, "content_full")
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvExporter) write(it store.ExportItem) error {
+	record := []string{
+		it.URL,
+		it.CanonicalURL,
+		it.Title,
+		it.Excerpt,
+		strings.Join(it.Tags, "|"),
+		it.CreatedAt.UTC().Format(time.RFC3339),
+		it.FetchStatus,
+		it.State,
+		strconv.FormatBool(it.Favorite),
+	}
+	if e.includeContent {
+		record = append(record, it.ContentFull)
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// bookmarksExporter writes the Netscape bookmark file format that browsers and most
+// read-later services import. Article text is not included.
+type bookmarksExporter struct {
+	w io.Writer
+}
+
+func (e *bookmarksExporter) begin() error {
+	_, err := io.WriteString(e.w, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>altpocket export</TITLE>
+<H1>altpocket export</H1>
+<DL><p>
+`)
+	return err
+}
+
+func (e *bookmarksExporter) write(it store.ExportItem) error {
+	title := it.Title
+	if title == "" {
+		title = it.URL
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<DT><A HREF="%s" ADD_DATE="%d"`, html.EscapeString(it.URL), it.CreatedAt.Unix())
+	if len(it.Tags) > 0 {
+		fmt.Fprintf(&b, ` TAGS="%s"`, html.EscapeString(strings.Join(it.Tags, ",")))
+	}
+	if it.State == store.ItemStateArchived {
+		b.WriteString(` READ="1"`)
+	}
+	fmt.Fprintf(&b, ">%s</A>\n", html.EscapeString(title))
+	if it.Excerpt != "" {
+		fmt.Fprintf(&b, "<DD>%s\n", html.EscapeString(it.Excerpt))
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *bookmarksExporter) end() error {
+	_, err := io.WriteString(e.w, "</DL><p>\n")
+	return err
+}
+
+// markdownExporter writes a zip with one Markdown file per item: YAML front matter, the
+// item note, highlights as block quotes and optionally the article text.
+type markdownExporter struct {
+	zw             *zip.Writer
+	includeContent bool
+	// names counts the files written per base name; used holds every emitted file name,
+	// lowercased, since vaults often live on case-insensitive file systems.
+	names map[string]int
+	used  map[string]bool
+}
+
+func newMarkdownExporter(w io.Writer, includeContent bool) *markdownExporter {
+	return &markdownExporter{zw: zip.NewWriter(w), includeContent: includeContent, names: map[string]int{}, used: map[string]bool{}}
+}
+
+func (e *markdownExporter) begin() error {
+	return nil
+}
+
+func (e *markdownExporter) write(it store.ExportItem) error {
+	f, err := e.zw.CreateHeader(&zip.FileHeader{
+		Name:     e.filename(it),
+		Method:   zip.Deflate,
+		Modified: it.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, markdownDocument(it, e.includeContent))
+	return err
+}
+
+func (e *markdownExporter) end() error {
+	return e.zw.Close()
+}
+
+// filename derives a unique file name from the title, numbering repeats the way file
+// managers do. A number is skipped when another title already produced that name, as
+// "A (2)" does.
+func (e *markdownExporter) filename(it store.ExportItem) string {
+	base := markdownFilename(it.Title)
+	if base == "" {
+		base = markdownFilename(strings.TrimPrefix(strings.TrimPrefix(it.URL, "https://"), "http://"))
+	}
+	if base == "" {
+		base = "item"
+	}
+	key := strings.ToLower(base)
+	name := base + ".md"
+	// n is the last number given to this base name; the bare name counts as 1.
+	n := e.names[key]
+	if n == 0 && !e.used[strings.ToLower(name)] {
+		n = 1
+	} else {
+		n = max(n, 1)
+		for {
+			n++
+			name = fmt.Sprintf("%s (%d).md", base, n)
+			if !e.used[strings.ToLower(name)] {
+				break
+			}
+		}
+	}
+	e.names[key] = n
+	e.used[strings.ToLower(name)] = true
+	return name
+}
+
+// maxMarkdownFilename keeps names well under common 255-byte file name limits.
+const maxMarkdownFilename = 80
+
+// markdownFilename replaces characters that are invalid in file names on common systems,
+// or that Obsidian treats as link syntax, with single spaces.
+func markdownFilename(title string) string {
+	var b strings.Builder
+	n := 0
+	space := false
+	for _, r := range title {
+		if n >= maxMarkdownFilename {
+			break
+		}
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|#^[]`, r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			n++
+			space = false
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return strings.TrimRight(b.String(), ". ")
+}
+
+func markdownDocument(it store.ExportItem, includeContent bool) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", yamlString(it.Title))
+	fmt.Fprintf(&b, "url: %s\n", yamlString(it.URL))
+	fmt.Fprintf(&b, "canonical_url: %s\n", yamlString(it.CanonicalURL))
+	tags := make([]string, 0, len(it.Tags))
+	for _, t := range it.Tags {
+		tags = append(tags, yamlString(t))
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	fmt.Fprintf(&b, "created_at: %s\n", it.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "state: %s\n", it.State)
+	fmt.Fprintf(&b, "favorite: %t\n", it.Favorite)
+	b.WriteString("---\n\n")
+
+	title := it.Title
+	if title == "" {
+		title = it.URL
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if it.Excerpt != "" {
+		fmt.Fprintf(&b, "%s\n\n", it.Excerpt)
+	}
+	if it.Note != "" {
+		fmt.Fprintf(&b, "## Note\n\n%s\n\n", it.Note)
+	}
+	if len(it.Highlights) > 0 {
+		b.WriteString("## Highlights\n\n")
+		for _, h := range it.Highlights {
+			b.WriteString(markdownQuote(h.Quote))
+			b.WriteString("\n")
+			if h.Note != "" {
+				fmt.Fprintf(&b, "%s\n\n", h.Note)
+			}
+		}
+	}
+	if includeContent && it.ContentFull != "" {
+		fmt.Fprintf(&b, "## Content\n\n%s\n", it.ContentFull)
+	}
+	return b.String()
+}
+
+func markdownQuote(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			b.WriteString(">\n")
+			continue
+		}
+		b.WriteString("> " + line + "\n")
+	}
+	return b.String()
+}
+
+// yamlString quotes s as a YAML double-quoted scalar. JSON string syntax is a subset of
+// it, so the JSON encoder does the escaping.
+func yamlString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
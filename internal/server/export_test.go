@@ -0,0 +1,158 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"altpocket/internal/store"
+)
+
+func exportFixture() []store.ExportItem {
+	return []store.ExportItem{
+		{ID: "1", URL: "https://example.com/a", Title: `A "quoted" <title>`, Tags: []string{"go", "web"}, CreatedAt: time.Unix(1700000000, 0), State: store.ItemStateArchived, ContentFull: "body"},
+		{ID: "2", URL: "https://example.com/b", CreatedAt: time.Unix(1700000100, 0), State: store.ItemStateUnread},
+	}
+}
+
+func runExporter(t *testing.T, exp itemExporter, items []store.ExportItem) {
+	t.Helper()
+	if err := exp.begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for _, it := range items {
+		if err := exp.write(it); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := exp.end(); err != nil {
+		t.Fatalf("end: %v", err)
+	}
+}
+
+func TestJSONExporterWritesValidDocument(t *testing.T) {
+	for _, items := range [][]store.ExportItem{nil, exportFixture()} {
+		var buf bytes.Buffer
+		runExporter(t, &jsonExporter{w: &buf}, items)
+		var doc struct {
+			Items []map[string]any `json:"items"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid json %q: %v", buf.String(), err)
+		}
+		if len(doc.Items) != len(items) {
+			t.Fatalf("expected %d items, got %d", len(items), len(doc.Items))
+		}
+	}
+}
+
+func TestCSVExporterIncludesContentOnRequest(t *testing.T) {
+	var buf bytes.Buffer
+	runExporter(t, newCSVExporter(&buf, true), exportFixture())
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	if len(records) != 3 || records[0][len(records[0])-1] != "content_full" {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+	if records[1][4] != "go|web" || records[1][9] != "body" {
+		t.Fatalf("unexpected row: %#v", records[1])
+	}
+
+	buf.Reset()
+	runExporter(t, newCSVExporter(&buf, false), exportFixture())
+	if strings.Contains(buf.String(), "content_full") {
+		t.Fatalf("content column should be omitted")
+	}
+}
+
+func TestBookmarksExporterEscapesAndMarksRead(t *testing.T) {
+	var buf bytes.Buffer
+	runExporter(t, &bookmarksExporter{w: &buf}, exportFixture())
+	out := buf.String()
+	if !strings.HasPrefix(out, "<!DOCTYPE NETSCAPE-Bookmark-file-1>") {
+		t.Fatalf("missing doctype: %q", out)
+	}
+	if !strings.Contains(out, `ADD_DATE="1700000000" TAGS="go,web" READ="1">A &#34;quoted&#34; &lt;title&gt;</A>`) {
+		t.Fatalf("unexpected first bookmark: %q", out)
+	}
+	if !strings.Contains(out, `>https://example.com/b</A>`) {
+		t.Fatalf("untitled item should fall back to its url: %q", out)
+	}
+}
+
+func TestMarkdownExporterWritesOneFilePerItem(t *testing.T) {
+	items := exportFixture()
+	items[0].Note = "worth a reread"
+	items[0].Highlights = []store.ExportHighlight{{Quote: "first line\nsecond line", Note: "key point"}}
+	items = append(items, store.ExportItem{ID: "3", URL: "https://example.com/c", Title: items[0].Title, CreatedAt: time.Unix(1700000200, 0)})
+
+	var buf bytes.Buffer
+	runExporter(t, newMarkdownExporter(&buf, true), items)
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{"A quoted title.md", "example.com b.md", "A quoted title (2).md"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected file names: %q", names)
+	}
+
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	body, _ := io.ReadAll(rc)
+	rc.Close()
+	doc := string(body)
+	for _, want := range []string{
+		"---\ntitle: \"A \\\"quoted\\\" <title>\"\n",
+		"url: \"https://example.com/a\"\n",
+		"tags: [\"go\", \"web\"]\n",
+		"created_at: 2023-11-14T22:13:20Z\n",
+		"## Note\n\nworth a reread\n",
+		"> first line\n> second line\n\nkey point\n",
+		"## Content\n\nbody\n",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Fatalf("missing %q in:\n%s", want, doc)
+		}
+	}
+}
+
+func TestMarkdownExporterSkipsNamesAlreadyUsed(t *testing.T) {
+	e := newMarkdownExporter(io.Discard, false)
+	var got []string
+	for _, title := range []string{"A", "a", "A (2)", "A", "A (3)", "Note (2)", "Note", "Note"} {
+		got = append(got, e.filename(store.ExportItem{Title: title}))
+	}
+	want := []string{"A.md", "a (2).md", "A (2) (2).md", "A (3).md", "A (3) (2).md", "Note (2).md", "Note.md", "Note (3).md"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("file names = %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownFilename(t *testing.T) {
+	cases := map[string]string{
+		"Go: tips & [tricks]?": "Go tips & tricks",
+		"  日本語の記事  ":           "日本語の記事",
+		"../../etc/passwd":     ".. .. etc passwd",
+		"ends with dot.":       "ends with dot",
+		"":                     "",
+	}
+	for in, want := range cases {
+		if got := markdownFilename(in); got != want {
+			t.Fatalf("markdownFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
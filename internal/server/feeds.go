@@ -0,0 +1,444 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/tag"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultFeedLimit = 50
+	maxFeedLimit     = 100
+)
+
+// feedEntry is one item as written to every feed format.
+type feedEntry struct {
+	ID        string
+	Title     string
+	URL       string
+	Excerpt   string
+	Content   string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+}
+
+type feedMeta struct {
+	Title   string
+	Author  string
+	SelfURL string
+	HomeURL string
+	Updated time.Time
+}
+
+// handleFeed serves the user's items as Atom, RSS 2.0 or JSON Feed. Feed readers cannot
+// log in, so the secret token in the path authenticates the request.
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	var write func(io.Writer, feedMeta, []feedEntry) error
+	var contentType string
+	switch format {
+	case "atom":
+		write, contentType = writeAtomFeed, "application/atom+xml; charset=utf-8"
+	case "rss":
+		write, contentType = writeRSSFeed, "application/rss+xml; charset=utf-8"
+	case "json":
+		write, contentType = writeJSONFeed, "application/feed+json; charset=utf-8"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := s.store.GetUserByFeedToken(r.Context(), auth.HashToken(chi.URLParam(r, "token")))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := store.ItemFilter{
+		Tag:        tag.Normalize(query.Get("tag")),
+		State:      stateFilter(query.Get("state")),
+		Favorite:   favoriteFilter(query.Get("favorite")),
+		Collection: query.Get("collection"),
+		Workspace:  query.Get("workspace"),
+		Sort:       "newest",
+	}
+	includeContent := parseBool(query.Get("include_content"))
+	limit := feedLimit(query.Get("limit"))
+
+	rows, _, err := s.store.ListItemsRange(r.Context(), user.ID, filter, 0, limit)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	var contents map[string]string
+	if includeContent {
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		if contents, err = s.store.ItemContents(r.Context(), ids); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	entries := make([]feedEntry, 0, len(rows))
+	for _, row := range rows {
+		e := feedEntry{
+			ID:        row.ID,
+			Title:     row.Title,
+			URL:       row.URL,
+			Excerpt:   row.Excerpt,
+			Content:   contents[row.ID],
+			Published: row.CreatedAt.UTC(),
+			Updated:   row.UpdatedAt.UTC(),
+		}
+		if e.Title == "" {
+			e.Title = row.CanonicalURL
+		}
+		for _, t := range row.Tags {
+			e.Tags = append(e.Tags, t.Name)
+		}
+		entries = append(entries, e)
+	}
+
+	base := strings.TrimRight(s.cfg.PublicBaseURL, "/")
+	meta := feedMeta{
+		Title:   feedTitle(user.Name, filter),
+		Author:  user.Name,
+		SelfURL: base + r.URL.RequestURI(),
+		HomeURL: base + "/ui/items",
+		Updated: feedUpdated(entries),
+	}
+
+	etag := feedETag(format, r.URL.RawQuery, entries)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if !meta.Updated.IsZero() {
+		w.Header().Set("Last-Modified", meta.Updated.Format(http.TimeFormat))
+	}
+	if feedNotModified(r, etag, meta.Updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := write(w, meta, entries); err != nil {
+		s.logger.Error("feed_write_failed", "error", err, "request_id", s.requestID(r.Context()))
+	}
+}
+
+func feedLimit(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return defaultFeedLimit
+	}
+	return min(n, maxFeedLimit)
+}
+
+func feedTitle(userName string, filter store.ItemFilter) string {
+	title := "altpocket"
+	if userName != "" {
+		title += " – " + userName
+	}
+	var parts []string
+	if filter.Tag != "" {
+		parts = append(parts, "#"+filter.Tag)
+	}
+	if filter.State != "" {
+		parts = append(parts, filter.State)
+	}
+	if filter.Favorite != nil && *filter.Favorite {
+		parts = append(parts, "favorites")
+	}
+	if len(parts) > 0 {
+		title += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return title
+}
+
+// feedUpdated is the latest change among the entries, at the one-second precision of
+// HTTP dates.
+func feedUpdated(entries []feedEntry) time.Time {
+	var latest time.Time
+	for _, e := range entries {
+		if e.Updated.After(latest) {
+			latest = e.Updated
+		}
+	}
+	return latest.Truncate(time.Second)
+}
+
+// feedETag fingerprints everything the feed body depends on, so removed or reordered
+// items change it even when no remaining item was updated.
+func feedETag(format, rawQuery string, entries []feedEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", format, rawQuery)
+	for _, e := range entries {
+		fmt.Fprintf(h, "%s %d %d\n", e.ID, e.Updated.UnixNano(), len(e.Content))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// feedNotModified applies the conditional request headers. If-None-Match takes
+// precedence; If-Modified-Since is only consulted without it.
+func feedNotModified(r *http.Request, etag string, updated time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !updated.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !updated.After(since)
+	}
+	return false
+}
+
+func feedEntryID(id string) string {
+	return "urn:uuid:" + id
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func writeAtomFeed(w io.Writer, meta feedMeta, entries []feedEntry) error {
+	updated := meta.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	author := meta.Author
+	if author == "" {
+		author = "altpocket"
+	}
+	feed := atomFeed{
+		ID:      meta.SelfURL,
+		Title:   meta.Title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  author,
+		Links:   []atomLink{{Rel: "self", Href: meta.SelfURL}, {Rel: "alternate", Href: meta.HomeURL}},
+	}
+	for _, e := range entries {
+		ae := atomEntry{
+			ID:        feedEntryID(e.ID),
+			Title:     e.Title,
+			Links:     []atomLink{{Rel: "alternate", Href: e.URL}},
+			Published: e.Published.Format(time.RFC3339),
+			Updated:   e.Updated.Format(time.RFC3339),
+		}
+		for _, t := range e.Tags {
+			ae.Categories = append(ae.Categories, atomCategory{Term: t})
+		}
+		if e.Excerpt != "" {
+			ae.Summary = &atomText{Type: "text", Body: e.Excerpt}
+		}
+		if e.Content != "" {
+			ae.Content = &atomText{Type: "text", Body: e.Content}
+		}
+		feed.Entries = append(feed.Entries, ae)
+	}
+	return writeXML(w, feed)
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description,omitempty"`
+	Content     string   `xml:"content:encoded,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName   xml.Name `xml:"rss"`
+	Version   string   `xml:"version,attr"`
+	ContentNS string   `xml:"xmlns:content,attr"`
+	Channel   struct {
+		Title         string    `xml:"title"`
+		Link          string    `xml:"link"`
+		Description   string    `xml:"description"`
+		LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+		Items         []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+func writeRSSFeed(w io.Writer, meta feedMeta, entries []feedEntry) error {
+	feed := rssFeed{Version: "2.0", ContentNS: "http://purl.org/rss/1.0/modules/content/"}
+	feed.Channel.Title = meta.Title
+	feed.Channel.Link = meta.HomeURL
+	feed.Channel.Description = "Items saved to altpocket"
+	if !meta.Updated.IsZero() {
+		feed.Channel.LastBuildDate = meta.Updated.Format(time.RFC1123Z)
+	}
+	for _, e := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			GUID:        rssGUID{IsPermaLink: "false", Value: feedEntryID(e.ID)},
+			PubDate:     e.Published.Format(time.RFC1123Z),
+			Categories:  e.Tags,
+			Description: e.Excerpt,
+			Content:     textToHTML(e.Content),
+		})
+	}
+	return writeXML(w, feed)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// textToHTML turns extracted plain text into escaped paragraphs for content:encoded,
+// which readers treat as HTML.
+func textToHTML(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(text, "\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			b.WriteString("<p>")
+			b.WriteString(html.EscapeString(para))
+			b.WriteString("</p>")
+		}
+	}
+	return b.String()
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	Summary       string   `json:"summary,omitempty"`
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func writeJSONFeed(w io.Writer, meta feedMeta, entries []feedEntry) error {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       meta.Title,
+		HomePageURL: meta.HomeURL,
+		FeedURL:     meta.SelfURL,
+		Items:       make([]jsonFeedItem, 0, len(entries)),
+	}
+	if meta.Author != "" {
+		feed.Authors = []jsonAuthor{{Name: meta.Author}}
+	}
+	for _, e := range entries {
+		// JSON Feed requires content; the excerpt stands in when full text is not requested.
+		content := e.Content
+		if content == "" {
+			content = e.Excerpt
+		}
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            feedEntryID(e.ID),
+			URL:           e.URL,
+			Title:         e.Title,
+			Summary:       e.Excerpt,
+			ContentText:   content,
+			DatePublished: e.Published.Format(time.RFC3339),
+			DateModified:  e.Updated.Format(time.RFC3339),
+			Tags:          e.Tags,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+// feedURLs lists the user's feed links for the settings page.
+func (s *Server) feedURLs(token string) map[string]string {
+	base := strings.TrimRight(s.cfg.PublicBaseURL, "/") + "/feeds/" + url.PathEscape(token) + "/"
+	return map[string]string{"Atom": base + "atom", "RSS": base + "rss", "JSON": base + "json"}
+}
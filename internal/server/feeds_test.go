@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func feedFixture() (feedMeta, []feedEntry) {
+	updated := time.Date(2024, 5, 2, 10, 30, 15, 500, time.UTC)
+	entries := []feedEntry{
+		{ID: "a1", Title: "Go & <generics>", URL: "https://example.com/a?x=1&y=2", Excerpt: "Short", Content: "First line\n\nSecond <b>line</b>", Tags: []string{"go"}, Published: updated.Add(-time.Hour), Updated: updated},
+		{ID: "b2", Title: "Plain", URL: "https://example.com/b", Published: updated.Add(-2 * time.Hour), Updated: updated.Add(-time.Minute)},
+	}
+	meta := feedMeta{Title: "altpocket – Ada", Author: "Ada", SelfURL: "https://pocket.example/feeds/t/atom", HomeURL: "https://pocket.example/ui/items", Updated: feedUpdated(entries)}
+	return meta, entries
+}
+
+func TestAtomFeedIsWellFormed(t *testing.T) {
+	meta, entries := feedFixture()
+	var buf bytes.Buffer
+	if err := writeAtomFeed(&buf, meta, entries); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid atom: %v\n%s", err, buf.String())
+	}
+	if doc.Updated != "2024-05-02T10:30:15Z" || len(doc.Entries) != 2 {
+		t.Fatalf("unexpected feed: %+v", doc)
+	}
+	if doc.Entries[0].ID != "urn:uuid:a1" || doc.Entries[0].Title != "Go & <generics>" || !strings.Contains(doc.Entries[0].Content, "Second <b>line</b>") {
+		t.Fatalf("unexpected entry: %+v", doc.Entries[0])
+	}
+	if strings.Count(buf.String(), "<content") != 1 {
+		t.Fatalf("entries without content should omit it:\n%s", buf.String())
+	}
+}
+
+func TestRSSFeedEscapesContentAsHTML(t *testing.T) {
+	meta, entries := feedFixture()
+	var buf bytes.Buffer
+	if err := writeRSSFeed(&buf, meta, entries); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Items   []struct {
+			GUID    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid rss: %v\n%s", err, buf.String())
+	}
+	if doc.Version != "2.0" || len(doc.Items) != 2 || doc.Items[0].GUID != "urn:uuid:a1" {
+		t.Fatalf("unexpected feed: %+v", doc)
+	}
+	if doc.Items[0].Content != "<p>First line</p><p>Second &lt;b&gt;line&lt;/b&gt;</p>" {
+		t.Fatalf("unexpected content: %q", doc.Items[0].Content)
+	}
+	if _, err := time.Parse(time.RFC1123Z, doc.Items[0].PubDate); err != nil {
+		t.Fatalf("unexpected pubDate %q: %v", doc.Items[0].PubDate, err)
+	}
+}
+
+func TestJSONFeedFallsBackToExcerpt(t *testing.T) {
+	meta, entries := feedFixture()
+	entries[0].Content = ""
+	var buf bytes.Buffer
+	if err := writeJSONFeed(&buf, meta, entries); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var doc struct {
+		Version string `json:"version"`
+		Items   []struct {
+			ID          string   `json:"id"`
+			ContentText string   `json:"content_text"`
+			Tags        []string `json:"tags"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid json feed: %v", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || len(doc.Items) != 2 {
+		t.Fatalf("unexpected feed: %+v", doc)
+	}
+	if doc.Items[0].ContentText != "Short" || len(doc.Items[0].Tags) != 1 {
+		t.Fatalf("unexpected item: %+v", doc.Items[0])
+	}
+}
+
+func TestFeedNotModified(t *testing.T) {
+	_, entries := feedFixture()
+	etag := feedETag("atom", "tag=go", entries)
+	updated := feedUpdated(entries)
+
+	req := httptest.NewRequest("GET", "/feeds/t/atom", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	if !feedNotModified(req, etag, updated) {
+		t.Fatalf("expected a matching etag to be not modified")
+	}
+	// If-None-Match wins over a matching If-Modified-Since.
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", updated.Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	if feedNotModified(req, etag, updated) {
+		t.Fatalf("expected a different etag to be modified")
+	}
+	req.Header.Del("If-None-Match")
+	if !feedNotModified(req, etag, updated) {
+		t.Fatalf("expected an up-to-date If-Modified-Since to be not modified")
+	}
+	req.Header.Set("If-Modified-Since", updated.Add(-time.Second).Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	if feedNotModified(req, etag, updated) {
+		t.Fatalf("expected an older If-Modified-Since to be modified")
+	}
+}
+
+func TestFeedETagChangesWithEntries(t *testing.T) {
+	_, entries := feedFixture()
+	base := feedETag("atom", "", entries)
+	if feedETag("atom", "", entries) != base {
+		t.Fatalf("etag should be stable")
+	}
+	if feedETag("rss", "", entries) == base || feedETag("atom", "tag=go", entries) == base {
+		t.Fatalf("etag should depend on format and query")
+	}
+	if feedETag("atom", "", entries[1:]) == base {
+		t.Fatalf("etag should change when an item drops out")
+	}
+}
+
+func TestFeedLimit(t *testing.T) {
+	if feedLimit("") != defaultFeedLimit || feedLimit("-3") != defaultFeedLimit || feedLimit("x") != defaultFeedLimit {
+		t.Fatalf("expected default limit")
+	}
+	if feedLimit("10") != 10 || feedLimit("1000") != maxFeedLimit {
+		t.Fatalf("unexpected limits")
+	}
+}
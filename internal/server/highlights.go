@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/highlight"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxQuoteLength caps the highlighted text, in code points.
+const maxQuoteLength = 5000
+
+func (s *Server) handleListHighlights(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	highlights, err := s.store.ListHighlights(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeHighlightError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"highlights": highlights})
+}
+
+// handleCreateHighlight stores a highlight for quote. Start is the selection offset in
+// code points and only picks between repeated occurrences of the quote.
+func (s *Server) handleCreateHighlight(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	var req struct {
+		Quote string `json:"quote"`
+		Start int    `json:"start"`
+		Note  string `json:"note"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	quote := strings.TrimSpace(req.Quote)
+	note := strings.TrimSpace(req.Note)
+	if quote == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_quote"})
+		return
+	}
+	if utf8.RuneCountInString(quote) > maxQuoteLength || utf8.RuneCountInString(note) > maxNoteLength {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "too_long"})
+		return
+	}
+
+	itemID := chi.URLParam(r, "id")
+	h, err := s.store.CreateHighlight(r.Context(), user.ID, itemID, quote, note, req.Start)
+	if err != nil {
+		writeHighlightError(w, err)
+		return
+	}
+	s.logger.Info("highlights.create",
+		slog.String("item_id", itemID),
+		slog.String("highlight_id", h.ID),
+		slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusCreated, h)
+}
+
+func (s *Server) handleDeleteHighlight(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.DeleteHighlight(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "highlightID")); err != nil {
+		writeHighlightError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeHighlightError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	case errors.Is(err, store.ErrQuoteNotFound):
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "quote_not_found"})
+	case errors.Is(err, store.ErrHighlightOverlap):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "highlight_overlap"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+	}
+}
+
+// highlightSegments splits the item content into plain and highlighted runs for the
+// detail page.
+func highlightSegments(item store.ItemDetail) []highlight.Segment {
+	spans := make([]highlight.Span, 0, len(item.Highlights))
+	for _, h := range item.Highlights {
+		if h.Anchored {
+			spans = append(spans, highlight.Span{ID: h.ID, Note: h.Note, Start: h.Start, End: h.End})
+		}
+	}
+	return highlight.Segments(item.ContentFull, spans)
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/importer"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxImportBytes bounds uploaded export files; Pocket exports of tens of thousands of
+// items stay well below it.
+const maxImportBytes = 32 << 20
+
+var errImportTooLarge = errors.New("too_large")
+
+func (s *Server) handleCreateImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	filename, data, err := readImportUpload(w, r)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "too_large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	job, err := s.startImport(r.Context(), user.ID, filename, data)
+	if err != nil {
+		if code := importErrorCode(err); code != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) handleListImports(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	jobs, err := s.store.ListImportJobs(r.Context(), user.ID, 20)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"imports": jobs})
+}
+
+func (s *Server) handleGetImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	job, err := s.store.GetImportJob(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleUIImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	jobs, err := s.store.ListImportJobs(r.Context(), user.ID, 20)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	active := false
+	for _, job := range jobs {
+		if job.Active() {
+			active = true
+			break
+		}
+	}
+	data := map[string]interface{}{
+		"Title":     "Import / Export",
+		"User":      user,
+		"CSRFToken": s.csrfFromContext(r.Context()),
+		"Notice":    importNotice(r.URL.Query().Get("notice")),
+		"Jobs":      jobs,
+		"Active":    active,
+	}
+	if err := s.renderer.Render(w, "import", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUIImportSubmit(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	filename, data, err := readImportUpload(w, r)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			http.Redirect(w, r, "/ui/import?notice=too_large", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/ui/import?notice=invalid_file", http.StatusFound)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/import?notice=rate_limited", http.StatusFound)
+		return
+	}
+
+	if _, err := s.startImport(r.Context(), user.ID, filename, data); err != nil {
+		if code := importErrorCode(err); code != "" {
+			http.Redirect(w, r, "/ui/import?notice="+code, http.StatusFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/import?notice=queued", http.StatusFound)
+}
+
+// startImport parses the export and queues it for the worker, which creates the items
+// in batches and enqueues their fetches.
+func (s *Server) startImport(ctx context.Context, userID, filename string, data []byte) (store.ImportJob, error) {
+	res, err := importer.Parse(filename, data)
+	if err != nil {
+		return store.ImportJob{}, err
+	}
+	entries := make([]store.ImportEntry, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		entries = append(entries, store.ImportEntry{
+			URL:           e.URL,
+			CanonicalURL:  e.CanonicalURL,
+			CanonicalHash: e.CanonicalHash,
+			Title:         e.Title,
+			TimeAdded:     e.TimeAdded,
+			Tags:          e.Tags,
+			Archived:      e.Archived,
+		})
+	}
+	job, err := s.store.CreateImportJob(ctx, userID, filename, res.Format, entries, res.Invalid)
+	if err != nil {
+		return store.ImportJob{}, err
+	}
+	s.logger.Info("import.queued",
+		slog.String("job_id", job.ID),
+		slog.String("user_id", userID),
+		slog.String("format", res.Format),
+		slog.Int("entries", len(entries)),
+		slog.Int("invalid", res.Invalid),
+		slog.String("request_id", s.requestID(ctx)))
+	return job, nil
+}
+
+// readImportUpload reads the "file" field of a multipart upload.
+func readImportUpload(w http.ResponseWriter, r *http.Request) (string, []byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return "", nil, errImportTooLarge
+		}
+		return "", nil, err
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxImportBytes {
+		return "", nil, errImportTooLarge
+	}
+	return header.Filename, data, nil
+}
+
+// importErrorCode maps parse failures to client-facing codes; other errors return "".
+func importErrorCode(err error) string {
+	switch {
+	case errors.Is(err, importer.ErrUnknownFormat):
+		return "unsupported_format"
+	case errors.Is(err, importer.ErrNoEntries):
+		return "empty_file"
+	case errors.Is(err, importer.ErrMalformed):
+		return "invalid_file"
+	default:
+		return ""
+	}
+}
+
+func importNotice(state string) string {
+	switch state {
+	case "queued":
+		return "Import started. Items appear in your list as they are processed."
+	case "unsupported_format":
+		return "Unsupported file. Upload ril_export.html or the CSV file from a Pocket export."
+	case "empty_file":
+		return "No saved items were found in that file."
+	case "invalid_file":
+		return "The file could not be read."
+	case "too_large":
+		return "The file is too large (32 MB max)."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
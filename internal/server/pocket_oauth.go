@@ -0,0 +1,305 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Pocket-style OAuth: a registered app obtains a request token with its consumer key,
+// sends the user to /auth/authorize to approve it, then exchanges the approved request
+// token for a long-lived access token used as consumer_key + access_token on /v3.
+
+const pocketRequestTokenTTL = 10 * time.Minute
+
+const (
+	pocketErrMissingConsumerKey = 138
+	pocketErrMissingRedirectURI = 140
+	pocketErrInvalidConsumerKey = 152
+	pocketErrUserRejectedCode   = 158
+	pocketErrAlreadyUsedCode    = 159
+	pocketErrInvalidRedirectURI = 181
+	pocketErrMissingCode        = 182
+	pocketErrCodeNotFound       = 185
+)
+
+func (s *Server) handlePocketOAuthRequest(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePocketParams(r)
+	if err != nil {
+		writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Invalid request, please refer to API documentation")
+		return
+	}
+	app, ok := s.pocketApp(w, r, p)
+	if !ok {
+		return
+	}
+	redirectURI := p.str("redirect_uri")
+	if redirectURI == "" {
+		writePocketError(w, http.StatusBadRequest, pocketErrMissingRedirectURI, "Missing redirect url.")
+		return
+	}
+	if !validRedirectURI(redirectURI) {
+		writePocketError(w, http.StatusBadRequest, pocketErrInvalidRedirectURI, "Invalid redirect uri.")
+		return
+	}
+
+	code, err := s.randomString(24)
+	if err != nil {
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		return
+	}
+	state := p.str("state")
+	if err := s.store.CreateOAuthRequest(r.Context(), app.ID, code, redirectURI, state, pocketRequestTokenTTL); err != nil {
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		return
+	}
+
+	values := url.Values{"code": {code}}
+	if state != "" {
+		values.Set("state", state)
+	}
+	writePocketOAuthResponse(w, r, values)
+}
+
+func (s *Server) handlePocketOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePocketParams(r)
+	if err != nil {
+		writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Invalid request, please refer to API documentation")
+		return
+	}
+	app, ok := s.pocketApp(w, r, p)
+	if !ok {
+		return
+	}
+	code := p.str("code")
+	if code == "" {
+		writePocketError(w, http.StatusBadRequest, pocketErrMissingCode, "Missing code.")
+		return
+	}
+
+	token, err := s.randomString(32)
+	if err != nil {
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		return
+	}
+	req, err := s.store.ConsumeOAuthRequest(r.Context(), app.ID, code, auth.HashToken(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writePocketError(w, http.StatusForbidden, pocketErrCodeNotFound, "Code not found.")
+		case errors.Is(err, store.ErrOAuthRequestDenied):
+			writePocketError(w, http.StatusForbidden, pocketErrUserRejectedCode, "User rejected code.")
+		case errors.Is(err, store.ErrOAuthRequestPending):
+			writePocketError(w, http.StatusForbidden, pocketErrUserRejectedCode, "User has not authorized code.")
+		case errors.Is(err, store.ErrOAuthRequestUsed):
+			writePocketError(w, http.StatusForbidden, pocketErrAlreadyUsedCode, "Already used code.")
+		default:
+			writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		}
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), req.UserID)
+	if err != nil {
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		return
+	}
+	s.logger.Info("pocket.oauth.authorized",
+		slog.String("app_id", app.ID),
+		slog.String("user_id", user.ID),
+		slog.String("request_id", s.requestID(r.Context())))
+
+	values := url.Values{"access_token": {token}, "username": {user.Email}}
+	if req.State != "" {
+		values.Set("state", req.State)
+	}
+	writePocketOAuthResponse(w, r, values)
+}
+
+func (s *Server) pocketApp(w http.ResponseWriter, r *http.Request, p pocketParams) (store.OAuthApp, bool) {
+	consumerKey := p.str("consumer_key")
+	if consumerKey == "" {
+		writePocketError(w, http.StatusBadRequest, pocketErrMissingConsumerKey, "Missing consumer key.")
+		return store.OAuthApp{}, false
+	}
+	app, err := s.store.GetOAuthAppByConsumerKey(r.Context(), consumerKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writePocketError(w, http.StatusForbidden, pocketErrInvalidConsumerKey, "Invalid consumer key.")
+			return store.OAuthApp{}, false
+		}
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue.")
+		return store.OAuthApp{}, false
+	}
+	return app, true
+}
+
+func (s *Server) handleUIPocketAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, err := s.store.GetOAuthRequest(r.Context(), r.URL.Query().Get("request_token"))
+	if err != nil || req.Status != "pending" {
+		s.renderUIPocketAuthorize(w, r, http.StatusBadRequest, store.OAuthRequest{}, "This authorization request is invalid or has expired.")
+		return
+	}
+	s.renderUIPocketAuthorize(w, r, http.StatusOK, req, "")
+}
+
+func (s *Server) handleUIPocketAuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		s.renderUIPocketAuthorize(w, r, http.StatusForbidden, store.OAuthRequest{}, "CSRF token mismatch.")
+		return
+	}
+	code := r.PostFormValue("request_token")
+	req, err := s.store.GetOAuthRequest(r.Context(), code)
+	if err != nil {
+		s.renderUIPocketAuthorize(w, r, http.StatusBadRequest, store.OAuthRequest{}, "This authorization request is invalid or has expired.")
+		return
+	}
+	approved := r.PostFormValue("decision") == "approve"
+	if err := s.store.DecideOAuthRequest(r.Context(), code, user.ID, approved); err != nil {
+		s.renderUIPocketAuthorize(w, r, http.StatusBadRequest, store.OAuthRequest{}, "This authorization request is invalid or has expired.")
+		return
+	}
+	s.logger.Info("pocket.oauth.decision",
+		slog.String("app_id", req.AppID),
+		slog.Bool("approved", approved),
+		slog.String("request_id", s.requestID(r.Context())))
+	http.Redirect(w, r, req.RedirectURI, http.StatusFound)
+}
+
+func (s *Server) renderUIPocketAuthorize(w http.ResponseWriter, r *http.Request, status int, req store.OAuthRequest, errMsg string) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":     "Authorize app",
+		"User":      user,
+		"CSRFToken": s.csrfFromContext(r.Context()),
+		"Request":   req,
+		"Error":     errMsg,
+	}
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+	}
+	if err := s.renderer.Render(w, "oauth_authorize", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICreateOAuthApp(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" || len(name) > 100 {
+		http.Redirect(w, r, "/ui/settings?notice=app_invalid", http.StatusFound)
+		return
+	}
+	consumerKey, err := s.randomString(24)
+	if err != nil {
+		http.Error(w, "failed", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.store.CreateOAuthApp(r.Context(), user.ID, name, consumerKey); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=app_created", http.StatusFound)
+}
+
+func (s *Server) handleUIDeleteOAuthApp(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if err := s.store.DeleteOAuthApp(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=app_deleted", http.StatusFound)
+}
+
+func (s *Server) handleUIRevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if err := s.store.RevokeOAuthToken(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=token_revoked", http.StatusFound)
+}
+
+// writePocketOAuthResponse answers in JSON when the client sends X-Accept: application/json
+// and form-encoded otherwise, as Pocket does.
+func writePocketOAuthResponse(w http.ResponseWriter, r *http.Request, values url.Values) {
+	if strings.Contains(r.Header.Get("X-Accept"), "application/json") {
+		body := make(map[string]string, len(values))
+		for key := range values {
+			body[key] = values.Get(key)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(body)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(values.Encode()))
+}
+
+// validRedirectURI accepts absolute URIs, including the custom schemes native clients use,
+// but rejects schemes that would execute in the browser.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "javascript", "data", "vbscript", "file":
+		return false
+	}
+	return true
+}
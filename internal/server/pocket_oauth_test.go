@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidRedirectURI(t *testing.T) {
+	for _, u := range []string{"https://client.example/callback", "pocketapp1234:authorizationFinished"} {
+		if !validRedirectURI(u) {
+			t.Fatalf("expected %q to be accepted", u)
+		}
+	}
+	for _, u := range []string{"", "/relative", "javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,x"} {
+		if validRedirectURI(u) {
+			t.Fatalf("expected %q to be rejected", u)
+		}
+	}
+}
+
+func TestWritePocketOAuthResponseHonorsXAccept(t *testing.T) {
+	values := url.Values{"code": {"abc"}, "state": {"s1"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/v3/oauth/request", nil)
+	req.Header.Set("X-Accept", "application/json")
+	rr := httptest.NewRecorder()
+	writePocketOAuthResponse(rr, req, values)
+	if rr.Header().Get("Content-Type") != "application/json" || !strings.Contains(rr.Body.String(), `"code":"abc"`) {
+		t.Fatalf("expected json response, got %q (%s)", rr.Body.String(), rr.Header().Get("Content-Type"))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v3/oauth/request", nil)
+	rr = httptest.NewRecorder()
+	writePocketOAuthResponse(rr, req, values)
+	if rr.Body.String() != "code=abc&state=s1" {
+		t.Fatalf("expected form-encoded response, got %q", rr.Body.String())
+	}
+}
+
+func TestPocketOAuthRequestRequiresConsumerKey(t *testing.T) {
+	s := newAuthTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/v3/oauth/request", strings.NewReader(`{"redirect_uri":"https://client.example"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	s.handlePocketOAuthRequest(rr, req)
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("X-Error-Code") != "138" {
+		t.Fatalf("expected 400/138, got %d/%s", rr.Code, rr.Header().Get("X-Error-Code"))
+	}
+}
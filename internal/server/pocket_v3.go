@@ -0,0 +1,582 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/tag"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Pocket v3 compatibility layer. Clients send parameters as JSON bodies, form bodies or
+// query strings and expect Pocket's response shapes, with errors reported through the
+// X-Error-Code / X-Error headers.
+
+const (
+	pocketMaxCount     = 30
+	pocketMaxBodyBytes = 1 << 20
+	pocketUntaggedTag  = "_untagged_"
+	// pocketStatusDeleted is the item status Pocket uses for deleted items.
+	pocketStatusDeleted = "2"
+)
+
+const (
+	pocketErrInvalidRequest = 130
+	pocketErrAccessToken    = 107
+	pocketErrServer         = 199
+)
+
+var (
+	errPocketMissingItemID = errors.New("missing_item_id")
+	errPocketUnknownAction = errors.New("unknown_action")
+	errPocketMissingTag    = errors.New("missing_tag")
+	errPocketTagNotFound   = errors.New("tag_not_found")
+)
+
+type pocketParams map[string]any
+
+type pocketHandler func(http.ResponseWriter, *http.Request, auth.User, pocketParams)
+
+type pocketTag struct {
+	ItemID string `json:"item_id"`
+	Tag    string `json:"tag"`
+}
+
+type pocketItem struct {
+	ItemID        string               `json:"item_id"`
+	ResolvedID    string               `json:"resolved_id"`
+	GivenURL      string               `json:"given_url"`
+	GivenTitle    string               `json:"given_title"`
+	Favorite      string               `json:"favorite"`
+	Status        string               `json:"status"`
+	TimeAdded     string               `json:"time_added"`
+	TimeUpdated   string               `json:"time_updated"`
+	TimeRead      string               `json:"time_read"`
+	TimeFavorited string               `json:"time_favorited"`
+	SortID        int                  `json:"sort_id"`
+	ResolvedTitle string               `json:"resolved_title"`
+	ResolvedURL   string               `json:"resolved_url"`
+	Excerpt       string               `json:"excerpt"`
+	IsArticle     string               `json:"is_article"`
+	HasVideo      string               `json:"has_video"`
+	HasImage      string               `json:"has_image"`
+	TopImageURL   string               `json:"top_image_url,omitempty"`
+	Lang          string               `json:"lang"`
+	Tags          map[string]pocketTag `json:"tags,omitempty"`
+}
+
+type pocketAddedItem struct {
+	ItemID      string `json:"item_id"`
+	NormalURL   string `json:"normal_url"`
+	ResolvedID  string `json:"resolved_id"`
+	ResolvedURL string `json:"resolved_url"`
+	GivenURL    string `json:"given_url"`
+	Title       string `json:"title"`
+	Excerpt     string `json:"excerpt"`
+	IsArticle   string `json:"is_article"`
+	HasVideo    string `json:"has_video"`
+	HasImage    string `json:"has_image"`
+}
+
+type pocketActionError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+}
+
+// requirePocket authenticates v3 requests and requires scope of bearer credentials.
+func (s *Server) requirePocket(scope string, next pocketHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parsePocketParams(r)
+		if err != nil {
+			writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Invalid request, please refer to API documentation")
+			return
+		}
+		user, granted, ok := s.pocketUser(r, params)
+		if !ok {
+			writePocketError(w, http.StatusUnauthorized, pocketErrAccessToken, "Invalid access token")
+			return
+		}
+		if !auth.ScopeAllows(granted, scope) {
+			writePocketError(w, http.StatusForbidden, pocketErrAccessToken, "Insufficient scope")
+			return
+		}
+		ctx := auth.ContextWithUser(r.Context(), user)
+		next(w, r.WithContext(ctx), user, params)
+	}
+}
+
+// pocketUser authenticates v3 requests with an app's consumer_key + access_token, falling
+// back to bearer credentials. The cookie-based web session (and its CSRF requirements)
+// never reaches this API. App tokens may read and write items.
+func (s *Server) pocketUser(r *http.Request, p pocketParams) (auth.User, string, bool) {
+	if consumerKey, token := p.str("consumer_key"), p.str("access_token"); consumerKey != "" && token != "" {
+		usr, err := s.store.GetUserByOAuthToken(r.Context(), consumerKey, auth.HashToken(token))
+		if err != nil {
+			return auth.User{}, "", false
+		}
+		return toAuthUser(usr), auth.ScopeWrite, true
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return auth.User{}, "", false
+	}
+	return s.authenticate(r)
+}
+
+func (s *Server) handlePocketAdd(w http.ResponseWriter, r *http.Request, user auth.User, p pocketParams) {
+	rawURL := p.str("url")
+	if rawURL == "" {
+		writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Missing url")
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writePocketError(w, http.StatusTooManyRequests, pocketErrInvalidRequest, "Rate limited")
+		return
+	}
+
+	item, err := s.pocketAdd(r.Context(), user.ID, rawURL, p.list("tags"))
+	if err != nil {
+		if errors.Is(err, errInvalidURL) {
+			writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Invalid url")
+			return
+		}
+		writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": item, "status": 1})
+}
+
+func (s *Server) handlePocketGet(w http.ResponseWriter, r *http.Request, user auth.User, p pocketParams) {
+	filter, satisfiable := pocketFilter(p)
+	detail := p.str("detailType") == "complete"
+	count := p.int("count", pocketMaxCount)
+	if count <= 0 || count > pocketMaxCount {
+		count = pocketMaxCount
+	}
+	offset := p.int("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var rows []store.ItemListRow
+	total := 0
+	if satisfiable {
+		var err error
+		rows, total, err = s.store.ListItemsRange(r.Context(), user.ID, filter, offset, count)
+		if err != nil {
+			writePocketError(w, http.StatusServiceUnavailable, pocketErrServer, "Pocket server issue")
+			return
+		}
+	}
+
+	list := make(map[string]pocketItem, len(rows))
+	for i, row := range rows {
+		list[row.ID] = toPocketItem(row, offset+i, detail)
+	}
+
+	resp := map[string]interface{}{
+		"status":      1,
+		"complete":    1,
+		"list":        list,
+		"error":       nil,
+		"search_meta": map[string]string{"search_type": "normal"},
+		"since":       time.Now().Unix(),
+	}
+	if p.bool("total") {
+		resp["total"] = strconv.Itoa(total)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlePocketSend(w http.ResponseWriter, r *http.Request, user auth.User, p pocketParams) {
+	actions, err := p.actions()
+	if err != nil || len(actions) == 0 {
+		writePocketError(w, http.StatusBadRequest, pocketErrInvalidRequest, "Invalid actions")
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writePocketError(w, http.StatusTooManyRequests, pocketErrInvalidRequest, "Rate limited")
+		return
+	}
+
+	results := make([]interface{}, 0, len(actions))
+	actionErrors := make([]*pocketActionError, 0, len(actions))
+	status := 1
+	for _, action := range actions {
+		result, err := s.applyPocketAction(r.Context(), user.ID, action)
+		if err != nil {
+			status = 0
+			s.logger.Info("pocket.send.action_failed",
+				slog.String("action", action.str("action")),
+				slog.String("error", err.Error()),
+				slog.String("request_id", s.requestID(r.Context())))
+			results = append(results, false)
+			actionErrors = append(actionErrors, pocketActionErrorFor(err))
+			continue
+		}
+		results = append(results, result)
+		actionErrors = append(actionErrors, nil)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         status,
+		"action_results": results,
+		"action_errors":  actionErrors,
+	})
+}
+
+func (s *Server) applyPocketAction(ctx context.Context, userID string, a pocketParams) (interface{}, error) {
+	name := a.str("action")
+	if name == "add" {
+		rawURL := a.str("url")
+		if rawURL == "" {
+			return nil, errInvalidURL
+		}
+		return s.pocketAdd(ctx, userID, rawURL, a.list("tags"))
+	}
+
+	itemID := a.str("item_id")
+	var err error
+	switch name {
+	case "delete":
+		if itemID == "" {
+			return nil, errPocketMissingItemID
+		}
+		err = s.store.DeleteItem(ctx, userID, itemID)
+	case "tags_add", "tags_remove", "tags_replace", "tags_clear":
+		if itemID == "" {
+			return nil, errPocketMissingItemID
+		}
+		tags := tag.NormalizeAll(a.list("tags"))
+		switch name {
+		case "tags_add":
+			_, err = s.store.AddItemTags(ctx, userID, itemID, tags)
+		case "tags_remove":
+			_, err = s.store.RemoveItemTags(ctx, userID, itemID, tags)
+		case "tags_replace":
+			_, err = s.store.ReplaceItemTags(ctx, userID, itemID, tags)
+		default:
+			_, err = s.store.ReplaceItemTags(ctx, userID, itemID, nil)
+		}
+	case "archive", "readd", "favorite", "unfavorite":
+		if itemID == "" {
+			return nil, errPocketMissingItemID
+		}
+		switch name {
+		case "archive":
+			err = s.archiveItem(ctx, userID, itemID)
+		case "readd":
+			err = s.unarchiveItem(ctx, userID, itemID)
+		case "favorite":
+			err = s.favoriteItem(ctx, userID, itemID)
+		default:
+			err = s.unfavoriteItem(ctx, userID, itemID)
+		}
+	case "tag_rename":
+		err = s.pocketRenameTag(ctx, userID, tag.Normalize(a.str("old_tag")), tag.Normalize(a.str("new_tag")))
+	case "tag_delete":
+		var t store.Tag
+		if t, err = s.store.GetTagByName(ctx, userID, tag.Normalize(a.str("tag"))); err == nil {
+			err = s.store.DeleteTag(ctx, userID, t.ID)
+		}
+	default:
+		err = errPocketUnknownAction
+	}
+	if errors.Is(err, pgx.ErrNoRows) && strings.HasPrefix(name, "tag_") {
+		err = errPocketTagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+// pocketRenameTag renames oldName, merging it into newName when the user already has
+// that tag, as Pocket does.
+func (s *Server) pocketRenameTag(ctx context.Context, userID, oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return errPocketMissingTag
+	}
+	source, err := s.store.GetTagByName(ctx, userID, oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+	target, err := s.store.GetTagByName(ctx, userID, newName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = s.store.RenameTag(ctx, userID, source.ID, newName)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.store.MergeTag(ctx, userID, source.ID, target.ID)
+	return err
+}
+
+func (s *Server) pocketAdd(ctx context.Context, userID, rawURL string, rawTags []string) (pocketAddedItem, error) {
+	itemID, _, err := s.createItem(ctx, userID, "", rawURL, rawTags)
+	if err != nil {
+		return pocketAddedItem{}, err
+	}
+	item, err := s.store.GetItemDetail(ctx, userID, itemID)
+	if err != nil {
+		return pocketAddedItem{}, err
+	}
+	return pocketAddedItem{
+		ItemID:      item.ID,
+		NormalURL:   item.CanonicalURL,
+		ResolvedID:  item.ID,
+		ResolvedURL: item.CanonicalURL,
+		GivenURL:    item.URL,
+		Title:       item.Title,
+		Excerpt:     item.Excerpt,
+		IsArticle:   pocketFlag(item.FetchStatus == "success"),
+		HasVideo:    "0",
+		HasImage:    "0",
+	}, nil
+}
+
+// pocketFilter translates /v3/get parameters into a store filter. The second return value
+// is false when the parameters select something this server never stores (such as videos),
+// in which case the result is known to be empty.
+func pocketFilter(p pocketParams) (store.ItemFilter, bool) {
+	filter := store.ItemFilter{
+		Query:  strings.TrimSpace(p.str("search")),
+		Domain: strings.TrimSpace(p.str("domain")),
+		Sort:   "newest",
+	}
+	satisfiable := true
+
+	switch p.str("state") {
+	case "unread":
+		filter.State = store.ItemStateUnread
+	case "archive":
+		filter.State = store.ItemStateArchived
+	}
+	filter.Favorite = favoriteFilter(p.str("favorite"))
+	switch p.str("contentType") {
+	case "video", "image":
+		satisfiable = false
+	}
+
+	if t := p.str("tag"); t == pocketUntaggedTag {
+		filter.Untagged = true
+	} else if t != "" {
+		filter.Tag = tag.Normalize(t)
+	}
+
+	switch p.str("sort") {
+	case "oldest", "title", "site":
+		filter.Sort = p.str("sort")
+	}
+
+	if since := p.int("since", 0); since > 0 {
+		filter.Since = time.Unix(int64(since), 0)
+	}
+	return filter, satisfiable
+}
+
+func toPocketItem(row store.ItemListRow, sortID int, detail bool) pocketItem {
+	item := pocketItem{
+		ItemID:        row.ID,
+		ResolvedID:    row.ID,
+		GivenURL:      row.URL,
+		GivenTitle:    row.Title,
+		Favorite:      pocketFlag(row.Favorite),
+		Status:        pocketFlag(row.State == store.ItemStateArchived),
+		TimeAdded:     pocketTime(row.CreatedAt),
+		TimeUpdated:   pocketTime(row.UpdatedAt),
+		TimeRead:      pocketTimePtr(row.ArchivedAt),
+		TimeFavorited: pocketTimePtr(row.FavoritedAt),
+		SortID:        sortID,
+		ResolvedTitle: row.Title,
+		ResolvedURL:   row.CanonicalURL,
+		Excerpt:       row.Excerpt,
+		IsArticle:     pocketFlag(row.FetchStatus == "success"),
+		HasVideo:      "0",
+		HasImage:      pocketFlag(row.ImageURL != ""),
+		TopImageURL:   row.ImageURL,
+		Lang:          row.Language,
+	}
+	// Only since queries return trashed items; Pocket reports them as deleted.
+	if row.DeletedAt != nil {
+		item.Status = pocketStatusDeleted
+	}
+	if detail && len(row.Tags) > 0 {
+		item.Tags = make(map[string]pocketTag, len(row.Tags))
+		for _, t := range row.Tags {
+			item.Tags[t.Name] = pocketTag{ItemID: row.ID, Tag: t.Name}
+		}
+	}
+	return item
+}
+
+func pocketActionErrorFor(err error) *pocketActionError {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return &pocketActionError{Message: "Item not found", Type: "Not Found", Code: 404}
+	case errors.Is(err, errInvalidURL):
+		return &pocketActionError{Message: "Invalid URL", Type: "Bad Request", Code: 422}
+	case errors.Is(err, errPocketMissingItemID):
+		return &pocketActionError{Message: "Missing item_id", Type: "Bad Request", Code: 400}
+	case errors.Is(err, errPocketTagNotFound):
+		return &pocketActionError{Message: "Tag not found", Type: "Not Found", Code: 404}
+	case errors.Is(err, errPocketMissingTag):
+		return &pocketActionError{Message: "Missing tag", Type: "Bad Request", Code: 400}
+	case errors.Is(err, errPocketUnknownAction):
+		return &pocketActionError{Message: "Unknown action", Type: "Bad Request", Code: 400}
+	default:
+		return &pocketActionError{Message: "Internal error", Type: "Internal Server Error", Code: 500}
+	}
+}
+
+func writePocketError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("X-Error-Code", strconv.Itoa(code))
+	w.Header().Set("X-Error", message)
+	writeJSON(w, status, map[string]interface{}{"status": 0, "error": message, "error_code": code})
+}
+
+// parsePocketParams merges the query string with a JSON or form-encoded body.
+func parsePocketParams(r *http.Request) (pocketParams, error) {
+	params := pocketParams{}
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	if r.Body == nil || r.Method == http.MethodGet {
+		return params, nil
+	}
+
+	r.Body = http.MaxBytesReader(nil, r.Body, pocketMaxBodyBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		body := map[string]any{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			return nil, err
+		}
+		for key, value := range body {
+			params[key] = value
+		}
+		return params, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	for key, values := range r.PostForm {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	return params, nil
+}
+
+func (p pocketParams) str(key string) string {
+	switch v := p[key].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return pocketFlag(v)
+	default:
+		return ""
+	}
+}
+
+func (p pocketParams) int(key string, fallback int) int {
+	return parseInt(p.str(key), fallback)
+}
+
+func (p pocketParams) bool(key string) bool {
+	switch p.str(key) {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
+
+// list reads a comma-separated string or a JSON array of strings.
+func (p pocketParams) list(key string) []string {
+	switch v := p[key].(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// actions reads the /v3/send actions list, given either as a JSON array or as a
+// JSON-encoded string (the form-encoded and query-string variants).
+func (p pocketParams) actions() ([]pocketParams, error) {
+	var raw []any
+	switch v := p["actions"].(type) {
+	case []any:
+		raw = v
+	case string:
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("missing actions")
+	}
+
+	out := make([]pocketParams, 0, len(raw))
+	for i, entry := range raw {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("action %d is not an object", i)
+		}
+		out = append(out, pocketParams(m))
+	}
+	return out, nil
+}
+
+func pocketTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func pocketTimePtr(t *time.Time) string {
+	if t == nil {
+		return "0"
+	}
+	return pocketTime(*t)
+}
+
+func pocketFlag(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"altpocket/internal/store"
+)
+
+func TestParsePocketParamsJSONBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v3/get?detailType=complete", strings.NewReader(`{"count":10,"tag":"Go","total":true}`))
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	p, err := parsePocketParams(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.int("count", 0) != 10 {
+		t.Fatalf("expected count 10, got %d", p.int("count", 0))
+	}
+	if p.str("tag") != "Go" {
+		t.Fatalf("expected tag Go, got %q", p.str("tag"))
+	}
+	if !p.bool("total") {
+		t.Fatalf("expected total to be true")
+	}
+	if p.str("detailType") != "complete" {
+		t.Fatalf("expected query params to be merged, got %q", p.str("detailType"))
+	}
+}
+
+func TestParsePocketParamsFormBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v3/add", strings.NewReader("url=https%3A%2F%2Fexample.com&tags=a%2Cb"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	p, err := parsePocketParams(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.str("url") != "https://example.com" {
+		t.Fatalf("unexpected url: %q", p.str("url"))
+	}
+	tags := p.list("tags")
+	if len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Fatalf("unexpected tags: %#v", tags)
+	}
+}
+
+func TestPocketParamsActionsAcceptsArrayAndEncodedString(t *testing.T) {
+	var body map[string]any
+	if err := json.Unmarshal([]byte(`{"actions":[{"action":"delete","item_id":"1"}]}`), &body); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	actions, err := pocketParams(body).actions()
+	if err != nil || len(actions) != 1 || actions[0].str("action") != "delete" {
+		t.Fatalf("unexpected actions from array: %#v, %v", actions, err)
+	}
+
+	encoded := pocketParams{"actions": `[{"action":"tags_add","item_id":"2","tags":["x","y"]}]`}
+	actions, err = encoded.actions()
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("unexpected actions from string: %#v, %v", actions, err)
+	}
+	if got := actions[0].list("tags"); len(got) != 2 || got[1] != "y" {
+		t.Fatalf("unexpected tags: %#v", got)
+	}
+
+	if _, err := (pocketParams{}).actions(); err == nil {
+		t.Fatalf("expected missing actions to fail")
+	}
+	if _, err := (pocketParams{"actions": `["delete"]`}).actions(); err == nil {
+		t.Fatalf("expected non-object action to fail")
+	}
+}
+
+func TestPocketFilter(t *testing.T) {
+	filter, ok := pocketFilter(pocketParams{
+		"search": " golang ",
+		"domain": "example.com",
+		"tag":    "Go",
+		"sort":   "oldest",
+		"since":  "1700000000",
+	})
+	if !ok {
+		t.Fatalf("expected filter to be satisfiable")
+	}
+	if filter.Query != "golang" || filter.Domain != "example.com" || filter.Tag != "go" || filter.Sort != "oldest" {
+		t.Fatalf("unexpected filter: %#v", filter)
+	}
+	if !filter.Since.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("unexpected since: %v", filter.Since)
+	}
+
+	filter, _ = pocketFilter(pocketParams{"tag": pocketUntaggedTag, "sort": "bogus"})
+	if !filter.Untagged || filter.Tag != "" || filter.Sort != "newest" {
+		t.Fatalf("unexpected untagged filter: %#v", filter)
+	}
+
+	filter, _ = pocketFilter(pocketParams{"state": "archive", "favorite": "1"})
+	if filter.State != store.ItemStateArchived || filter.Favorite == nil || !*filter.Favorite {
+		t.Fatalf("unexpected state filter: %#v", filter)
+	}
+	filter, _ = pocketFilter(pocketParams{"state": "all"})
+	if filter.State != "" || filter.Favorite != nil {
+		t.Fatalf("state=all should not filter: %#v", filter)
+	}
+
+	if _, ok := pocketFilter(pocketParams{"contentType": "video"}); ok {
+		t.Fatalf("video content should never match")
+	}
+}
+
+func TestToPocketItem(t *testing.T) {
+	archivedAt := time.Unix(1700000500, 0)
+	row := store.ItemListRow{
+		Item: store.Item{
+			ID:           "item-1",
+			URL:          "https://example.com/a?utm_source=x",
+			CanonicalURL: "https://example.com/a",
+			Title:        "Title",
+			FetchStatus:  "success",
+			CreatedAt:    time.Unix(1700000000, 0),
+			State:        store.ItemStateArchived,
+			ArchivedAt:   &archivedAt,
+			Favorite:     true,
+		},
+		Tags: []store.Tag{{ID: "t1", Name: "go", NormalizedName: "go"}},
+	}
+
+	simple := toPocketItem(row, 3, false)
+	if simple.ItemID != "item-1" || simple.ResolvedURL != "https://example.com/a" || simple.GivenURL != row.URL {
+		t.Fatalf("unexpected item: %#v", simple)
+	}
+	if simple.TimeAdded != "1700000000" || simple.TimeUpdated != "0" || simple.SortID != 3 || simple.IsArticle != "1" {
+		t.Fatalf("unexpected item fields: %#v", simple)
+	}
+	if simple.Status != "1" || simple.TimeRead != "1700000500" || simple.Favorite != "1" || simple.TimeFavorited != "0" {
+		t.Fatalf("unexpected state fields: %#v", simple)
+	}
+	if simple.Tags != nil {
+		t.Fatalf("simple detail should omit tags")
+	}
+
+	complete := toPocketItem(row, 0, true)
+	if complete.Tags["go"].ItemID != "item-1" {
+		t.Fatalf("expected tags in complete detail, got %#v", complete.Tags)
+	}
+}
+
+func TestToPocketItemReportsTrashedItemsAsDeleted(t *testing.T) {
+	deletedAt := time.Unix(1700000900, 0)
+	row := store.ItemListRow{Item: store.Item{
+		ID:        "item-1",
+		CreatedAt: time.Unix(1700000000, 0),
+		UpdatedAt: deletedAt,
+		State:     store.ItemStateArchived,
+		DeletedAt: &deletedAt,
+	}}
+	if got := toPocketItem(row, 0, false); got.Status != "2" || got.TimeUpdated != "1700000900" {
+		t.Fatalf("trashed item should be reported as deleted: %#v", got)
+	}
+
+	row.DeletedAt = nil
+	if got := toPocketItem(row, 0, false); got.Status != "1" {
+		t.Fatalf("live archived item status = %q, want 1", got.Status)
+	}
+}
+
+func TestWritePocketErrorSetsHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writePocketError(rr, http.StatusBadRequest, pocketErrInvalidRequest, "Missing url")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-Error-Code") != "130" || rr.Header().Get("X-Error") != "Missing url" {
+		t.Fatalf("unexpected headers: %v", rr.Header())
+	}
+}
+
+func TestPocketTagActionErrors(t *testing.T) {
+	if got := pocketActionErrorFor(errPocketTagNotFound); got.Code != 404 || got.Message != "Tag not found" {
+		t.Fatalf("unexpected error: %#v", got)
+	}
+	if got := pocketActionErrorFor(errPocketMissingTag); got.Code != 400 {
+		t.Fatalf("unexpected error: %#v", got)
+	}
+	s := &Server{}
+	if err := s.pocketRenameTag(context.Background(), "u", "", "go"); !errors.Is(err, errPocketMissingTag) {
+		t.Fatalf("expected missing tag error, got %v", err)
+	}
+}
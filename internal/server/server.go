@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"html/template"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 	"altpocket/internal/auth"
 	"altpocket/internal/config"
 	"altpocket/internal/ratelimit"
+	"altpocket/internal/sanitize"
 	"altpocket/internal/store"
 	"altpocket/internal/tag"
 	"altpocket/internal/ui"
@@ -100,23 +102,154 @@ func (s *Server) Routes() http.Handler {
 		r.Get("/auth/google/login", s.handleGoogleLogin)
 		r.Get("/auth/google/callback", s.handleGoogleCallback)
 		r.Post("/auth/extension/exchange", s.handleExtensionExchange)
-		r.Get("/tags", s.requireAuth(s.handleTags))
+		r.Post("/auth/extension/refresh", s.handleExtensionRefresh)
+		r.Post("/auth/extension/revoke", s.handleExtensionRevoke)
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/", s.requireAuth(s.handleTags))
+			r.Patch("/{id}", s.requireAuth(s.handleRenameTag))
+			r.Post("/{id}/merge", s.requireAuth(s.handleMergeTag))
+			r.Delete("/{id}", s.requireAuth(s.handleDeleteTag))
+		})
 
 		r.Route("/items", func(r chi.Router) {
 			r.Get("/", s.requireAuth(s.handleListItems))
 			r.Post("/", s.requireAuth(s.handleCreateItem))
+			r.Post("/batch", s.requireAuth(s.handleBatchItems))
 			r.Get("/{id}", s.requireAuth(s.handleGetItem))
 			r.Put("/{id}/tags", s.requireAuth(s.handleUpdateItemTags))
+			r.Patch("/{id}", s.requireAuth(s.handleUpdateItem))
 			r.Delete("/{id}", s.requireAuth(s.handleDeleteItem))
+			r.Post("/{id}/restore", s.requireAuth(s.handleRestoreItem))
+			r.Get("/{id}/highlights", s.requireAuth(s.handleListHighlights))
+			r.Post("/{id}/highlights", s.requireAuth(s.handleCreateHighlight))
+			r.Delete("/{id}/highlights/{highlightID}", s.requireAuth(s.handleDeleteHighlight))
 			r.Post("/{id}/refetch", s.requireAuth(s.handleRefetchItem))
+			r.Get("/{id}/share", s.requireAuth(s.handleListShares))
+			r.Post("/{id}/share", s.requireAuth(s.handleCreateShare))
+			r.Delete("/{id}/share/{shareID}", s.requireAuth(s.handleRevokeShare))
+			r.Post("/{id}/archive", s.requireAuth(s.handleSetItemState(s.archiveItem, map[string]interface{}{"state": store.ItemStateArchived})))
+			r.Post("/{id}/unarchive", s.requireAuth(s.handleSetItemState(s.unarchiveItem, map[string]interface{}{"state": store.ItemStateUnread})))
+			r.Post("/{id}/favorite", s.requireAuth(s.handleSetItemState(s.favoriteItem, map[string]interface{}{"favorite": true})))
+			r.Post("/{id}/unfavorite", s.requireAuth(s.handleSetItemState(s.unfavoriteItem, map[string]interface{}{"favorite": false})))
+		})
+
+		r.Route("/collections", func(r chi.Router) {
+			r.Get("/", s.requireAuth(s.handleListCollections))
+			r.Post("/", s.requireAuth(s.handleCreateCollection))
+			r.Get("/{id}", s.requireAuth(s.handleGetCollection))
+			r.Patch("/{id}", s.requireAuth(s.handleUpdateCollection))
+			r.Delete("/{id}", s.requireAuth(s.handleDeleteCollection))
+			r.Post("/{id}/items", s.requireAuth(s.handleAddCollectionItems))
+			r.Delete("/{id}/items/{itemID}", s.requireAuth(s.handleRemoveCollectionItem))
+			r.Put("/{id}/order", s.requireAuth(s.handleReorderCollection))
+		})
+		r.Route("/workspaces", func(r chi.Router) {
+			r.Get("/", s.requireAuth(s.handleListWorkspaces))
+			r.Post("/", s.requireAuth(s.handleCreateWorkspace))
+			r.Get("/{id}", s.requireAuth(s.handleGetWorkspace))
+			r.Delete("/{id}", s.requireAuth(s.handleDeleteWorkspace))
+			r.Post("/{id}/members", s.requireAuth(s.handleAddWorkspaceMember))
+			r.Patch("/{id}/members/{userID}", s.requireAuth(s.handleUpdateWorkspaceMember))
+			r.Delete("/{id}/members/{userID}", s.requireAuth(s.handleRemoveWorkspaceMember))
+		})
+
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.Get("/", s.requireAuth(s.handleListSubscriptions))
+			r.Post("/", s.requireAuth(s.handleCreateSubscription))
+			r.Get("/opml", s.requireAuth(s.handleExportOPML))
+			r.Post("/opml", s.requireAuth(s.handleImportOPML))
+			r.Patch("/{id}", s.requireAuth(s.handleUpdateSubscription))
+			r.Delete("/{id}", s.requireAuth(s.handleDeleteSubscription))
+		})
+
+		r.Route("/tokens", func(r chi.Router) {
+			r.Get("/", s.requireAdmin(s.handleListPersonalTokens))
+			r.Post("/", s.requireAdmin(s.handleCreatePersonalToken))
+			r.Delete("/{id}", s.requireAdmin(s.handleRevokePersonalToken))
+		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", s.requireAdmin(s.handleListWebhooks))
+			r.Post("/", s.requireAdmin(s.handleCreateWebhook))
+			r.Patch("/{id}", s.requireAdmin(s.handleUpdateWebhook))
+			r.Delete("/{id}", s.requireAdmin(s.handleDeleteWebhook))
+			r.Get("/{id}/deliveries", s.requireAdmin(s.handleListWebhookDeliveries))
+			r.Post("/{id}/deliveries/{deliveryID}/retry", s.requireAdmin(s.handleRetryWebhookDelivery))
+		})
+
+		r.Get("/export", s.requireAuth(s.handleExport))
+		r.Get("/export/markdown", s.requireAuth(s.handleExportMarkdown))
+
+		r.Route("/imports", func(r chi.Router) {
+			r.Get("/", s.requireAuth(s.handleListImports))
+			r.Post("/", s.requireAuth(s.handleCreateImport))
+			r.Get("/{id}", s.requireAuth(s.handleGetImport))
 		})
 	})
 
+	r.Route("/v3", func(r chi.Router) {
+		r.Use(s.cors)
+		r.Post("/add", s.requirePocket(auth.ScopeWrite, s.handlePocketAdd))
+		r.Get("/get", s.requirePocket(auth.ScopeRead, s.handlePocketGet))
+		r.Post("/get", s.requirePocket(auth.ScopeRead, s.handlePocketGet))
+		r.Get("/send", s.requirePocket(auth.ScopeWrite, s.handlePocketSend))
+		r.Post("/send", s.requirePocket(auth.ScopeWrite, s.handlePocketSend))
+		r.Post("/oauth/request", s.handlePocketOAuthRequest)
+		r.Post("/oauth/authorize", s.handlePocketOAuthAuthorize)
+	})
+
+	r.Get("/s/{token}", s.handleSharedItem)
+	r.Get("/feeds/{token}/{format}", s.handleFeed)
+	r.Head("/feeds/{token}/{format}", s.handleFeed)
+
+	r.Get("/auth/authorize", s.requireWeb(s.handleUIPocketAuthorize))
+	r.Post("/auth/authorize", s.requireWeb(s.handleUIPocketAuthorizeSubmit))
+
 	r.Route("/ui", func(r chi.Router) {
 		r.Get("/items", s.requireWeb(s.handleUIItems))
 		r.Get("/items/{id}", s.requireWeb(s.handleUIItem))
+		r.Post("/items/{id}/edit", s.requireWeb(s.handleUIUpdateItem))
+		r.Post("/items/{id}/collections", s.requireWeb(s.handleUIAddItemToCollection))
+		r.Get("/collections", s.requireWeb(s.handleUICollections))
+		r.Post("/collections", s.requireWeb(s.handleUICreateCollection))
+		r.Get("/collections/{id}", s.requireWeb(s.handleUICollection))
+		r.Post("/collections/{id}/update", s.requireWeb(s.handleUIUpdateCollection))
+		r.Post("/collections/{id}/delete", s.requireWeb(s.handleUIDeleteCollection))
+		r.Get("/workspaces", s.requireWeb(s.handleUIWorkspaces))
+		r.Post("/workspaces", s.requireWeb(s.handleUICreateWorkspace))
+		r.Post("/workspaces/{id}/members", s.requireWeb(s.handleUIWorkspaceAction("added", s.uiAddWorkspaceMember)))
+		r.Post("/workspaces/{id}/members/{userID}/role", s.requireWeb(s.handleUIWorkspaceAction("updated", s.uiSetWorkspaceMemberRole)))
+		r.Post("/workspaces/{id}/members/{userID}/remove", s.requireWeb(s.handleUIWorkspaceAction("removed", s.uiRemoveWorkspaceMember)))
+		r.Post("/workspaces/{id}/delete", s.requireWeb(s.handleUIWorkspaceAction("deleted", s.uiDeleteWorkspace)))
+		r.Get("/subscriptions", s.requireWeb(s.handleUISubscriptions))
+		r.Post("/subscriptions", s.requireWeb(s.handleUICreateSubscription))
+		r.Post("/subscriptions/import", s.requireWeb(s.handleUIImportOPML))
+		r.Post("/subscriptions/{id}/tags", s.requireWeb(s.handleUIUpdateSubscription))
+		r.Post("/subscriptions/{id}/delete", s.requireWeb(s.handleUIDeleteSubscription))
+		r.Get("/webhooks", s.requireWeb(s.handleUIWebhooks))
+		r.Post("/webhooks", s.requireWeb(s.handleUICreateWebhook))
+		r.Post("/webhooks/{id}/update", s.requireWeb(s.handleUIUpdateWebhook))
+		r.Post("/webhooks/{id}/delete", s.requireWeb(s.handleUIDeleteWebhook))
+		r.Post("/webhooks/{id}/deliveries/{deliveryID}/retry", s.requireWeb(s.handleUIRetryWebhookDelivery))
 		r.Get("/quick-add", s.requireWeb(s.handleUIQuickAdd))
 		r.Post("/quick-add", s.requireWeb(s.handleUIQuickAddSubmit))
+		r.Get("/import", s.requireWeb(s.handleUIImport))
+		r.Post("/import", s.requireWeb(s.handleUIImportSubmit))
+		r.Get("/trash", s.requireWeb(s.handleUITrash))
+		r.Post("/trash/empty", s.requireWeb(s.handleUIEmptyTrash))
+		r.Get("/tags", s.requireWeb(s.handleUITags))
+		r.Post("/tags/{id}/rename", s.requireWeb(s.handleUIRenameTag))
+		r.Post("/tags/{id}/merge", s.requireWeb(s.handleUIMergeTag))
+		r.Post("/tags/{id}/delete", s.requireWeb(s.handleUIDeleteTag))
+		r.Get("/settings", s.requireWeb(s.handleUISettings))
+		r.Post("/settings/feed-token", s.requireWeb(s.handleUICreateFeedToken))
+		r.Post("/settings/feed-token/delete", s.requireWeb(s.handleUIDeleteFeedToken))
+		r.Post("/settings/oauth-apps", s.requireWeb(s.handleUICreateOAuthApp))
+		r.Post("/settings/oauth-apps/{id}/delete", s.requireWeb(s.handleUIDeleteOAuthApp))
+		r.Post("/settings/oauth-tokens/{id}/revoke", s.requireWeb(s.handleUIRevokeOAuthToken))
+		r.Post("/settings/tokens", s.requireWeb(s.handleUICreatePersonalToken))
+		r.Post("/settings/tokens/{id}/revoke", s.requireWeb(s.handleUIRevokePersonalToken))
+		r.Post("/settings/devices/{id}/revoke", s.requireWeb(s.handleUIRevokeExtensionDevice))
 	})
 
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
@@ -147,6 +280,17 @@ func (s *Server) handleGoogleLogin(w http.ResponseWriter, r *http.Request) {
 		MaxAge:   300,
 	}
 	http.SetCookie(w, cookie)
+	if next := r.URL.Query().Get("next"); safeNextPath(next) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "oauth_next",
+			Value:    next,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   strings.HasPrefix(s.cfg.PublicBaseURL, "https://"),
+			MaxAge:   300,
+		})
+	}
 	url := s.oauthCfg.AuthCodeURL(state, oauth2.AccessTypeOnline)
 	http.Redirect(w, r, url, http.StatusFound)
 }
@@ -253,12 +397,20 @@ func (s *Server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	// Clear oauth_state
 	http.SetCookie(w, &http.Cookie{Name: "oauth_state", Value: "", Path: "/", MaxAge: -1})
 
-	http.Redirect(w, r, "/ui/items", http.StatusFound)
+	redirectTo := "/ui/items"
+	if next, err := r.Cookie("oauth_next"); err == nil && safeNextPath(next.Value) {
+		redirectTo = next.Value
+		http.SetCookie(w, &http.Cookie{Name: "oauth_next", Value: "", Path: "/", MaxAge: -1})
+	}
+	http.Redirect(w, r, redirectTo, http.StatusFound)
 }
 
+// handleExtensionExchange signs an extension install in with a Google ID token and
+// returns an access token with a refresh token for the new device.
 func (s *Server) handleExtensionExchange(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		IDToken string `json:"id_token"`
+		IDToken    string `json:"id_token"`
+		DeviceName string `json:"device_name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IDToken == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
@@ -284,22 +436,29 @@ func (s *Server) handleExtensionExchange(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token, exp, err := auth.IssueJWT(s.cfg.JWTSecret, user.ID, 24*time.Hour)
+	refreshToken, err := s.randomString(32)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_error"})
 		return
 	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"token":      token,
-		"expires_in": exp - time.Now().Unix(),
-	})
+	deviceID, err := s.store.CreateExtensionDevice(r.Context(), user.ID, deviceName(req.DeviceName), auth.HashToken(refreshToken), extensionRefreshTTL)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	s.logger.Info("auth.extension_device", slog.String("device_id", deviceID), slog.String("request_id", s.requestID(r.Context())))
+	s.writeExtensionTokens(w, r, user.ID, deviceID, refreshToken)
 }
 
 func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
 	q := r.URL.Query().Get("q")
 	norm := tag.Normalize(q)
-	tags, err := s.store.SuggestTags(r.Context(), norm)
+	tags, err := s.store.SuggestTags(r.Context(), user.ID, norm)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
 		return
@@ -319,20 +478,28 @@ func (s *Server) handleCreateItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		URL  string   `json:"url"`
-		Tags []string `json:"tags"`
+		URL         string   `json:"url"`
+		Tags        []string `json:"tags"`
+		WorkspaceID string   `json:"workspace_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
 		return
 	}
+	if ws, ok := workspaceFromContext(r.Context()); ok && req.WorkspaceID == "" {
+		req.WorkspaceID = ws.ID
+	}
 
-	itemID, created, err := s.createItem(r.Context(), user.ID, req.URL, req.Tags)
+	itemID, created, err := s.createItem(r.Context(), user.ID, req.WorkspaceID, req.URL, req.Tags)
 	if err != nil {
 		if errors.Is(err, errInvalidURL) {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_url"})
 			return
 		}
+		if errors.Is(err, store.ErrWorkspaceForbidden) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
 		return
 	}
@@ -348,11 +515,24 @@ func (s *Server) handleListItems(w http.ResponseWriter, r *http.Request) {
 	}
 	q := r.URL.Query().Get("q")
 	tagFilter := tag.Normalize(r.URL.Query().Get("tag"))
-	sort := defaultSort(r.URL.Query().Get("sort"))
+	collection := r.URL.Query().Get("collection")
+	sort := listSort(r.URL.Query().Get("sort"), collection)
 	page := parseInt(r.URL.Query().Get("page"), 1)
 	perPage := perPageValue(r.URL.Query().Get("per_page"))
 
-	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, q, tagFilter, sort)
+	filter := store.ItemFilter{
+		Query:      q,
+		Tag:        tagFilter,
+		Sort:       sort,
+		State:      stateFilter(r.URL.Query().Get("state")),
+		Favorite:   favoriteFilter(r.URL.Query().Get("favorite")),
+		Collection: collection,
+	}
+	if ws, ok := workspaceFromContext(r.Context()); ok {
+		filter.Workspace = ws.ID
+	}
+
+	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, filter)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
 		return
@@ -418,7 +598,7 @@ func (s *Server) handleUpdateItemTags(w http.ResponseWriter, r *http.Request) {
 	}
 
 	itemID := chi.URLParam(r, "id")
-	tags, err := s.store.ReplaceItemTags(r.Context(), user.ID, itemID, normalizeTagNames(req.Tags))
+	tags, err := s.store.ReplaceItemTags(r.Context(), user.ID, itemID, tag.NormalizeAll(req.Tags))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
@@ -450,6 +630,47 @@ func (s *Server) handleRefetchItem(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
 }
 
+// handleSetItemState applies a state change to the item in the URL and echoes result.
+func (s *Server) handleSetItemState(apply func(context.Context, string, string) error, result map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if !s.limiter.Allow(user.ID) {
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+			return
+		}
+		id := chi.URLParam(r, "id")
+		if err := apply(r.Context(), user.ID, id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) archiveItem(ctx context.Context, userID, itemID string) error {
+	return s.store.SetItemArchived(ctx, userID, itemID, true)
+}
+
+func (s *Server) unarchiveItem(ctx context.Context, userID, itemID string) error {
+	return s.store.SetItemArchived(ctx, userID, itemID, false)
+}
+
+func (s *Server) favoriteItem(ctx context.Context, userID, itemID string) error {
+	return s.store.SetItemFavorite(ctx, userID, itemID, true)
+}
+
+func (s *Server) unfavoriteItem(ctx context.Context, userID, itemID string) error {
+	return s.store.SetItemFavorite(ctx, userID, itemID, false)
+}
+
 func (s *Server) handleUIItems(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -462,28 +683,51 @@ func (s *Server) handleUIItems(w http.ResponseWriter, r *http.Request) {
 	page := parseInt(r.URL.Query().Get("page"), 1)
 	perPage := perPageValue(r.URL.Query().Get("per_page"))
 
-	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, q, tagFilter, sort)
+	view, filter := itemsViewFilter(r.URL.Query().Get("view"))
+	filter.Query = q
+	filter.Tag = tagFilter
+	filter.Sort = sort
+
+	ws, inWorkspace := workspaceFromContext(r.Context())
+	filter.Workspace = ws.ID
+
+	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, filter)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	var tags []store.Tag
+	if inWorkspace {
+		tags, _ = s.store.ListWorkspaceTags(r.Context(), ws.ID)
+	} else {
+		tags, _ = s.store.ListTagsWithCount(r.Context(), user.ID)
+	}
+	workspaces, err := s.store.ListWorkspaces(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	tags, _ := s.store.ListTagsWithCount(r.Context(), user.ID)
 
 	data := map[string]interface{}{
 		"Title":          "Items",
 		"User":           user,
 		"Items":          items,
 		"Tags":           tags,
+		"Workspace":      ws,
+		"Workspaces":     workspaces,
+		"ReadOnly":       inWorkspace && !store.RoleAtLeast(ws.Role, store.RoleEditor),
 		"Page":           pag.Page,
 		"PerPage":        pag.PerPage,
 		"TotalPages":     max(1, (pag.Total+pag.PerPage-1)/pag.PerPage),
 		"Query":          q,
+		"View":           view,
 		"Sort":           defaultSort(sort),
 		"PerPageOptions": []int{10, 20, 30, 40, 50},
 		"PrevURL":        pageURL(r.URL, pag.Page-1),
 		"NextURL":        pageURL(r.URL, pag.Page+1),
 		"CSRFToken":      s.csrfFromContext(r.Context()),
 		"QuickAddNotice": quickAddNotice(r.URL.Query().Get("quick_add")),
+		"TrashedID":      r.URL.Query().Get("trashed"),
 	}
 
 	if err := s.renderer.Render(w, "items", data); err != nil {
@@ -503,11 +747,43 @@ func (s *Server) handleUIItem(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	// Collections are personal, so shared items show their workspace instead.
+	var ws store.Workspace
+	var memberOf, collections []store.Collection
+	if item.WorkspaceID != nil {
+		ws, err = s.store.GetWorkspace(r.Context(), user.ID, *item.WorkspaceID)
+	} else {
+		memberOf, err = s.store.ItemCollections(r.Context(), user.ID, id)
+		if err == nil {
+			collections, err = s.store.ListCollections(r.Context(), user.ID)
+		}
+	}
+	readOnly := item.WorkspaceID != nil && !store.RoleAtLeast(ws.Role, store.RoleEditor)
+	var shares []store.ItemShare
+	if err == nil && !readOnly {
+		shares, err = s.store.ListItemShares(r.Context(), user.ID, id)
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	var readerHTML template.HTML
+	if detailView(r.URL.Query().Get("view"), readOnly, len(item.Highlights) > 0) == "reader" {
+		readerHTML = template.HTML(sanitize.HTML(item.ContentHTML))
+	}
 	data := map[string]interface{}{
-		"Title":     "Item",
-		"User":      user,
-		"Item":      item,
-		"CSRFToken": s.csrfFromContext(r.Context()),
+		"Title":       "Item",
+		"User":        user,
+		"Item":        item,
+		"ReaderHTML":  readerHTML,
+		"Segments":    highlightSegments(item),
+		"MemberOf":    memberOf,
+		"Collections": collections,
+		"Workspace":   ws,
+		"ReadOnly":    readOnly,
+		"Shares":      activeShares(shares, time.Now()),
+		"Notice":      itemNotice(r.URL.Query().Get("notice")),
+		"CSRFToken":   s.csrfFromContext(r.Context()),
 	}
 	if err := s.renderer.Render(w, "detail", data); err != nil {
 		http.Error(w, "render error", http.StatusInternalServerError)
@@ -522,6 +798,7 @@ func (s *Server) handleUIQuickAdd(w http.ResponseWriter, r *http.Request) {
 		strings.TrimSpace(r.URL.Query().Get("url")),
 		strings.TrimSpace(r.URL.Query().Get("title")),
 		strings.TrimSpace(r.URL.Query().Get("tags")),
+		r.URL.Query().Get("workspace"),
 		"",
 	)
 }
@@ -536,46 +813,60 @@ func (s *Server) handleUIQuickAddSubmit(w http.ResponseWriter, r *http.Request)
 	urlValue := strings.TrimSpace(r.PostFormValue("url"))
 	titleValue := strings.TrimSpace(r.PostFormValue("title"))
 	tagsValue := strings.TrimSpace(r.PostFormValue("tags"))
+	workspaceValue := r.PostFormValue("workspace_id")
 
-	csrfExpected := s.csrfFromContext(r.Context())
-	csrfProvided := r.PostFormValue("csrf_token")
-	if csrfExpected == "" || csrfProvided == "" || csrfProvided != csrfExpected {
-		s.renderUIQuickAdd(w, r, http.StatusForbidden, urlValue, titleValue, tagsValue, "CSRF token mismatch.")
+	if !s.validFormCSRF(r) {
+		s.renderUIQuickAdd(w, r, http.StatusForbidden, urlValue, titleValue, tagsValue, workspaceValue, "CSRF token mismatch.")
 		return
 	}
 	if urlValue == "" {
-		s.renderUIQuickAdd(w, r, http.StatusBadRequest, urlValue, titleValue, tagsValue, "URL is required.")
+		s.renderUIQuickAdd(w, r, http.StatusBadRequest, urlValue, titleValue, tagsValue, workspaceValue, "URL is required.")
 		return
 	}
 	if !s.limiter.Allow(user.ID) {
-		s.renderUIQuickAdd(w, r, http.StatusTooManyRequests, urlValue, titleValue, tagsValue, "Too many requests. Please wait and retry.")
+		s.renderUIQuickAdd(w, r, http.StatusTooManyRequests, urlValue, titleValue, tagsValue, workspaceValue, "Too many requests. Please wait and retry.")
 		return
 	}
 
-	_, created, err := s.createItem(r.Context(), user.ID, urlValue, parseTagInput(tagsValue))
+	_, created, err := s.createItem(r.Context(), user.ID, workspaceValue, urlValue, parseTagInput(tagsValue))
 	if err != nil {
 		if errors.Is(err, errInvalidURL) {
-			s.renderUIQuickAdd(w, r, http.StatusBadRequest, urlValue, titleValue, tagsValue, "Invalid URL.")
+			s.renderUIQuickAdd(w, r, http.StatusBadRequest, urlValue, titleValue, tagsValue, workspaceValue, "Invalid URL.")
 			return
 		}
-		s.renderUIQuickAdd(w, r, http.StatusInternalServerError, urlValue, titleValue, tagsValue, "Failed to save item.")
+		if errors.Is(err, store.ErrWorkspaceForbidden) {
+			s.renderUIQuickAdd(w, r, http.StatusForbidden, urlValue, titleValue, tagsValue, workspaceValue, "You can't save to that workspace.")
+			return
+		}
+		s.renderUIQuickAdd(w, r, http.StatusInternalServerError, urlValue, titleValue, tagsValue, workspaceValue, "Failed to save item.")
 		return
 	}
 
+	back := "/ui/items?quick_add="
+	if workspaceValue != "" {
+		back = "/ui/items?workspace=" + url.QueryEscape(workspaceValue) + "&quick_add="
+	}
 	if created {
-		http.Redirect(w, r, "/ui/items?quick_add=created", http.StatusFound)
+		http.Redirect(w, r, back+"created", http.StatusFound)
 		return
 	}
-	http.Redirect(w, r, "/ui/items?quick_add=exists", http.StatusFound)
+	http.Redirect(w, r, back+"exists", http.StatusFound)
 }
 
-func (s *Server) renderUIQuickAdd(w http.ResponseWriter, r *http.Request, status int, urlValue, titleValue, tagsValue, errMsg string) {
+func (s *Server) renderUIQuickAdd(w http.ResponseWriter, r *http.Request, status int, urlValue, titleValue, tagsValue, workspaceValue, errMsg string) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	workspaces, err := s.store.ListWorkspaces(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
 	data := map[string]interface{}{
+		"Workspaces":  workspaces,
+		"WorkspaceID": workspaceValue,
 		"Title":       "Quick Add",
 		"User":        user,
 		"CSRFToken":   s.csrfFromContext(r.Context()),
@@ -593,61 +884,109 @@ func (s *Server) renderUIQuickAdd(w http.ResponseWriter, r *http.Request, status
 	}
 }
 
+// requireAuth requires the read scope for GET and HEAD requests and the write scope
+// for everything else.
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireScope("", next)
+}
+
+// requireAdmin guards endpoints that manage credentials and integrations.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireScope(auth.ScopeAdmin, next)
+}
+
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := s.checkCSRF(r); err != nil {
 			writeJSON(w, http.StatusForbidden, map[string]string{"error": "csrf"})
 			return
 		}
-		user, ok := s.authenticate(r)
+		user, granted, ok := s.authenticate(r)
 		if !ok {
 			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 			return
 		}
+		required := scope
+		if required == "" {
+			required = methodScope(r.Method)
+		}
+		if !auth.ScopeAllows(granted, required) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "insufficient_scope"})
+			return
+		}
 		ctx := auth.ContextWithUser(r.Context(), user)
+		ctx, status, code := s.workspaceContext(ctx, r, user.ID)
+		if status != 0 {
+			writeJSON(w, status, map[string]string{"error": code})
+			return
+		}
 		next(w, r.WithContext(ctx))
 	}
 }
 
+func methodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return auth.ScopeRead
+	default:
+		return auth.ScopeWrite
+	}
+}
+
 func (s *Server) requireWeb(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sess, user, ok := s.webSession(r)
 		if !ok {
-			http.Redirect(w, r, "/v1/auth/google/login", http.StatusFound)
+			loginURL := "/v1/auth/google/login"
+			if r.Method == http.MethodGet {
+				loginURL += "?next=" + url.QueryEscape(r.URL.RequestURI())
+			}
+			http.Redirect(w, r, loginURL, http.StatusFound)
 			return
 		}
-		ctx := auth.ContextWithUser(r.Context(), auth.User{
-			ID:        user.ID,
-			GoogleSub: user.GoogleSub,
-			Email:     user.Email,
-			Name:      user.Name,
-			AvatarURL: user.AvatarURL,
-		})
+		ctx := auth.ContextWithUser(r.Context(), toAuthUser(user))
 		ctx = context.WithValue(ctx, csrfKey, sess.CSRFToken)
+		ctx, status, code := s.workspaceContext(ctx, r, user.ID)
+		if status != 0 {
+			http.Error(w, strings.ReplaceAll(code, "_", " "), status)
+			return
+		}
 		next(w, r.WithContext(ctx))
 	}
 }
 
-func (s *Server) authenticate(r *http.Request) (auth.User, bool) {
+// personalTokenPrefix marks personal access tokens so that authenticate can tell them
+// from JWTs and secret scanners can recognize them.
+const personalTokenPrefix = "altp_"
+
+// authenticate resolves the request's credentials to a user and the scope they grant.
+func (s *Server) authenticate(r *http.Request) (auth.User, string, bool) {
 	// Prefer Authorization for API
 	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
 		token := strings.TrimPrefix(authz, "Bearer ")
-		userID, err := auth.ParseJWT(s.cfg.JWTSecret, token)
+		if strings.HasPrefix(token, personalTokenPrefix) {
+			usr, scope, err := s.store.GetUserByPersonalToken(r.Context(), auth.HashToken(token))
+			if err != nil {
+				return auth.User{}, "", false
+			}
+			return toAuthUser(usr), scope, true
+		}
+		claims, err := auth.ParseJWT(s.cfg.JWTSecret, token)
 		if err != nil {
-			return auth.User{}, false
+			return auth.User{}, "", false
 		}
-		usr, err := s.store.GetUserByID(r.Context(), userID)
+		usr, err := s.store.GetUserByAccessToken(r.Context(), claims.UserID, claims.DeviceID, claims.ID)
 		if err != nil {
-			return auth.User{}, false
+			return auth.User{}, "", false
 		}
-		return auth.User{ID: usr.ID, GoogleSub: usr.GoogleSub, Email: usr.Email, Name: usr.Name, AvatarURL: usr.AvatarURL}, true
+		return toAuthUser(usr), auth.ScopeAdmin, true
 	}
 	// Fallback to web session
 	_, user, ok := s.webSession(r)
 	if ok {
-		return auth.User{ID: user.ID, GoogleSub: user.GoogleSub, Email: user.Email, Name: user.Name, AvatarURL: user.AvatarURL}, true
+		return toAuthUser(user), auth.ScopeAdmin, true
 	}
-	return auth.User{}, false
+	return auth.User{}, "", false
 }
 
 func (s *Server) webSession(r *http.Request) (store.Session, store.User, bool) {
@@ -666,6 +1005,17 @@ func (s *Server) webSession(r *http.Request) (store.Session, store.User, bool) {
 	return sess, user, true
 }
 
+func toAuthUser(u store.User) auth.User {
+	return auth.User{ID: u.ID, GoogleSub: u.GoogleSub, Email: u.Email, Name: u.Name, AvatarURL: u.AvatarURL}
+}
+
+// validFormCSRF reports whether a form post carries the web session's CSRF token.
+func (s *Server) validFormCSRF(r *http.Request) bool {
+	expected := s.csrfFromContext(r.Context())
+	provided := r.PostFormValue("csrf_token")
+	return expected != "" && provided != "" && provided == expected
+}
+
 func (s *Server) csrfFromContext(ctx context.Context) string {
 	v := ctx.Value(csrfKey)
 	if v == nil {
@@ -691,8 +1041,8 @@ func (s *Server) requestID(ctx context.Context) string {
 func (s *Server) cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Accept, X-Workspace-ID")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -726,14 +1076,14 @@ func (s *Server) checkCSRF(r *http.Request) error {
 	return nil
 }
 
-func (s *Server) createItem(ctx context.Context, userID, rawURL string, rawTags []string) (string, bool, error) {
+func (s *Server) createItem(ctx context.Context, userID, workspaceID, rawURL string, rawTags []string) (string, bool, error) {
 	canonicalURL, canonicalHash, err := urlnorm.Canonicalize(rawURL)
 	if err != nil {
 		return "", false, errInvalidURL
 	}
 
-	normTags := normalizeTagNames(rawTags)
-	itemID, created, err := s.store.CreateItem(ctx, userID, rawURL, canonicalURL, canonicalHash, normTags)
+	normTags := tag.NormalizeAll(rawTags)
+	itemID, created, err := s.store.CreateItem(ctx, userID, workspaceID, rawURL, canonicalURL, canonicalHash, normTags)
 	if err != nil {
 		return "", false, err
 	}
@@ -747,28 +1097,16 @@ func (s *Server) createItem(ctx context.Context, userID, rawURL string, rawTags
 	return itemID, false, nil
 }
 
-func normalizeTagNames(rawTags []string) []string {
-	normTags := make([]string, 0, len(rawTags))
-	seen := map[string]struct{}{}
-	for _, t := range rawTags {
-		norm := tag.Normalize(t)
-		if norm == "" {
-			continue
-		}
-		if _, ok := seen[norm]; ok {
-			continue
-		}
-		seen[norm] = struct{}{}
-		normTags = append(normTags, norm)
-	}
-	return normTags
-}
-
 func parseTagInput(v string) []string {
 	parts := strings.FieldsFunc(v, func(r rune) bool {
 		return r == ',' || r == ';' || r == '\n' || r == '\r'
 	})
-	return normalizeTagNames(parts)
+	return tag.NormalizeAll(parts)
+}
+
+// safeNextPath only allows same-origin relative paths as post-login destinations.
+func safeNextPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.HasPrefix(p, "/\\")
 }
 
 func quickAddNotice(state string) string {
@@ -799,6 +1137,10 @@ func parseInt(v string, fallback int) int {
 	return i
 }
 
+func parseBool(v string) bool {
+	return v == "1" || v == "true"
+}
+
 func perPageValue(v string) int {
 	allowed := map[int]struct{}{10: {}, 20: {}, 30: {}, 40: {}, 50: {}}
 	parsed := parseInt(v, 30)
@@ -815,6 +1157,68 @@ func defaultSort(v string) string {
 	return "newest"
 }
 
+// listSort is defaultSort, except that a collection listing follows the collection's
+// manual order unless another sort is asked for.
+func listSort(v, collection string) string {
+	if collection != "" && (v == "" || v == "position") {
+		return "position"
+	}
+	return defaultSort(v)
+}
+
+// stateFilter maps the state query parameter to a store state; anything else lists all items.
+func stateFilter(v string) string {
+	switch v {
+	case "unread":
+		return store.ItemStateUnread
+	case "archived", "archive":
+		return store.ItemStateArchived
+	default:
+		return ""
+	}
+}
+
+func favoriteFilter(v string) *bool {
+	switch v {
+	case "1", "true":
+		t := true
+		return &t
+	case "0", "false":
+		f := false
+		return &f
+	default:
+		return nil
+	}
+}
+
+// itemsViewFilter maps a /ui/items tab to its filter. The unread queue is the default.
+func itemsViewFilter(view string) (string, store.ItemFilter) {
+	switch view {
+	case "archive":
+		return view, store.ItemFilter{State: store.ItemStateArchived}
+	case "favorites":
+		return view, store.ItemFilter{Favorite: favoriteFilter("true")}
+	case "all":
+		return view, store.ItemFilter{}
+	default:
+		return "unread", store.ItemFilter{State: store.ItemStateUnread}
+	}
+}
+
+// detailView picks how the item detail page shows the article. Highlights are drawn and
+// made only in the text view, so it is the default unless the viewer is read-only and
+// there are no highlights to show; the reader view can always be asked for.
+func detailView(view string, readOnly, hasHighlights bool) string {
+	switch {
+	case view == "text" || view == "reader":
+		return view
+	case readOnly && !hasHighlights:
+		return "reader"
+	default:
+		return "text"
+	}
+}
+
 func pageURL(u *url.URL, page int) string {
 	if page < 1 {
 		page = 1
@@ -1,8 +1,18 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/importer"
+	"altpocket/internal/store"
 )
 
 func TestPerPageValue(t *testing.T) {
@@ -55,3 +65,297 @@ func TestQuickAddNotice(t *testing.T) {
 		t.Fatalf("unexpected notice for unknown state")
 	}
 }
+
+func TestSafeNextPath(t *testing.T) {
+	for _, p := range []string{"/ui/items", "/auth/authorize?request_token=x"} {
+		if !safeNextPath(p) {
+			t.Fatalf("expected %q to be allowed", p)
+		}
+	}
+	for _, p := range []string{"", "https://evil.example", "//evil.example", "/\\evil.example", "ui/items"} {
+		if safeNextPath(p) {
+			t.Fatalf("expected %q to be rejected", p)
+		}
+	}
+}
+
+func TestStateAndFavoriteFilters(t *testing.T) {
+	if stateFilter("archive") != "archived" || stateFilter("unread") != "unread" || stateFilter("all") != "" {
+		t.Fatalf("unexpected state filter mapping")
+	}
+	if f := favoriteFilter("true"); f == nil || !*f {
+		t.Fatalf("expected favorite=true filter")
+	}
+	if f := favoriteFilter("0"); f == nil || *f {
+		t.Fatalf("expected favorite=false filter")
+	}
+	if favoriteFilter("") != nil {
+		t.Fatalf("expected no favorite filter")
+	}
+}
+
+func TestItemsViewFilterDefaultsToUnread(t *testing.T) {
+	view, filter := itemsViewFilter("")
+	if view != "unread" || filter.State != "unread" {
+		t.Fatalf("expected unread default, got %q %#v", view, filter)
+	}
+	view, filter = itemsViewFilter("favorites")
+	if view != "favorites" || filter.State != "" || filter.Favorite == nil || !*filter.Favorite {
+		t.Fatalf("unexpected favorites filter: %q %#v", view, filter)
+	}
+	if _, filter = itemsViewFilter("archive"); filter.State != "archived" {
+		t.Fatalf("unexpected archive filter: %#v", filter)
+	}
+}
+
+func TestDetailViewKeepsHighlightsVisible(t *testing.T) {
+	cases := []struct {
+		view                    string
+		readOnly, hasHighlights bool
+		want                    string
+	}{
+		{"", false, false, "text"},
+		{"", true, true, "text"},
+		{"", true, false, "reader"},
+		{"reader", false, true, "reader"},
+		{"text", true, false, "text"},
+		{"bogus", false, false, "text"},
+	}
+	for _, tc := range cases {
+		if got := detailView(tc.view, tc.readOnly, tc.hasHighlights); got != tc.want {
+			t.Fatalf("detailView(%q, %v, %v) = %q, want %q", tc.view, tc.readOnly, tc.hasHighlights, got, tc.want)
+		}
+	}
+}
+
+func TestImportErrorCode(t *testing.T) {
+	if got := importErrorCode(importer.ErrUnknownFormat); got != "unsupported_format" {
+		t.Fatalf("unexpected code: %q", got)
+	}
+	if got := importErrorCode(fmt.Errorf("%w: bad quote", importer.ErrMalformed)); got != "invalid_file" {
+		t.Fatalf("unexpected code: %q", got)
+	}
+	if got := importErrorCode(errors.New("db down")); got != "" {
+		t.Fatalf("expected db errors to be unmapped, got %q", got)
+	}
+}
+
+func TestTagsNotice(t *testing.T) {
+	if tagsNotice("exists") == "" || tagsNotice("merged") == "" {
+		t.Fatalf("expected notices for known states")
+	}
+	if tagsNotice("<script>") != "" {
+		t.Fatalf("unknown states should not produce a notice")
+	}
+}
+
+func TestTrashNotice(t *testing.T) {
+	if trashNotice("emptied") == "" {
+		t.Fatalf("expected a notice for emptied")
+	}
+	if trashNotice("restored") != "" {
+		t.Fatalf("unknown states should not produce a notice")
+	}
+}
+
+func TestItemEdit(t *testing.T) {
+	str := func(v string) *string { return &v }
+
+	if _, err := itemEdit(nil, nil, nil, nil); !errors.Is(err, errEditEmpty) {
+		t.Fatalf("expected empty edit error, got %v", err)
+	}
+	edit, err := itemEdit(str("  New title "), str(""), nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *edit.Title != "New title" || *edit.Excerpt != "" || edit.Note != nil || edit.CanonicalHash != "" {
+		t.Fatalf("unexpected edit: %+v", edit)
+	}
+	if _, err := itemEdit(str(strings.Repeat("あ", maxTitleLength+1)), nil, nil, nil); !errors.Is(err, errEditTooLong) {
+		t.Fatalf("expected too long error, got %v", err)
+	}
+	for _, raw := range []string{"", "not a url", "javascript:alert(1)", "ftp://example.com/file"} {
+		if _, err := itemEdit(nil, nil, nil, str(raw)); !errors.Is(err, errInvalidURL) {
+			t.Fatalf("expected invalid url for %q, got %v", raw, err)
+		}
+	}
+	edit, err = itemEdit(nil, nil, nil, str("https://example.com/a/?utm_source=x"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if edit.CanonicalURL != "https://example.com/a" || edit.CanonicalHash == "" {
+		t.Fatalf("expected canonicalized url, got %+v", edit)
+	}
+}
+
+func TestItemNotice(t *testing.T) {
+	if itemNotice("saved") == "" || itemNotice("exists") == "" {
+		t.Fatalf("expected notices for known states")
+	}
+	if itemNotice("<script>") != "" {
+		t.Fatalf("unknown states should not produce a notice")
+	}
+}
+
+func TestHighlightSegmentsSkipsDetached(t *testing.T) {
+	item := store.ItemDetail{
+		ContentFull: "one two three",
+		Highlights: []store.Highlight{
+			{ID: "a", Start: 4, End: 7, Anchored: true},
+			{ID: "gone", Start: -1, End: -1},
+		},
+	}
+	got := highlightSegments(item)
+	if len(got) != 3 || got[1].ID != "a" || got[1].Text != "two" {
+		t.Fatalf("unexpected segments: %+v", got)
+	}
+}
+
+func TestListSort(t *testing.T) {
+	cases := []struct {
+		sort, collection, want string
+	}{
+		{"", "", "newest"},
+		{"position", "", "newest"},
+		{"", "c1", "position"},
+		{"position", "c1", "position"},
+		{"relevance", "c1", "relevance"},
+		{"newest", "c1", "newest"},
+	}
+	for _, tc := range cases {
+		if got := listSort(tc.sort, tc.collection); got != tc.want {
+			t.Fatalf("listSort(%q, %q) = %q, want %q", tc.sort, tc.collection, got, tc.want)
+		}
+	}
+}
+
+func TestCollectionFields(t *testing.T) {
+	str := func(v string) *string { return &v }
+
+	title, desc, err := collectionFields(str("  Onboarding "), nil)
+	if err != nil || *title != "Onboarding" || desc != nil {
+		t.Fatalf("unexpected result: %v %v %v", title, desc, err)
+	}
+	if _, _, err := collectionFields(str("   "), nil); !errors.Is(err, errInvalidCollection) {
+		t.Fatalf("expected blank title to be rejected, got %v", err)
+	}
+	if _, _, err := collectionFields(nil, str(strings.Repeat("x", maxCollectionDescription+1))); !errors.Is(err, errInvalidCollection) {
+		t.Fatalf("expected long description to be rejected, got %v", err)
+	}
+}
+
+func TestWorkspaceRoleFor(t *testing.T) {
+	for _, m := range []string{"GET", "HEAD", "OPTIONS"} {
+		if got := workspaceRoleFor(m); got != store.RoleViewer {
+			t.Fatalf("%s: expected viewer, got %q", m, got)
+		}
+	}
+	for _, m := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		if got := workspaceRoleFor(m); got != store.RoleEditor {
+			t.Fatalf("%s: expected editor, got %q", m, got)
+		}
+	}
+}
+
+func TestWorkspaceName(t *testing.T) {
+	if name, err := workspaceName("  Research "); err != nil || name != "Research" {
+		t.Fatalf("unexpected result: %q %v", name, err)
+	}
+	for _, v := range []string{"", "   ", strings.Repeat("x", maxWorkspaceName+1)} {
+		if _, err := workspaceName(v); !errors.Is(err, errInvalidWorkspace) {
+			t.Fatalf("expected %q to be rejected, got %v", v, err)
+		}
+	}
+}
+
+func TestShareExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	if err := shareExpiry(nil, now); err != nil {
+		t.Fatalf("links without expiry should be allowed, got %v", err)
+	}
+	if err := shareExpiry(&later, now); err != nil {
+		t.Fatalf("future expiry should be allowed, got %v", err)
+	}
+	if err := shareExpiry(&earlier, now); !errors.Is(err, errInvalidExpiry) {
+		t.Fatalf("expected past expiry to be rejected, got %v", err)
+	}
+	if err := shareExpiry(&now, now); !errors.Is(err, errInvalidExpiry) {
+		t.Fatalf("expected expiry at now to be rejected, got %v", err)
+	}
+}
+
+func TestActiveSharesDropsExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	got := activeShares([]store.ItemShare{{ID: "open"}, {ID: "old", ExpiresAt: &past}, {ID: "soon", ExpiresAt: &future}}, now)
+	if len(got) != 2 || got[0].ID != "open" || got[1].ID != "soon" {
+		t.Fatalf("unexpected shares: %+v", got)
+	}
+}
+
+func TestWebURL(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{" https://example.com/feed.xml ", "https://example.com/feed.xml"},
+		{"http://example.com/rss?format=full#top", "http://example.com/rss?format=full"},
+		{"feed://example.com/rss", ""},
+		{"/relative/feed", ""},
+		{"", ""},
+		{"https://example.com/" + strings.Repeat("a", maxWebURLLength), ""},
+	}
+	for _, tc := range cases {
+		got, err := webURL(tc.in)
+		if tc.want == "" {
+			if !errors.Is(err, errInvalidURL) {
+				t.Fatalf("webURL(%q) = %q, %v; want errInvalidURL", tc.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("webURL(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	got, err := webhookEvents([]string{"item.tagged", "item.created", "item.tagged"})
+	if err != nil || strings.Join(got, ",") != "item.tagged,item.created" {
+		t.Fatalf("webhookEvents = %v, %v", got, err)
+	}
+	for _, in := range [][]string{nil, {}, {"item.created", "item.archived"}} {
+		if _, err := webhookEvents(in); !errors.Is(err, errInvalidEvents) {
+			t.Fatalf("webhookEvents(%v) err = %v, want errInvalidEvents", in, err)
+		}
+	}
+}
+
+func TestMethodScope(t *testing.T) {
+	for method, want := range map[string]string{
+		http.MethodGet:    auth.ScopeRead,
+		http.MethodHead:   auth.ScopeRead,
+		http.MethodPost:   auth.ScopeWrite,
+		http.MethodPatch:  auth.ScopeWrite,
+		http.MethodDelete: auth.ScopeWrite,
+	} {
+		if got := methodScope(method); got != want {
+			t.Fatalf("methodScope(%s) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	if got := deviceName("  Chrome   on\tmacOS "); got != "Chrome on macOS" {
+		t.Fatalf("deviceName = %q", got)
+	}
+	if got := deviceName(""); got != "Browser extension" {
+		t.Fatalf("deviceName(empty) = %q", got)
+	}
+	long := deviceName(strings.Repeat("é", maxDeviceNameLength))
+	if len(long) > maxDeviceNameLength || !utf8.ValidString(long) {
+		t.Fatalf("deviceName did not truncate on a rune boundary: %d bytes", len(long))
+	}
+}
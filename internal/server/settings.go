@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+
+	"altpocket/internal/auth"
+)
+
+func (s *Server) handleUISettings(w http.ResponseWriter, r *http.Request) {
+	s.renderUISettings(w, r, nil)
+}
+
+// renderUISettings renders the settings page. extra adds page data, such as a secret that
+// is only shown right after it is created.
+func (s *Server) renderUISettings(w http.ResponseWriter, r *http.Request, extra map[string]interface{}) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	tokens, err := s.store.ListOAuthTokens(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	apps, err := s.store.ListOAuthApps(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	feedToken, err := s.store.GetFeedToken(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	personalTokens, err := s.store.ListPersonalTokens(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	devices, err := s.store.ListExtensionDevices(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":          "Settings",
+		"User":           user,
+		"CSRFToken":      s.csrfFromContext(r.Context()),
+		"Notice":         settingsNotice(r.URL.Query().Get("notice")),
+		"OAuthTokens":    tokens,
+		"OAuthApps":      apps,
+		"FeedToken":      feedToken,
+		"PersonalTokens": personalTokens,
+		"Devices":        devices,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	if err := s.renderer.Render(w, "settings", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+// handleUICreateFeedToken issues a new feed token and shows its feed URLs once; any
+// previous feed URLs stop working.
+func (s *Server) handleUICreateFeedToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	token, err := s.randomString(24)
+	if err != nil {
+		http.Error(w, "failed", http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.SetFeedToken(r.Context(), user.ID, auth.HashToken(token)); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.renderUISettings(w, r, map[string]interface{}{
+		"Notice":   settingsNotice("feed_created"),
+		"FeedURLs": s.feedURLs(token),
+	})
+}
+
+func (s *Server) handleUIDeleteFeedToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if err := s.store.DeleteFeedToken(r.Context(), user.ID); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=feed_deleted", http.StatusFound)
+}
+
+func settingsNotice(state string) string {
+	switch state {
+	case "app_created":
+		return "App registered. Use its consumer key in your Pocket client."
+	case "app_invalid":
+		return "App name is required (100 characters max)."
+	case "app_deleted":
+		return "App deleted. Tokens issued to it no longer work."
+	case "token_revoked":
+		return "Access revoked."
+	case "feed_created":
+		return "Feed links created. Copy them now; they are not shown again."
+	case "feed_deleted":
+		return "Feeds disabled. Existing feed links no longer work."
+	case "device_revoked":
+		return "Extension signed out. It has to sign in again to save pages."
+	case "pat_created":
+		return "Token created. Copy it now; it is not shown again."
+	case "pat_invalid":
+		return "Token name is required (100 characters max) and the scope must be read, write or admin."
+	case "pat_revoked":
+		return "Token revoked. Requests using it are rejected."
+	default:
+		return ""
+	}
+}
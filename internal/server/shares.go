@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+var errInvalidExpiry = errors.New("invalid_expiry")
+
+// handleCreateShare issues a public link to the item. The link is only returned here;
+// the server keeps a hash of its token.
+func (s *Server) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	// An empty body shares without expiry.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := shareExpiry(req.ExpiresAt, time.Now()); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	token, err := s.randomString(24)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_error"})
+		return
+	}
+	share, err := s.store.CreateItemShare(r.Context(), user.ID, chi.URLParam(r, "id"), auth.HashToken(token), req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	s.logger.Info("items.share", slog.String("item_id", share.ItemID), slog.String("share_id", share.ID), slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"share": share,
+		"token": token,
+		"url":   s.shareURL(token),
+	})
+}
+
+func (s *Server) handleListShares(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	shares, err := s.store.ListItemShares(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
+
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	err := s.store.RevokeItemShare(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "shareID"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"revoked": true})
+}
+
+// handleSharedItem renders the public reader page for a share link. It needs no login and
+// shows only the page's title, source and extracted text.
+func (s *Server) handleSharedItem(w http.ResponseWriter, r *http.Request) {
+	// Keep the token out of Referer headers sent to the source site, and out of caches so
+	// revocation takes effect immediately.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	status := http.StatusOK
+	data := map[string]interface{}{"Title": "Shared article", "Public": true}
+	item, err := s.store.OpenSharedItem(r.Context(), auth.HashToken(chi.URLParam(r, "token")))
+	switch {
+	case err == nil:
+		if item.Title != "" {
+			data["Title"] = item.Title
+		}
+		data["Item"] = item
+	case errors.Is(err, pgx.ErrNoRows):
+		status = http.StatusNotFound
+	default:
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+	}
+	if err := s.renderer.Render(w, "shared", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) shareURL(token string) string {
+	return strings.TrimRight(s.cfg.PublicBaseURL, "/") + "/s/" + token
+}
+
+// shareExpiry rejects expiry times that are not in the future.
+func shareExpiry(expiresAt *time.Time, now time.Time) error {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return errInvalidExpiry
+	}
+	return nil
+}
+
+// activeShares drops expired links for display.
+func activeShares(shares []store.ItemShare, now time.Time) []store.ItemShare {
+	active := make([]store.ItemShare, 0, len(shares))
+	for _, sh := range shares {
+		if !sh.Expired(now) {
+			active = append(active, sh)
+		}
+	}
+	return active
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/subscription"
+	"altpocket/internal/tag"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxWebURLLength bounds feed and webhook URLs.
+const maxWebURLLength = 2048
+
+func (s *Server) handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	subs, err := s.store.ListSubscriptions(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": subs})
+}
+
+func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		URL  string   `json:"url"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	feedURL, err := webURL(req.URL)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	sub, err := s.store.CreateSubscription(r.Context(), user.ID, feedURL, "", "", tag.NormalizeAll(req.Tags))
+	if err != nil {
+		if errors.Is(err, store.ErrSubscriptionExists) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "subscription_exists"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	s.logger.Info("subscriptions.create", slog.String("subscription_id", sub.ID), slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+// handleUpdateSubscription replaces the default tags; items already saved keep theirs.
+func (s *Server) handleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	sub, err := s.store.SetSubscriptionTags(r.Context(), user.ID, chi.URLParam(r, "id"), tag.NormalizeAll(req.Tags))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.DeleteSubscription(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleExportOPML(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	subs, err := s.store.ListSubscriptions(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	outlines := make([]subscription.Outline, 0, len(subs))
+	for _, sub := range subs {
+		outlines = append(outlines, subscription.Outline{Title: sub.Title, FeedURL: sub.FeedURL, SiteURL: sub.SiteURL, Tags: sub.Tags})
+	}
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="altpocket-subscriptions.opml"`)
+	if err := subscription.WriteOPML(w, "altpocket subscriptions", outlines); err != nil {
+		s.logger.Error("subscriptions.export_failed", slog.String("error", err.Error()), slog.String("request_id", s.requestID(r.Context())))
+	}
+}
+
+// handleImportOPML follows every feed in an uploaded OPML file. Feeds already followed
+// are counted as existing and left unchanged.
+func (s *Server) handleImportOPML(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	_, data, err := readImportUpload(w, r)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "too_large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	res, err := s.importOPML(r.Context(), user.ID, data)
+	if err != nil {
+		if errors.Is(err, subscription.ErrMalformedOPML) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_file"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+type opmlImportResult struct {
+	Added    int `json:"added"`
+	Existing int `json:"existing"`
+	Invalid  int `json:"invalid"`
+}
+
+func (s *Server) importOPML(ctx context.Context, userID string, data []byte) (opmlImportResult, error) {
+	var res opmlImportResult
+	outlines, err := subscription.ParseOPML(data)
+	if err != nil {
+		return res, err
+	}
+	for _, o := range outlines {
+		feedURL, err := webURL(o.FeedURL)
+		if err != nil {
+			res.Invalid++
+			continue
+		}
+		siteURL := o.SiteURL
+		if len(siteURL) > maxWebURLLength {
+			siteURL = ""
+		}
+		_, err = s.store.CreateSubscription(ctx, userID, feedURL, o.Title, siteURL, o.Tags)
+		switch {
+		case errors.Is(err, store.ErrSubscriptionExists):
+			res.Existing++
+		case err != nil:
+			return res, err
+		default:
+			res.Added++
+		}
+	}
+	s.logger.Info("subscriptions.import",
+		slog.String("user_id", userID),
+		slog.Int("added", res.Added),
+		slog.Int("existing", res.Existing),
+		slog.Int("invalid", res.Invalid),
+		slog.String("request_id", s.requestID(ctx)))
+	return res, nil
+}
+
+func writeSubscriptionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+}
+
+// webURL accepts absolute http(s) URLs for feeds and webhooks. The URL is kept as entered
+// rather than canonicalized, since endpoints often depend on query strings.
+func webURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxWebURLLength {
+		return "", errInvalidURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errInvalidURL
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+func (s *Server) handleUISubscriptions(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	subs, err := s.store.ListSubscriptions(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":         "Subscriptions",
+		"User":          user,
+		"CSRFToken":     s.csrfFromContext(r.Context()),
+		"Notice":        subscriptionsNotice(r.URL.Query().Get("notice")),
+		"Subscriptions": subs,
+	}
+	if err := s.renderer.Render(w, "subscriptions", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICreateSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/subscriptions?notice=rate_limited", http.StatusFound)
+		return
+	}
+	feedURL, err := webURL(r.PostFormValue("url"))
+	if err != nil {
+		http.Redirect(w, r, "/ui/subscriptions?notice=invalid_url", http.StatusFound)
+		return
+	}
+	if _, err := s.store.CreateSubscription(r.Context(), user.ID, feedURL, "", "", parseTagInput(r.PostFormValue("tags"))); err != nil {
+		if errors.Is(err, store.ErrSubscriptionExists) {
+			http.Redirect(w, r, "/ui/subscriptions?notice=exists", http.StatusFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/subscriptions?notice=created", http.StatusFound)
+}
+
+func (s *Server) handleUIUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/subscriptions?notice=rate_limited", http.StatusFound)
+		return
+	}
+	if _, err := s.store.SetSubscriptionTags(r.Context(), user.ID, chi.URLParam(r, "id"), parseTagInput(r.PostFormValue("tags"))); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/subscriptions?notice=updated", http.StatusFound)
+}
+
+func (s *Server) handleUIDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/subscriptions?notice=rate_limited", http.StatusFound)
+		return
+	}
+	if err := s.store.DeleteSubscription(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/subscriptions?notice=deleted", http.StatusFound)
+}
+
+func (s *Server) handleUIImportOPML(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	_, data, err := readImportUpload(w, r)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			http.Redirect(w, r, "/ui/subscriptions?notice=too_large", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/ui/subscriptions?notice=invalid_file", http.StatusFound)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/subscriptions?notice=rate_limited", http.StatusFound)
+		return
+	}
+	res, err := s.importOPML(r.Context(), user.ID, data)
+	if err != nil {
+		if errors.Is(err, subscription.ErrMalformedOPML) {
+			http.Redirect(w, r, "/ui/subscriptions?notice=invalid_file", http.StatusFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if res.Added == 0 && res.Existing == 0 {
+		http.Redirect(w, r, "/ui/subscriptions?notice=empty_file", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/ui/subscriptions?notice=imported", http.StatusFound)
+}
+
+func subscriptionsNotice(state string) string {
+	switch state {
+	case "created":
+		return "Subscribed. The feed is checked within a minute; posts published after that are saved to your list."
+	case "exists":
+		return "You already follow that feed."
+	case "invalid_url":
+		return "Enter the full http(s) URL of an RSS, Atom or JSON feed."
+	case "updated":
+		return "Default tags updated. They apply to posts saved from now on."
+	case "deleted":
+		return "Unsubscribed. Items already saved from the feed were kept."
+	case "imported":
+		return "Subscriptions imported. Feeds you already follow were skipped."
+	case "empty_file":
+		return "No feeds were found in that file."
+	case "invalid_file":
+		return "The file could not be read as OPML."
+	case "too_large":
+		return "The file is too large (32 MB max)."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
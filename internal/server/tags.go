@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+	"altpocket/internal/tag"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+var errInvalidTagInput = errors.New("invalid_tag_input")
+
+func (s *Server) handleRenameTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	name := tag.Normalize(req.Name)
+	if name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_name"})
+		return
+	}
+
+	t, err := s.store.RenameTag(r.Context(), user.ID, chi.URLParam(r, "id"), name)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) handleMergeTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+
+	var req struct {
+		TargetID string `json:"target_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TargetID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	t, err := s.store.MergeTag(r.Context(), user.ID, chi.URLParam(r, "id"), req.TargetID)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.DeleteTag(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeTagError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	case errors.Is(err, store.ErrTagExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "tag_exists"})
+	case errors.Is(err, store.ErrTagMergeSelf):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_target"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+	}
+}
+
+func (s *Server) handleUITags(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	tags, err := s.store.ListTagsWithCount(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":     "Tags",
+		"User":      user,
+		"CSRFToken": s.csrfFromContext(r.Context()),
+		"Notice":    tagsNotice(r.URL.Query().Get("notice")),
+		"Tags":      tags,
+	}
+	if err := s.renderer.Render(w, "tags", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUIRenameTag(w http.ResponseWriter, r *http.Request) {
+	s.handleUITagAction(w, r, "renamed", func(userID, tagID string) error {
+		name := tag.Normalize(r.PostFormValue("name"))
+		if name == "" {
+			return errInvalidTagInput
+		}
+		_, err := s.store.RenameTag(r.Context(), userID, tagID, name)
+		return err
+	})
+}
+
+func (s *Server) handleUIMergeTag(w http.ResponseWriter, r *http.Request) {
+	s.handleUITagAction(w, r, "merged", func(userID, tagID string) error {
+		targetID := r.PostFormValue("target_id")
+		if targetID == "" {
+			return errInvalidTagInput
+		}
+		_, err := s.store.MergeTag(r.Context(), userID, tagID, targetID)
+		return err
+	})
+}
+
+func (s *Server) handleUIDeleteTag(w http.ResponseWriter, r *http.Request) {
+	s.handleUITagAction(w, r, "deleted", func(userID, tagID string) error {
+		return s.store.DeleteTag(r.Context(), userID, tagID)
+	})
+}
+
+// handleUITagAction runs a tag form post and redirects back to the tag page with a notice.
+func (s *Server) handleUITagAction(w http.ResponseWriter, r *http.Request, done string, apply func(userID, tagID string) error) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/tags?notice=rate_limited", http.StatusFound)
+		return
+	}
+
+	notice := done
+	if err := apply(user.ID, chi.URLParam(r, "id")); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		case errors.Is(err, store.ErrTagExists):
+			notice = "exists"
+		case errors.Is(err, errInvalidTagInput), errors.Is(err, store.ErrTagMergeSelf):
+			notice = "invalid"
+		default:
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+	http.Redirect(w, r, "/ui/tags?notice="+notice, http.StatusFound)
+}
+
+func tagsNotice(state string) string {
+	switch state {
+	case "renamed":
+		return "Tag renamed."
+	case "merged":
+		return "Tags merged."
+	case "deleted":
+		return "Tag removed from all items."
+	case "exists":
+		return "A tag with that name already exists. Merge the tags instead."
+	case "invalid":
+		return "Choose a different name or target tag."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxTokenNameLength = 100
+
+var (
+	errInvalidTokenName = errors.New("invalid_name")
+	errInvalidScope     = errors.New("invalid_scope")
+)
+
+func (s *Server) handleListPersonalTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	tokens, err := s.store.ListPersonalTokens(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
+}
+
+// handleCreatePersonalToken issues a token and returns it once; only its hash is kept.
+func (s *Server) handleCreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	pt, token, err := s.createPersonalToken(r.Context(), user.ID, req.Name, req.Scope)
+	if err != nil {
+		if errors.Is(err, errInvalidTokenName) || errors.Is(err, errInvalidScope) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"token": pt, "secret": token})
+}
+
+func (s *Server) handleRevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if err := s.store.RevokePersonalToken(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createPersonalToken(ctx context.Context, userID, name, scope string) (store.PersonalToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTokenNameLength {
+		return store.PersonalToken{}, "", errInvalidTokenName
+	}
+	if !auth.ValidScope(scope) {
+		return store.PersonalToken{}, "", errInvalidScope
+	}
+	secret, err := s.randomString(24)
+	if err != nil {
+		return store.PersonalToken{}, "", err
+	}
+	token := personalTokenPrefix + secret
+	pt, err := s.store.CreatePersonalToken(ctx, userID, name, scope, auth.HashToken(token))
+	if err != nil {
+		return store.PersonalToken{}, "", err
+	}
+	s.logger.Info("tokens.create", slog.String("token_id", pt.ID), slog.String("scope", scope), slog.String("request_id", s.requestID(ctx)))
+	return pt, token, nil
+}
+
+func (s *Server) handleUICreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	_, token, err := s.createPersonalToken(r.Context(), user.ID, r.PostFormValue("name"), r.PostFormValue("scope"))
+	if err != nil {
+		if errors.Is(err, errInvalidTokenName) || errors.Is(err, errInvalidScope) {
+			http.Redirect(w, r, "/ui/settings?notice=pat_invalid", http.StatusFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.renderUISettings(w, r, map[string]interface{}{
+		"Notice":           settingsNotice("pat_created"),
+		"NewPersonalToken": token,
+	})
+}
+
+func (s *Server) handleUIRevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if err := s.store.RevokePersonalToken(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/settings?notice=pat_revoked", http.StatusFound)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Server) handleRestoreItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := s.store.RestoreItem(r.Context(), user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		case errors.Is(err, store.ErrItemExists):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "item_exists"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "deleted_at": nil})
+}
+
+func (s *Server) handleUITrash(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	perPage := perPageValue(r.URL.Query().Get("per_page"))
+
+	items, pag, err := s.store.ListItems(r.Context(), user.ID, page, perPage, store.ItemFilter{Trashed: true, Sort: "deleted"})
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":         "Trash",
+		"User":          user,
+		"Items":         items,
+		"Page":          pag.Page,
+		"TotalPages":    max(1, (pag.Total+pag.PerPage-1)/pag.PerPage),
+		"PrevURL":       pageURL(r.URL, pag.Page-1),
+		"NextURL":       pageURL(r.URL, pag.Page+1),
+		"RetentionDays": s.cfg.TrashRetentionDays,
+		"Notice":        trashNotice(r.URL.Query().Get("notice")),
+		"CSRFToken":     s.csrfFromContext(r.Context()),
+	}
+	if err := s.renderer.Render(w, "trash", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUIEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/trash?notice=rate_limited", http.StatusFound)
+		return
+	}
+	removed, err := s.store.EmptyTrash(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("trash.empty",
+		slog.String("user_id", user.ID),
+		slog.Int64("removed", removed),
+		slog.String("request_id", s.requestID(r.Context())))
+	http.Redirect(w, r, "/ui/trash?notice=emptied", http.StatusFound)
+}
+
+func trashNotice(state string) string {
+	switch state {
+	case "emptied":
+		return "Trash emptied."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
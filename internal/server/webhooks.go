@@ -0,0 +1,412 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// maxWebhooks bounds the endpoints per user; each one multiplies every event.
+	maxWebhooks = 10
+	// webhookLogLimit is the number of deliveries shown in the log.
+	webhookLogLimit = 50
+)
+
+var (
+	errInvalidEvents   = errors.New("invalid_events")
+	errTooManyWebhooks = errors.New("too_many_webhooks")
+)
+
+// webhookEvents validates and dedups the requested events. At least one is required.
+func webhookEvents(events []string) ([]string, error) {
+	out := make([]string, 0, len(events))
+	seen := map[string]bool{}
+	for _, e := range events {
+		if !store.ValidWebhookEvent(e) {
+			return nil, errInvalidEvents
+		}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return nil, errInvalidEvents
+	}
+	return out, nil
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	hooks, err := s.store.ListWebhooks(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+// handleCreateWebhook registers an endpoint and returns its signing secret. The secret
+// is not shown again.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	hook, secret, err := s.createWebhook(r, user.ID, req.URL, req.Events)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidURL), errors.Is(err, errInvalidEvents):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, errTooManyWebhooks):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"webhook": hook, "secret": secret})
+}
+
+func (s *Server) createWebhook(r *http.Request, userID, rawURL string, rawEvents []string) (store.Webhook, string, error) {
+	hookURL, err := webURL(rawURL)
+	if err != nil {
+		return store.Webhook{}, "", err
+	}
+	events, err := webhookEvents(rawEvents)
+	if err != nil {
+		return store.Webhook{}, "", err
+	}
+	hooks, err := s.store.ListWebhooks(r.Context(), userID)
+	if err != nil {
+		return store.Webhook{}, "", err
+	}
+	if len(hooks) >= maxWebhooks {
+		return store.Webhook{}, "", errTooManyWebhooks
+	}
+	secret, err := s.randomString(24)
+	if err != nil {
+		return store.Webhook{}, "", err
+	}
+	hook, err := s.store.CreateWebhook(r.Context(), userID, hookURL, secret, events)
+	if err != nil {
+		return store.Webhook{}, "", err
+	}
+	s.logger.Info("webhooks.create", slog.String("webhook_id", hook.ID), slog.String("request_id", s.requestID(r.Context())))
+	return hook, secret, nil
+}
+
+// handleUpdateWebhook changes the URL, the events or pauses the webhook. Omitted fields
+// are kept.
+func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		URL    *string  `json:"url"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if req.URL != nil {
+		hookURL, err := webURL(*req.URL)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		req.URL = &hookURL
+	}
+	var events []string
+	if req.Events != nil {
+		var err error
+		if events, err = webhookEvents(req.Events); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	hook, err := s.store.UpdateWebhook(r.Context(), user.ID, chi.URLParam(r, "id"), req.URL, events, req.Active)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.DeleteWebhook(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	hook, err := s.store.GetWebhook(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	deliveries, err := s.store.ListWebhookDeliveries(r.Context(), user.ID, hook.ID, webhookLogLimit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+// handleRetryWebhookDelivery queues a delivered or failed event again. Pending
+// deliveries are already queued and return 404.
+func (s *Server) handleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	if err := s.store.RetryWebhookDelivery(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "deliveryID")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+}
+
+func (s *Server) handleUIWebhooks(w http.ResponseWriter, r *http.Request) {
+	s.renderUIWebhooks(w, r, nil)
+}
+
+// renderUIWebhooks renders the webhooks page. extra adds page data, such as the secret
+// of a webhook that was just created.
+func (s *Server) renderUIWebhooks(w http.ResponseWriter, r *http.Request, extra map[string]interface{}) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	hooks, err := s.store.ListWebhooks(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	deliveries, err := s.store.ListWebhookDeliveries(r.Context(), user.ID, "", webhookLogLimit)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":      "Webhooks",
+		"User":       user,
+		"CSRFToken":  s.csrfFromContext(r.Context()),
+		"Notice":     webhooksNotice(r.URL.Query().Get("notice")),
+		"Webhooks":   hooks,
+		"Deliveries": deliveries,
+		"Events":     store.WebhookEvents,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	if err := s.renderer.Render(w, "webhooks", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/webhooks?notice=rate_limited", http.StatusFound)
+		return
+	}
+	hook, secret, err := s.createWebhook(r, user.ID, r.PostFormValue("url"), r.PostForm["events"])
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidURL):
+			http.Redirect(w, r, "/ui/webhooks?notice=invalid_url", http.StatusFound)
+		case errors.Is(err, errInvalidEvents):
+			http.Redirect(w, r, "/ui/webhooks?notice=invalid_events", http.StatusFound)
+		case errors.Is(err, errTooManyWebhooks):
+			http.Redirect(w, r, "/ui/webhooks?notice=too_many", http.StatusFound)
+		default:
+			http.Error(w, "db error", http.StatusInternalServerError)
+		}
+		return
+	}
+	s.renderUIWebhooks(w, r, map[string]interface{}{
+		"Notice":    webhooksNotice("created"),
+		"NewSecret": secret,
+		"NewHookID": hook.ID,
+	})
+}
+
+// handleUIUpdateWebhook saves the event checkboxes and the active flag.
+func (s *Server) handleUIUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/webhooks?notice=rate_limited", http.StatusFound)
+		return
+	}
+	events, err := webhookEvents(r.PostForm["events"])
+	if err != nil {
+		http.Redirect(w, r, "/ui/webhooks?notice=invalid_events", http.StatusFound)
+		return
+	}
+	active := r.PostFormValue("active") != ""
+	if _, err := s.store.UpdateWebhook(r.Context(), user.ID, chi.URLParam(r, "id"), nil, events, &active); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/webhooks?notice=updated", http.StatusFound)
+}
+
+func (s *Server) handleUIDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/webhooks?notice=rate_limited", http.StatusFound)
+		return
+	}
+	if err := s.store.DeleteWebhook(r.Context(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/webhooks?notice=deleted", http.StatusFound)
+}
+
+func (s *Server) handleUIRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/webhooks?notice=rate_limited", http.StatusFound)
+		return
+	}
+	if err := s.store.RetryWebhookDelivery(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "deliveryID")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Redirect(w, r, "/ui/webhooks?notice=retry_pending", http.StatusFound)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/webhooks?notice=retried", http.StatusFound)
+}
+
+func webhooksNotice(state string) string {
+	switch state {
+	case "created":
+		return "Webhook added. Copy the signing secret now; it is not shown again."
+	case "invalid_url":
+		return "Enter the full http(s) URL of the endpoint."
+	case "invalid_events":
+		return "Choose at least one event."
+	case "too_many":
+		return "You can add up to 10 webhooks."
+	case "updated":
+		return "Webhook updated."
+	case "deleted":
+		return "Webhook deleted. Queued deliveries were dropped."
+	case "retried":
+		return "Delivery queued again. It is sent within a minute."
+	case "retry_pending":
+		return "That delivery is already queued."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
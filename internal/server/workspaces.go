@@ -0,0 +1,424 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"altpocket/internal/auth"
+	"altpocket/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxWorkspaceName = 100
+
+var errInvalidWorkspace = errors.New("invalid_workspace")
+
+// workspaceContext resolves the shared workspace a request targets, taken from the
+// X-Workspace-ID header or the workspace query parameter, and enforces the user's role in
+// it. Requests without a workspace act on the user's own library and pass through. On
+// failure it returns the HTTP status and error code to send.
+func (s *Server) workspaceContext(ctx context.Context, r *http.Request, userID string) (context.Context, int, string) {
+	id := r.Header.Get("X-Workspace-ID")
+	if id == "" {
+		id = r.URL.Query().Get("workspace")
+	}
+	if id == "" {
+		return ctx, 0, ""
+	}
+	ws, err := s.store.GetWorkspace(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ctx, http.StatusNotFound, "workspace_not_found"
+		}
+		return ctx, http.StatusInternalServerError, "db_error"
+	}
+	if !store.RoleAtLeast(ws.Role, workspaceRoleFor(r.Method)) {
+		return ctx, http.StatusForbidden, "forbidden"
+	}
+	return context.WithValue(ctx, workspaceKey, ws), 0, ""
+}
+
+// workspaceRoleFor returns the minimum workspace role needed for a request method:
+// viewers may read, changes need an editor.
+func workspaceRoleFor(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return store.RoleViewer
+	}
+	return store.RoleEditor
+}
+
+func workspaceFromContext(ctx context.Context) (store.Workspace, bool) {
+	ws, ok := ctx.Value(workspaceKey).(store.Workspace)
+	return ws, ok
+}
+
+func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	workspaces, err := s.store.ListWorkspaces(r.Context(), user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"workspaces": workspaces})
+}
+
+func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	name, err := workspaceName(req.Name)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ws, err := s.store.CreateWorkspace(r.Context(), user.ID, name)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	s.logger.Info("workspaces.create", slog.String("workspace_id", ws.ID), slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusCreated, ws)
+}
+
+// handleGetWorkspace returns the workspace with its members.
+func (s *Server) handleGetWorkspace(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	ws, err := s.store.GetWorkspace(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	members, err := s.store.ListWorkspaceMembers(r.Context(), ws.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"workspace": ws, "members": members})
+}
+
+func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	ws, err := s.store.GetWorkspace(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err == nil && ws.Role != store.RoleOwner {
+		err = store.ErrWorkspaceForbidden
+	}
+	if err == nil {
+		err = s.store.DeleteWorkspace(r.Context(), user.ID, ws.ID)
+	}
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	s.logger.Info("workspaces.delete", slog.String("workspace_id", ws.ID), slog.String("request_id", s.requestID(r.Context())))
+	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
+}
+
+func (s *Server) handleAddWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" || !store.ValidRole(req.Role) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	ws, err := s.store.GetWorkspace(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	m, err := s.store.AddWorkspaceMember(r.Context(), user.ID, ws.ID, email, req.Role)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleUpdateWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !store.ValidRole(req.Role) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	ws, err := s.store.GetWorkspace(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err == nil {
+		err = s.store.SetWorkspaceMemberRole(r.Context(), user.ID, ws.ID, chi.URLParam(r, "userID"), req.Role)
+	}
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"role": req.Role})
+}
+
+// handleRemoveWorkspaceMember removes a member; members may also remove themselves to
+// leave the workspace.
+func (s *Server) handleRemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate_limited"})
+		return
+	}
+	ws, err := s.store.GetWorkspace(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err == nil {
+		err = s.store.RemoveWorkspaceMember(r.Context(), user.ID, ws.ID, chi.URLParam(r, "userID"))
+	}
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"removed": true})
+}
+
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	case errors.Is(err, store.ErrUnknownUser):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown_user"})
+	case errors.Is(err, store.ErrWorkspaceForbidden):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+	case errors.Is(err, store.ErrMemberExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "member_exists"})
+	case errors.Is(err, store.ErrLastOwner):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "last_owner"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "db_error"})
+	}
+}
+
+// workspaceName trims and validates a workspace name.
+func workspaceName(v string) (string, error) {
+	name := strings.TrimSpace(v)
+	if name == "" || utf8.RuneCountInString(name) > maxWorkspaceName {
+		return "", errInvalidWorkspace
+	}
+	return name, nil
+}
+
+// workspaceCard is a workspace with its members as shown on the workspaces page.
+type workspaceCard struct {
+	store.Workspace
+	Members []store.WorkspaceMember
+}
+
+func (s *Server) handleUIWorkspaces(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	workspaces, err := s.store.ListWorkspaces(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	cards := make([]workspaceCard, 0, len(workspaces))
+	for _, ws := range workspaces {
+		members, err := s.store.ListWorkspaceMembers(r.Context(), ws.ID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		cards = append(cards, workspaceCard{Workspace: ws, Members: members})
+	}
+	data := map[string]interface{}{
+		"Title":      "Workspaces",
+		"User":       user,
+		"Workspaces": cards,
+		"Notice":     workspacesNotice(r.URL.Query().Get("notice")),
+		"CSRFToken":  s.csrfFromContext(r.Context()),
+	}
+	if err := s.renderer.Render(w, "workspaces", data); err != nil {
+		http.Error(w, "render error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleUICreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.validFormCSRF(r) {
+		http.Error(w, "csrf", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.Allow(user.ID) {
+		http.Redirect(w, r, "/ui/workspaces?notice=rate_limited", http.StatusFound)
+		return
+	}
+	name, err := workspaceName(r.PostFormValue("name"))
+	if err != nil {
+		http.Redirect(w, r, "/ui/workspaces?notice=invalid", http.StatusFound)
+		return
+	}
+	ws, err := s.store.CreateWorkspace(r.Context(), user.ID, name)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("workspaces.create", slog.String("workspace_id", ws.ID), slog.String("request_id", s.requestID(r.Context())))
+	http.Redirect(w, r, "/ui/workspaces?notice=created", http.StatusFound)
+}
+
+// handleUIWorkspaceAction wraps the workspace form posts: it checks CSRF and the rate
+// limit, runs apply and redirects back with a notice for the outcome.
+func (s *Server) handleUIWorkspaceAction(done string, apply func(r *http.Request, userID, workspaceID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !s.validFormCSRF(r) {
+			http.Error(w, "csrf", http.StatusForbidden)
+			return
+		}
+		if !s.limiter.Allow(user.ID) {
+			http.Redirect(w, r, "/ui/workspaces?notice=rate_limited", http.StatusFound)
+			return
+		}
+		notice := done
+		if err := apply(r, user.ID, chi.URLParam(r, "id")); err != nil {
+			switch {
+			case errors.Is(err, pgx.ErrNoRows), errors.Is(err, store.ErrWorkspaceForbidden):
+				notice = "forbidden"
+			case errors.Is(err, store.ErrUnknownUser):
+				notice = "unknown_user"
+			case errors.Is(err, store.ErrMemberExists):
+				notice = "member_exists"
+			case errors.Is(err, store.ErrLastOwner):
+				notice = "last_owner"
+			case errors.Is(err, errInvalidWorkspace):
+				notice = "invalid"
+			default:
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Redirect(w, r, "/ui/workspaces?notice="+url.QueryEscape(notice), http.StatusFound)
+	}
+}
+
+func (s *Server) uiAddWorkspaceMember(r *http.Request, userID, workspaceID string) error {
+	email, role := strings.TrimSpace(r.PostFormValue("email")), r.PostFormValue("role")
+	if email == "" || !store.ValidRole(role) {
+		return errInvalidWorkspace
+	}
+	_, err := s.store.AddWorkspaceMember(r.Context(), userID, workspaceID, email, role)
+	return err
+}
+
+func (s *Server) uiSetWorkspaceMemberRole(r *http.Request, userID, workspaceID string) error {
+	role := r.PostFormValue("role")
+	if !store.ValidRole(role) {
+		return errInvalidWorkspace
+	}
+	return s.store.SetWorkspaceMemberRole(r.Context(), userID, workspaceID, chi.URLParam(r, "userID"), role)
+}
+
+func (s *Server) uiRemoveWorkspaceMember(r *http.Request, userID, workspaceID string) error {
+	return s.store.RemoveWorkspaceMember(r.Context(), userID, workspaceID, chi.URLParam(r, "userID"))
+}
+
+func (s *Server) uiDeleteWorkspace(r *http.Request, userID, workspaceID string) error {
+	return s.store.DeleteWorkspace(r.Context(), userID, workspaceID)
+}
+
+func workspacesNotice(state string) string {
+	switch state {
+	case "created":
+		return "Workspace created. Add members by the email they sign in with."
+	case "added":
+		return "Member added."
+	case "updated":
+		return "Role updated."
+	case "removed":
+		return "Member removed."
+	case "deleted":
+		return "Workspace deleted with its items."
+	case "invalid":
+		return "Enter a name (100 characters max), or an email and a role."
+	case "unknown_user":
+		return "No user with that email has signed in yet."
+	case "member_exists":
+		return "That user is already a member."
+	case "last_owner":
+		return "A workspace needs at least one owner. Promote another member first."
+	case "forbidden":
+		return "Only owners can manage members."
+	case "rate_limited":
+		return "Too many requests. Please wait and retry."
+	default:
+		return ""
+	}
+}
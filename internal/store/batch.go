@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+)
+
+const (
+	BatchDelete     = "delete"
+	BatchAddTags    = "add_tags"
+	BatchRemoveTags = "remove_tags"
+	BatchArchive    = "archive"
+	BatchRefetch    = "refetch"
+)
+
+// BatchOp applies one action to every listed item. Tags are used by the tag actions
+// and must already be normalized.
+type BatchOp struct {
+	Action  string
+	ItemIDs []string
+	Tags    []string
+}
+
+type BatchResult struct {
+	Action string `json:"action"`
+	ItemID string `json:"item_id"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ApplyItemBatch applies ops in order within a single transaction. Items that do not
+// exist or that the user may not change are reported as not_found without aborting the
+// batch; database errors roll everything back.
+func (s *Store) ApplyItemBatch(ctx context.Context, userID string, ops []BatchOp) ([]BatchResult, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var ids []string
+	for _, op := range ops {
+		ids = append(ids, op.ItemIDs...)
+	}
+	// Comparing as text keeps malformed IDs from failing the whole transaction.
+	rows, err := tx.Query(ctx, `
+		SELECT id::text, user_id, workspace_id FROM items
+		WHERE `+itemAccess("", "$1", true)+` AND id::text = ANY($2) AND deleted_at IS NULL
+		FOR UPDATE
+	`, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	// owned maps each changeable item to the namespace its tags live in.
+	owned := map[string]tagScope{}
+	for rows.Next() {
+		var id, ownerID string
+		var workspaceID *string
+		if err = rows.Scan(&id, &ownerID, &workspaceID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		owned[id] = itemTagScope(ownerID, workspaceID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, 0, len(ids))
+	tagScopes := map[tagScope]bool{}
+	for _, op := range ops {
+		for _, itemID := range op.ItemIDs {
+			res := BatchResult{Action: op.Action, ItemID: itemID}
+			scope, ok := owned[itemID]
+			if !ok {
+				res.Error = "not_found"
+				results = append(results, res)
+				continue
+			}
+			switch op.Action {
+			case BatchDelete:
+				_, err = tx.Exec(ctx, `UPDATE items SET deleted_at=NOW(), updated_at=NOW() WHERE id=$1`, itemID)
+				delete(owned, itemID)
+			case BatchAddTags:
+				if err = attachTags(ctx, tx, scope, itemID, op.Tags); err == nil {
+					_, err = tx.Exec(ctx, `UPDATE items SET updated_at=NOW() WHERE id=$1`, itemID)
+				}
+			case BatchRemoveTags:
+				if err = detachTags(ctx, tx, itemID, op.Tags); err == nil {
+					_, err = tx.Exec(ctx, `UPDATE items SET updated_at=NOW() WHERE id=$1`, itemID)
+				}
+				tagScopes[scope] = true
+			case BatchArchive:
+				_, err = tx.Exec(ctx, `
+					UPDATE items
+					SET state='archived', archived_at=COALESCE(archived_at, NOW()), updated_at=NOW()
+					WHERE id=$1
+				`, itemID)
+			case BatchRefetch:
+				_, err = tx.Exec(ctx, `UPDATE items SET refetch_requested=true WHERE id=$1`, itemID)
+			default:
+				res.Error = "unknown_action"
+				results = append(results, res)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if event := batchWebhookEvent(op.Action); event != "" {
+				if err = enqueueWebhooks(ctx, tx, event, itemID); err != nil {
+					return nil, err
+				}
+			}
+			res.OK = true
+			results = append(results, res)
+		}
+	}
+
+	for scope := range tagScopes {
+		if err = deleteOrphanTags(ctx, tx, scope); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchWebhookEvent maps batch actions to the webhook event they raise, if any.
+func batchWebhookEvent(action string) string {
+	switch action {
+	case BatchDelete:
+		return WebhookItemDeleted
+	case BatchAddTags, BatchRemoveTags:
+		return WebhookItemTagged
+	default:
+		return ""
+	}
+}
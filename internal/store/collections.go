@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Collection is a named, manually ordered reading list. ItemCount excludes trashed
+// items, which keep their place and reappear if restored.
+type Collection struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ItemCount   int       `json:"item_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const collectionColumns = `
+	co.id, co.title, co.description,
+	(SELECT COUNT(*) FROM collection_items ci JOIN items i ON i.id=ci.item_id
+		WHERE ci.collection_id=co.id AND i.deleted_at IS NULL),
+	co.created_at, co.updated_at`
+
+func scanCollection(row pgx.Row) (Collection, error) {
+	var c Collection
+	if err := row.Scan(&c.ID, &c.Title, &c.Description, &c.ItemCount, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return Collection{}, err
+	}
+	return c, nil
+}
+
+func (s *Store) ListCollections(ctx context.Context, userID string) ([]Collection, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+collectionColumns+`
+		FROM collections co
+		WHERE co.user_id=$1
+		ORDER BY lower(co.title), co.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func (s *Store) GetCollection(ctx context.Context, userID, collectionID string) (Collection, error) {
+	return scanCollection(s.DB.QueryRow(ctx, `
+		SELECT `+collectionColumns+`
+		FROM collections co
+		WHERE co.id=$1 AND co.user_id=$2
+	`, collectionID, userID))
+}
+
+func (s *Store) CreateCollection(ctx context.Context, userID, title, description string) (Collection, error) {
+	return scanCollection(s.DB.QueryRow(ctx, `
+		INSERT INTO collections AS co (user_id, title, description)
+		VALUES ($1, $2, $3)
+		RETURNING `+collectionColumns, userID, title, description))
+}
+
+// UpdateCollection changes the title and/or description; nil values are kept.
+func (s *Store) UpdateCollection(ctx context.Context, userID, collectionID string, title, description *string) (Collection, error) {
+	return scanCollection(s.DB.QueryRow(ctx, `
+		UPDATE collections co
+		SET title=COALESCE($3, co.title), description=COALESCE($4, co.description), updated_at=NOW()
+		WHERE co.id=$1 AND co.user_id=$2
+		RETURNING `+collectionColumns, collectionID, userID, title, description))
+}
+
+func (s *Store) DeleteCollection(ctx context.Context, userID, collectionID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM collections WHERE id=$1 AND user_id=$2`, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// AddCollectionItems appends the user's live items to the end of the collection in the
+// given order. Items already in the collection keep their place; unknown IDs are
+// ignored. It returns how many items were added.
+func (s *Store) AddCollectionItems(ctx context.Context, userID, collectionID string, itemIDs []string) (int64, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = lockCollection(ctx, tx, userID, collectionID); err != nil {
+		return 0, err
+	}
+	// Comparing as text keeps malformed IDs from failing the whole transaction.
+	ct, err := tx.Exec(ctx, `
+		INSERT INTO collection_items (collection_id, item_id, position)
+		SELECT $1, i.id,
+			(SELECT COALESCE(MAX(position), 0) FROM collection_items WHERE collection_id=$1) + o.ord
+		FROM unnest($3::text[]) WITH ORDINALITY AS o(item_id, ord)
+		JOIN items i ON i.id::text=o.item_id
+		WHERE i.user_id=$2 AND i.workspace_id IS NULL AND i.deleted_at IS NULL
+		ON CONFLICT (collection_id, item_id) DO NOTHING
+	`, collectionID, userID, itemIDs)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE collections SET updated_at=NOW() WHERE id=$1`, collectionID); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
+
+func (s *Store) RemoveCollectionItem(ctx context.Context, userID, collectionID, itemID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		DELETE FROM collection_items ci
+		USING collections co
+		WHERE ci.collection_id=$1 AND ci.item_id::text=$2 AND co.id=ci.collection_id AND co.user_id=$3
+	`, collectionID, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ReorderCollection moves the listed items to the front of the collection in the given
+// order. Items left out, such as trashed ones, follow in their previous order.
+func (s *Store) ReorderCollection(ctx context.Context, userID, collectionID string, itemIDs []string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = lockCollection(ctx, tx, userID, collectionID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		WITH listed AS (
+			SELECT item_id, MIN(ord) AS ord
+			FROM unnest($2::text[]) WITH ORDINALITY AS o(item_id, ord)
+			GROUP BY item_id
+		), ranked AS (
+			SELECT ci.item_id,
+				ROW_NUMBER() OVER (ORDER BY l.ord ASC NULLS LAST, ci.position ASC, ci.added_at ASC) AS pos
+			FROM collection_items ci
+			LEFT JOIN listed l ON l.item_id=ci.item_id::text
+			WHERE ci.collection_id=$1
+		)
+		UPDATE collection_items ci SET position=r.pos
+		FROM ranked r
+		WHERE ci.collection_id=$1 AND ci.item_id=r.item_id
+	`, collectionID, itemIDs)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE collections SET updated_at=NOW() WHERE id=$1`, collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ItemCollections lists the collections containing the item.
+func (s *Store) ItemCollections(ctx context.Context, userID, itemID string) ([]Collection, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+collectionColumns+`
+		FROM collections co
+		JOIN collection_items ci ON ci.collection_id=co.id
+		WHERE co.user_id=$1 AND ci.item_id=$2
+		ORDER BY lower(co.title), co.created_at
+	`, userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func lockCollection(ctx context.Context, tx pgx.Tx, userID, collectionID string) error {
+	var id string
+	return tx.QueryRow(ctx, `
+		SELECT id FROM collections WHERE id=$1 AND user_id=$2 FOR UPDATE
+	`, collectionID, userID).Scan(&id)
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestStore returns a Store on a fresh schema of the database in TEST_DATABASE_URL with
+// every migration applied, and drops the schema when the test ends. Tests that need a
+// database are skipped when the variable is unset.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := newEmptyTestStore(t)
+	migrateTestStore(t, s, "", "")
+	return s
+}
+
+// newEmptyTestStore is newTestStore without any migrations applied.
+func newEmptyTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	schema := fmt.Sprintf("altpocket_test_%d", time.Now().UnixNano())
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer admin.Close(ctx)
+	// Extensions go to public so that they outlive the per-test schema.
+	for _, stmt := range []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public",
+		"CREATE EXTENSION IF NOT EXISTS pgcrypto SCHEMA public",
+		"CREATE SCHEMA " + schema,
+	} {
+		if _, err := admin.Exec(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			t.Errorf("connect: %v", err)
+			return
+		}
+		defer conn.Close(ctx)
+		if _, err := conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return New(pool)
+}
+
+// migrateTestStore applies, in order, the migrations whose file names sort at or after
+// from and before to; empty bounds are open.
+func migrateTestStore(t *testing.T, s *Store, from, to string) {
+	t.Helper()
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		name := filepath.Base(f)
+		if name < from || to != "" && name >= to {
+			continue
+		}
+		sql, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.DB.Exec(context.Background(), string(sql)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func createTestUser(t *testing.T, s *Store, name string) User {
+	t.Helper()
+	u, err := s.UpsertUser(context.Background(), "sub-"+name, name+"@example.com", name, "")
+	if err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return u
+}
+
+// createTestItem saves rawURL, which doubles as the canonical URL and hash, with tags.
+func createTestItem(t *testing.T, s *Store, userID, workspaceID, rawURL string, tags ...string) string {
+	t.Helper()
+	id, created, err := s.CreateItem(context.Background(), userID, workspaceID, rawURL, rawURL, rawURL, tags)
+	if err != nil || !created {
+		t.Fatalf("create item %s: created=%v err=%v", rawURL, created, err)
+	}
+	return id
+}
+
+func tagNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func assertNoTag(t *testing.T, s *Store, userID, name string) {
+	t.Helper()
+	if _, err := s.GetTagByName(context.Background(), userID, name); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected no tag %q for user %s, got err=%v", name, userID, err)
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRefreshTokenReused means a refresh token was presented after it had already been
+// rotated. The device is signed out, since the token has likely been copied.
+var ErrRefreshTokenReused = errors.New("refresh_token_reused")
+
+// ExtensionDevice is a signed-in browser extension install.
+type ExtensionDevice struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CreateExtensionDevice registers an install with its first refresh token.
+func (s *Store) CreateExtensionDevice(ctx context.Context, userID, name, refreshHash string, refreshTTL time.Duration) (string, error) {
+	var id string
+	err := s.DB.QueryRow(ctx, `
+		INSERT INTO extension_devices (user_id, name, refresh_hash, refresh_expires_at, last_used_at)
+		VALUES ($1, $2, $3, NOW() + ($4::bigint * INTERVAL '1 second'), NOW())
+		RETURNING id
+	`, userID, name, refreshHash, int64(refreshTTL.Seconds())).Scan(&id)
+	return id, err
+}
+
+// RotateRefreshToken replaces an unexpired refresh token with newHash and extends its
+// lifetime. An unknown token returns pgx.ErrNoRows; the token rotated away last time
+// signs the device out and returns ErrRefreshTokenReused.
+func (s *Store) RotateRefreshToken(ctx context.Context, refreshHash, newHash string, refreshTTL time.Duration) (userID, deviceID string, err error) {
+	err = s.DB.QueryRow(ctx, `
+		UPDATE extension_devices
+		SET refresh_hash=$2, previous_refresh_hash=refresh_hash,
+			refresh_expires_at=NOW() + ($3::bigint * INTERVAL '1 second'), last_used_at=NOW()
+		WHERE refresh_hash=$1 AND refresh_expires_at > NOW()
+		RETURNING user_id, id
+	`, refreshHash, newHash, int64(refreshTTL.Seconds())).Scan(&userID, &deviceID)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return userID, deviceID, err
+	}
+	revoked, err := s.revokeExtensionDevices(ctx, `previous_refresh_hash=$1`, refreshHash)
+	if err != nil {
+		return "", "", err
+	}
+	if revoked > 0 {
+		return "", "", ErrRefreshTokenReused
+	}
+	return "", "", pgx.ErrNoRows
+}
+
+// SetDeviceAccessToken records the access token most recently issued to the device, so
+// that signing the device out can denylist it.
+func (s *Store) SetDeviceAccessToken(ctx context.Context, deviceID, jti string, expiresAt time.Time) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE extension_devices SET access_jti=$2, access_expires_at=$3 WHERE id=$1
+	`, deviceID, jti, expiresAt)
+	return err
+}
+
+func (s *Store) ListExtensionDevices(ctx context.Context, userID string) ([]ExtensionDevice, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, name, created_at, last_used_at
+		FROM extension_devices
+		WHERE user_id=$1 AND refresh_expires_at > NOW()
+		ORDER BY last_used_at DESC NULLS LAST, created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []ExtensionDevice{}
+	for rows.Next() {
+		var d ExtensionDevice
+		if err := rows.Scan(&d.ID, &d.Name, &d.CreatedAt, &d.LastUsedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// RevokeExtensionDevice signs the device out: its refresh token stops working and its
+// current access token is denylisted.
+func (s *Store) RevokeExtensionDevice(ctx context.Context, userID, deviceID string) error {
+	revoked, err := s.revokeExtensionDevices(ctx, `id::text=$1 AND user_id=$2`, deviceID, userID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeRefreshToken signs out the device holding refreshHash. Unknown tokens are
+// ignored.
+func (s *Store) RevokeRefreshToken(ctx context.Context, refreshHash string) error {
+	_, err := s.revokeExtensionDevices(ctx, `refresh_hash=$1`, refreshHash)
+	return err
+}
+
+// revokeExtensionDevices deletes the matching devices, denylists their unexpired access
+// tokens and returns how many devices were deleted.
+func (s *Store) revokeExtensionDevices(ctx context.Context, where string, args ...interface{}) (int64, error) {
+	var revoked int64
+	err := s.DB.QueryRow(ctx, `
+		WITH d AS (
+			DELETE FROM extension_devices WHERE `+where+`
+			RETURNING access_jti, access_expires_at
+		), denied AS (
+			INSERT INTO revoked_access_tokens (jti, expires_at)
+			SELECT access_jti, access_expires_at FROM d
+			WHERE access_jti <> '' AND access_expires_at > NOW()
+			ON CONFLICT (jti) DO NOTHING
+		)
+		SELECT count(*) FROM d
+	`, args...).Scan(&revoked)
+	return revoked, err
+}
+
+// GetUserByAccessToken returns the user of a verified access token unless its jti is
+// denylisted or, for extension tokens, the device has been signed out. It records the
+// device's use.
+func (s *Store) GetUserByAccessToken(ctx context.Context, userID, deviceID, jti string) (User, error) {
+	var u User
+	err := s.DB.QueryRow(ctx, `
+		WITH d AS (
+			UPDATE extension_devices SET last_used_at=NOW()
+			WHERE $2 <> '' AND id::text=$2 AND user_id::text=$1
+			RETURNING id
+		)
+		SELECT u.id, u.google_sub, u.email, u.name, u.avatar_url
+		FROM users u
+		WHERE u.id::text=$1
+			AND NOT EXISTS (SELECT 1 FROM revoked_access_tokens r WHERE r.jti=$3)
+			AND ($2='' OR EXISTS (SELECT 1 FROM d))
+	`, userID, deviceID, jti).Scan(&u.ID, &u.GoogleSub, &u.Email, &u.Name, &u.AvatarURL)
+	if err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// CleanupExtensionTokens removes expired denylist entries and devices whose refresh
+// token has expired.
+func (s *Store) CleanupExtensionTokens(ctx context.Context) (int64, error) {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	removed := ct.RowsAffected()
+	ct, err = s.DB.Exec(ctx, `DELETE FROM extension_devices WHERE refresh_expires_at <= NOW()`)
+	if err != nil {
+		return removed, err
+	}
+	return removed + ct.RowsAffected(), nil
+}
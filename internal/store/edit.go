@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ItemEdit holds user changes to an item. Nil fields are left untouched. An empty Title
+// or Excerpt drops the override and queues a refetch so the fetched value comes back.
+// URL changes are applied only when CanonicalHash is set.
+type ItemEdit struct {
+	Title         *string
+	Excerpt       *string
+	Note          *string
+	URL           string
+	CanonicalURL  string
+	CanonicalHash string
+}
+
+// UpdateItem applies edit to a live item. Moving the item to a URL that is already
+// saved returns ErrItemExists.
+func (s *Store) UpdateItem(ctx context.Context, userID, itemID string, edit ItemEdit) error {
+	sets := []string{"updated_at=NOW()"}
+	args := []interface{}{itemID, userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	refetch := false
+	if edit.Title != nil {
+		if *edit.Title == "" {
+			sets = append(sets, "title_edited=false")
+			refetch = true
+		} else {
+			sets = append(sets, "title="+arg(*edit.Title), "title_edited=true")
+		}
+	}
+	if edit.Excerpt != nil {
+		if *edit.Excerpt == "" {
+			sets = append(sets, "excerpt_edited=false")
+			refetch = true
+		} else {
+			sets = append(sets, "excerpt="+arg(*edit.Excerpt), "excerpt_edited=true")
+		}
+	}
+	if edit.Note != nil {
+		sets = append(sets, "note="+arg(*edit.Note))
+	}
+	if edit.CanonicalHash != "" {
+		hash := arg(edit.CanonicalHash)
+		sets = append(sets,
+			"url="+arg(edit.URL),
+			"canonical_url="+arg(edit.CanonicalURL),
+			"canonical_hash="+hash,
+			// A different page needs fetching; the same page under another URL does not.
+			fmt.Sprintf("fetch_status=CASE WHEN canonical_hash=%s THEN fetch_status ELSE 'pending' END", hash),
+			fmt.Sprintf("fetch_error=CASE WHEN canonical_hash=%s THEN fetch_error ELSE '' END", hash),
+		)
+	}
+	if refetch {
+		sets = append(sets, "refetch_requested=true")
+	}
+
+	ct, err := s.DB.Exec(ctx, fmt.Sprintf(`
+		UPDATE items SET %s
+		WHERE id=$1 AND %s AND deleted_at IS NULL
+	`, strings.Join(sets, ", "), itemAccess("", "$2", true)), args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrItemExists
+		}
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// exportFetchSize is how many rows are pulled from the export cursor per round trip.
+const exportFetchSize = 200
+
+// ExportItem is one item as written to a library export. Highlights are in reading
+// order, with ones that lost their anchor last.
+type ExportItem struct {
+	ID           string            `json:"id"`
+	URL          string            `json:"url"`
+	CanonicalURL string            `json:"canonical_url"`
+	Title        string            `json:"title"`
+	Excerpt      string            `json:"excerpt"`
+	Tags         []string          `json:"tags"`
+	FetchStatus  string            `json:"fetch_status"`
+	State        string            `json:"state"`
+	Favorite     bool              `json:"favorite"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	ArchivedAt   *time.Time        `json:"archived_at"`
+	Note         string            `json:"note"`
+	Highlights   []ExportHighlight `json:"highlights"`
+	ContentFull  string            `json:"content_full,omitempty"`
+}
+
+type ExportHighlight struct {
+	Quote string `json:"quote"`
+	Note  string `json:"note"`
+}
+
+// ExportItems calls fn for every item of the user, oldest first. Rows are read through a
+// server-side cursor in batches, so memory use does not grow with the library size.
+// content_full is only read when includeContent is set.
+func (s *Store) ExportItems(ctx context.Context, userID string, includeContent bool, fn func(ExportItem) error) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// The transaction only holds the cursor; nothing is written.
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, err = tx.Exec(ctx, `
+		DECLARE export_items NO SCROLL CURSOR FOR
+		SELECT i.id, i.url, i.canonical_url, i.title, i.excerpt,
+			ARRAY(
+				SELECT t.name FROM item_tags it JOIN tags t ON t.id=it.tag_id
+				WHERE it.item_id=i.id ORDER BY t.normalized_name
+			) AS tag_names,
+			i.fetch_status, i.state, i.favorite, i.created_at, i.updated_at, i.archived_at, i.note,
+			COALESCE((
+				SELECT json_agg(json_build_object('quote', h.quote, 'note', h.note)
+					ORDER BY h.start_offset < 0, h.start_offset, h.created_at)
+				FROM highlights h WHERE h.item_id=i.id
+			), '[]'::json) AS highlights,
+			CASE WHEN $2 THEN COALESCE(c.content_full, '') ELSE '' END
+		FROM items i
+		LEFT JOIN item_contents c ON c.item_id=i.id
+		WHERE i.user_id=$1 AND i.workspace_id IS NULL AND i.deleted_at IS NULL
+		ORDER BY i.created_at ASC, i.id ASC
+	`, userID, includeContent)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM export_items`, exportFetchSize))
+		if err != nil {
+			return err
+		}
+		n := 0
+		for rows.Next() {
+			var it ExportItem
+			if err := rows.Scan(&it.ID, &it.URL, &it.CanonicalURL, &it.Title, &it.Excerpt, &it.Tags,
+				&it.FetchStatus, &it.State, &it.Favorite, &it.CreatedAt, &it.UpdatedAt, &it.ArchivedAt, &it.Note, &it.Highlights, &it.ContentFull); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+			if err := fn(it); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			return nil
+		}
+	}
+}
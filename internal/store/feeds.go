@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FeedToken describes the user's feed credential. The token itself is never stored.
+type FeedToken struct {
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// SetFeedToken replaces the user's feed token, invalidating existing feed URLs.
+func (s *Store) SetFeedToken(ctx context.Context, userID, tokenHash string) error {
+	_, err := s.DB.Exec(ctx, `
+		INSERT INTO feed_tokens (user_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash=EXCLUDED.token_hash, last_used_at=NULL, created_at=NOW()
+	`, userID, tokenHash)
+	return err
+}
+
+func (s *Store) DeleteFeedToken(ctx context.Context, userID string) error {
+	_, err := s.DB.Exec(ctx, `DELETE FROM feed_tokens WHERE user_id=$1`, userID)
+	return err
+}
+
+// GetFeedToken returns nil when the user has no feed token.
+func (s *Store) GetFeedToken(ctx context.Context, userID string) (*FeedToken, error) {
+	var t FeedToken
+	err := s.DB.QueryRow(ctx, `SELECT last_used_at, created_at FROM feed_tokens WHERE user_id=$1`, userID).Scan(&t.LastUsedAt, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetUserByFeedToken resolves a feed token and records its use.
+func (s *Store) GetUserByFeedToken(ctx context.Context, tokenHash string) (User, error) {
+	var u User
+	err := s.DB.QueryRow(ctx, `
+		WITH t AS (
+			UPDATE feed_tokens SET last_used_at=NOW() WHERE token_hash=$1 RETURNING user_id
+		)
+		SELECT u.id, u.google_sub, u.email, u.name, u.avatar_url FROM users u JOIN t ON t.user_id=u.id
+	`, tokenHash).Scan(&u.ID, &u.GoogleSub, &u.Email, &u.Name, &u.AvatarURL)
+	if err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// ItemContents returns the extracted text of the given items, keyed by item ID. Items
+// without content are omitted. Callers must already have checked access to the items.
+func (s *Store) ItemContents(ctx context.Context, itemIDs []string) (map[string]string, error) {
+	contents := make(map[string]string, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return contents, nil
+	}
+	rows, err := s.DB.Query(ctx, `
+		SELECT item_id::text, content_full FROM item_contents
+		WHERE item_id::text = ANY($1) AND content_full IS NOT NULL AND content_full <> ''
+	`, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, err
+		}
+		contents[id] = content
+	}
+	return contents, rows.Err()
+}
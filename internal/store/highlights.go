@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"altpocket/internal/highlight"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrQuoteNotFound    = errors.New("quote_not_found")
+	ErrHighlightOverlap = errors.New("highlight_overlap")
+)
+
+// Highlight is a quoted range of an item's content. Start and End are code point
+// offsets into content_full; Anchored is false when a refetch removed the quote.
+type Highlight struct {
+	ID        string    `json:"id"`
+	ItemID    string    `json:"item_id"`
+	Quote     string    `json:"quote"`
+	Prefix    string    `json:"prefix"`
+	Suffix    string    `json:"suffix"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Anchored  bool      `json:"anchored"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListHighlights returns the highlights of a live item in reading order, followed by
+// those that lost their anchor.
+func (s *Store) ListHighlights(ctx context.Context, userID, itemID string) ([]Highlight, error) {
+	var exists bool
+	if err := s.DB.QueryRow(ctx, `
+		SELECT true FROM items WHERE id=$1 AND `+itemAccess("", "$2", false)+` AND deleted_at IS NULL
+	`, itemID, userID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	return s.itemHighlights(ctx, itemID)
+}
+
+func (s *Store) itemHighlights(ctx context.Context, itemID string) ([]Highlight, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, item_id, quote, prefix, suffix, start_offset, end_offset, note, created_at, updated_at
+		FROM highlights
+		WHERE item_id=$1
+		ORDER BY start_offset < 0, start_offset, created_at
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	highlights := []Highlight{}
+	for rows.Next() {
+		var h Highlight
+		if err := rows.Scan(&h.ID, &h.ItemID, &h.Quote, &h.Prefix, &h.Suffix, &h.Start, &h.End, &h.Note, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			return nil, err
+		}
+		h.Anchored = h.Start >= 0
+		highlights = append(highlights, h)
+	}
+	return highlights, rows.Err()
+}
+
+// CreateHighlight anchors quote in the item's current content, preferring the
+// occurrence nearest hint, and stores it with its surrounding context.
+func (s *Store) CreateHighlight(ctx context.Context, userID, itemID, quote, note string, hint int) (Highlight, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return Highlight{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var content string
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(c.content_full, '')
+		FROM items i
+		LEFT JOIN item_contents c ON c.item_id=i.id
+		WHERE i.id=$1 AND `+itemAccess("i.", "$2", true)+` AND i.deleted_at IS NULL
+		FOR UPDATE OF i
+	`, itemID, userID).Scan(&content)
+	if err != nil {
+		return Highlight{}, err
+	}
+
+	start, end, ok := highlight.Locate(content, quote, "", "", hint)
+	if !ok {
+		err = ErrQuoteNotFound
+		return Highlight{}, err
+	}
+	spans, err := anchoredSpans(ctx, tx, itemID)
+	if err != nil {
+		return Highlight{}, err
+	}
+	if highlight.Overlaps(spans, start, end) {
+		err = ErrHighlightOverlap
+		return Highlight{}, err
+	}
+	prefix, suffix := highlight.Context(content, start, end)
+
+	h := Highlight{ItemID: itemID, Quote: quote, Prefix: prefix, Suffix: suffix, Start: start, End: end, Anchored: true, Note: note}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO highlights (item_id, user_id, quote, prefix, suffix, start_offset, end_offset, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, itemID, userID, quote, prefix, suffix, start, end, note).Scan(&h.ID, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return Highlight{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Highlight{}, err
+	}
+	return h, nil
+}
+
+func (s *Store) DeleteHighlight(ctx context.Context, userID, itemID, highlightID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		DELETE FROM highlights h
+		USING items i
+		WHERE h.id=$1 AND h.item_id=$2 AND i.id=h.item_id AND `+itemAccess("i.", "$3", true)+` AND i.deleted_at IS NULL
+	`, highlightID, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func anchoredSpans(ctx context.Context, tx pgx.Tx, itemID string) ([]highlight.Span, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, start_offset, end_offset FROM highlights WHERE item_id=$1 AND start_offset >= 0
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []highlight.Span
+	for rows.Next() {
+		var sp highlight.Span
+		if err := rows.Scan(&sp.ID, &sp.Start, &sp.End); err != nil {
+			return nil, err
+		}
+		spans = append(spans, sp)
+	}
+	return spans, rows.Err()
+}
+
+// reanchorHighlights moves the item's highlights onto their quotes in freshly fetched
+// content. Highlights whose quote disappeared are kept but marked unanchored.
+func reanchorHighlights(ctx context.Context, tx pgx.Tx, itemID, content string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id, quote, prefix, suffix, start_offset FROM highlights WHERE item_id=$1
+	`, itemID)
+	if err != nil {
+		return err
+	}
+	type anchor struct {
+		id                    string
+		quote, prefix, suffix string
+		start                 int
+	}
+	var anchors []anchor
+	for rows.Next() {
+		var a anchor
+		if err := rows.Scan(&a.id, &a.quote, &a.prefix, &a.suffix, &a.start); err != nil {
+			rows.Close()
+			return err
+		}
+		anchors = append(anchors, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range anchors {
+		start, end, ok := highlight.Locate(content, a.quote, a.prefix, a.suffix, a.start)
+		if !ok {
+			start, end = -1, -1
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE highlights SET start_offset=$1, end_offset=$2, updated_at=NOW()
+			WHERE id=$3 AND (start_offset<>$1 OR end_offset<>$2)
+		`, start, end, a.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
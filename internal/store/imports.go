@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ImportStatusQueued  = "queued"
+	ImportStatusRunning = "running"
+	ImportStatusDone    = "done"
+)
+
+// fetchPriorityBulk queues fetches for imported items behind items saved interactively.
+const fetchPriorityBulk = 1
+
+type ImportJob struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Filename       string     `json:"filename"`
+	Format         string     `json:"format"`
+	Status         string     `json:"status"`
+	Total          int        `json:"total"`
+	Processed      int        `json:"processed"`
+	CreatedCount   int        `json:"created_count"`
+	DuplicateCount int        `json:"duplicate_count"`
+	InvalidCount   int        `json:"invalid_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+}
+
+// Active reports whether the worker still has rows of this job to process.
+func (j ImportJob) Active() bool {
+	return j.Status == ImportStatusQueued || j.Status == ImportStatusRunning
+}
+
+// Percent is the share of processed rows, for progress bars.
+func (j ImportJob) Percent() int {
+	if j.Total == 0 {
+		return 100
+	}
+	return j.Processed * 100 / j.Total
+}
+
+// ImportEntry is one parsed export row. Tags must already be normalized.
+type ImportEntry struct {
+	URL           string
+	CanonicalURL  string
+	CanonicalHash string
+	Title         string
+	TimeAdded     time.Time
+	Tags          []string
+	Archived      bool
+}
+
+const importJobColumns = `id, user_id, filename, format, status, total, processed, created_count, duplicate_count, invalid_count, created_at, started_at, finished_at`
+
+func scanImportJob(row pgx.Row) (ImportJob, error) {
+	var j ImportJob
+	err := row.Scan(&j.ID, &j.UserID, &j.Filename, &j.Format, &j.Status, &j.Total, &j.Processed,
+		&j.CreatedCount, &j.DuplicateCount, &j.InvalidCount, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	return j, err
+}
+
+// CreateImportJob stores a queued job with its parsed entries. Rows that could not be
+// parsed are counted as invalid and already processed.
+func (s *Store) CreateImportJob(ctx context.Context, userID, filename, format string, entries []ImportEntry, invalid int) (ImportJob, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return ImportJob{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	status := ImportStatusQueued
+	var finishedAt *time.Time
+	if len(entries) == 0 {
+		now := time.Now()
+		status = ImportStatusDone
+		finishedAt = &now
+	}
+	job, err := scanImportJob(tx.QueryRow(ctx, `
+		INSERT INTO import_jobs (user_id, filename, format, status, total, processed, invalid_count, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, $7)
+		RETURNING `+importJobColumns,
+		userID, filename, format, status, len(entries)+invalid, invalid, finishedAt))
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"import_entries"},
+		[]string{"job_id", "position", "url", "canonical_url", "canonical_hash", "title", "time_added", "tags", "archived"},
+		pgx.CopyFromSlice(len(entries), func(i int) ([]any, error) {
+			e := entries[i]
+			var timeAdded *time.Time
+			if !e.TimeAdded.IsZero() {
+				timeAdded = &e.TimeAdded
+			}
+			tags := e.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			return []any{job.ID, i, e.URL, e.CanonicalURL, e.CanonicalHash, e.Title, timeAdded, tags, e.Archived}, nil
+		}))
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return ImportJob{}, err
+	}
+	return job, nil
+}
+
+func (s *Store) ListImportJobs(ctx context.Context, userID string, limit int) ([]ImportJob, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+importJobColumns+`
+		FROM import_jobs
+		WHERE user_id=$1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []ImportJob{}
+	for rows.Next() {
+		j, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *Store) GetImportJob(ctx context.Context, userID, jobID string) (ImportJob, error) {
+	return scanImportJob(s.DB.QueryRow(ctx, `
+		SELECT `+importJobColumns+`
+		FROM import_jobs
+		WHERE id=$1 AND user_id=$2
+	`, jobID, userID))
+}
+
+// ListRunnableImportJobs returns the oldest unfinished job of every user, so each
+// worker pass advances all users' imports instead of draining one at a time.
+func (s *Store) ListRunnableImportJobs(ctx context.Context) ([]ImportJob, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+importJobColumns+`
+		FROM (
+			SELECT DISTINCT ON (user_id) *
+			FROM import_jobs
+			WHERE status IN ('queued', 'running')
+			ORDER BY user_id, created_at ASC
+		) j
+		ORDER BY created_at ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []ImportJob{}
+	for rows.Next() {
+		j, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// RunImportBatch turns up to limit pending entries of the job into items and updates
+// the job's counters in the same transaction. Existing items (by canonical hash) are
+// left untouched and counted as duplicates. Imported items keep the export's
+// time_added as created_at, are queued for fetching at bulk priority and raise
+// item.created webhooks like any other save.
+func (s *Store) RunImportBatch(ctx context.Context, jobID string, limit int) (ImportJob, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return ImportJob{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var userID, status string
+	if err = tx.QueryRow(ctx, `SELECT user_id, status FROM import_jobs WHERE id=$1 FOR UPDATE`, jobID).Scan(&userID, &status); err != nil {
+		return ImportJob{}, err
+	}
+	if status != ImportStatusQueued && status != ImportStatusRunning {
+		err = pgx.ErrNoRows
+		return ImportJob{}, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM import_entries
+		WHERE job_id=$1 AND position IN (
+			SELECT position FROM import_entries WHERE job_id=$1 ORDER BY position LIMIT $2
+		)
+		RETURNING url, canonical_url, canonical_hash, title, time_added, tags, archived
+	`, jobID, limit)
+	if err != nil {
+		return ImportJob{}, err
+	}
+	type batchEntry struct {
+		entry     ImportEntry
+		timeAdded *time.Time
+	}
+	batch := []batchEntry{}
+	for rows.Next() {
+		var b batchEntry
+		if err = rows.Scan(&b.entry.URL, &b.entry.CanonicalURL, &b.entry.CanonicalHash,
+			&b.entry.Title, &b.timeAdded, &b.entry.Tags, &b.entry.Archived); err != nil {
+			rows.Close()
+			return ImportJob{}, err
+		}
+		batch = append(batch, b)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return ImportJob{}, err
+	}
+
+	created, duplicates := 0, 0
+	for _, b := range batch {
+		e := b.entry
+		state := ItemStateUnread
+		if e.Archived {
+			state = ItemStateArchived
+		}
+		var itemID string
+		err = tx.QueryRow(ctx, `
+			INSERT INTO items (user_id, url, canonical_url, canonical_hash, title, fetch_status, refetch_requested, fetch_priority,
+				created_at, updated_at, state, archived_at)
+			VALUES ($1, $2, $3, $4, $5, 'pending', false, $6,
+				COALESCE($7, NOW()), NOW(), $8, CASE WHEN $8 = 'archived' THEN COALESCE($7, NOW()) END)
+			ON CONFLICT (user_id, canonical_hash) WHERE deleted_at IS NULL AND workspace_id IS NULL DO NOTHING
+			RETURNING id
+		`, userID, e.URL, e.CanonicalURL, e.CanonicalHash, e.Title, fetchPriorityBulk, b.timeAdded, state).Scan(&itemID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				err = nil
+				duplicates++
+				continue
+			}
+			return ImportJob{}, err
+		}
+		if len(e.Tags) > 0 {
+			if err = attachTags(ctx, tx, tagScope{UserID: userID}, itemID, e.Tags); err != nil {
+				return ImportJob{}, err
+			}
+		}
+		if err = enqueueWebhooks(ctx, tx, WebhookItemCreated, itemID); err != nil {
+			return ImportJob{}, err
+		}
+		created++
+	}
+
+	job, err := scanImportJob(tx.QueryRow(ctx, `
+		UPDATE import_jobs j
+		SET processed = processed + $2,
+			created_count = created_count + $3,
+			duplicate_count = duplicate_count + $4,
+			started_at = COALESCE(started_at, NOW()),
+			status = CASE WHEN EXISTS (SELECT 1 FROM import_entries e WHERE e.job_id = j.id) THEN 'running' ELSE 'done' END,
+			finished_at = CASE WHEN EXISTS (SELECT 1 FROM import_entries e WHERE e.job_id = j.id) THEN NULL ELSE NOW() END
+		WHERE id=$1
+		RETURNING `+importJobColumns,
+		jobID, len(batch), created, duplicates))
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return ImportJob{}, err
+	}
+	return job, nil
+}
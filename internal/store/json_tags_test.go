@@ -31,6 +31,21 @@ func TestItemListRowJSONUsesSnakeCase(t *testing.T) {
 	assertHasKey(t, m, "canonical_url")
 	assertHasKey(t, m, "created_at")
 	assertHasKey(t, m, "refetch_requested")
+	assertHasKey(t, m, "state")
+	assertHasKey(t, m, "archived_at")
+	assertHasKey(t, m, "favorite")
+	assertHasKey(t, m, "favorited_at")
+	assertHasKey(t, m, "deleted_at")
+	assertHasKey(t, m, "note")
+	assertHasKey(t, m, "title_edited")
+	assertHasKey(t, m, "excerpt_edited")
+	assertHasKey(t, m, "workspace_id")
+	assertHasKey(t, m, "charset")
+	assertHasKey(t, m, "site_name")
+	assertHasKey(t, m, "author")
+	assertHasKey(t, m, "published_at")
+	assertHasKey(t, m, "image_url")
+	assertHasKey(t, m, "language")
 	assertHasKey(t, m, "tags")
 
 	assertMissingKey(t, m, "ID")
@@ -38,6 +53,7 @@ func TestItemListRowJSONUsesSnakeCase(t *testing.T) {
 	assertMissingKey(t, m, "CanonicalURL")
 	assertMissingKey(t, m, "CreatedAt")
 	assertMissingKey(t, m, "RefetchRequested")
+	assertMissingKey(t, m, "ItemMetadata")
 	assertMissingKey(t, m, "Tags")
 }
 
@@ -53,7 +69,9 @@ func TestItemDetailJSONUsesSnakeCase(t *testing.T) {
 	m := marshalObject(t, detail)
 
 	assertHasKey(t, m, "content_full")
+	assertHasKey(t, m, "content_html")
 	assertHasKey(t, m, "tags")
+	assertHasKey(t, m, "highlights")
 	assertMissingKey(t, m, "ContentFull")
 	assertMissingKey(t, m, "Tags")
 }
@@ -86,6 +104,17 @@ func TestTagJSONUsesSnakeCase(t *testing.T) {
 	assertMissingKey(t, m, "Count")
 }
 
+func TestExportItemJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, ExportItem{ID: "item-1", Tags: []string{"go"}})
+
+	assertHasKey(t, m, "canonical_url")
+	assertHasKey(t, m, "fetch_status")
+	assertHasKey(t, m, "created_at")
+	assertHasKey(t, m, "highlights")
+	assertMissingKey(t, m, "content_full")
+	assertMissingKey(t, m, "CanonicalURL")
+}
+
 func marshalObject(t *testing.T, v any) map[string]any {
 	t.Helper()
 
@@ -115,3 +144,85 @@ func assertMissingKey(t *testing.T, m map[string]any, key string) {
 		t.Fatalf("did not expect key %q, got %v", key, m)
 	}
 }
+
+func TestHighlightJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, Highlight{ID: "h-1", ItemID: "item-1", Quote: "q", Start: 1, End: 2, Anchored: true})
+
+	assertHasKey(t, m, "item_id")
+	assertHasKey(t, m, "anchored")
+	assertHasKey(t, m, "created_at")
+	assertMissingKey(t, m, "ItemID")
+}
+
+func TestCollectionJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, Collection{ID: "c-1", Title: "Reading", ItemCount: 3})
+
+	assertHasKey(t, m, "item_count")
+	assertHasKey(t, m, "updated_at")
+	assertMissingKey(t, m, "ItemCount")
+}
+
+func TestWorkspaceJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, Workspace{ID: "w-1", Name: "Team", Role: RoleOwner, MemberCount: 2})
+
+	assertHasKey(t, m, "member_count")
+	assertHasKey(t, m, "role")
+	assertMissingKey(t, m, "MemberCount")
+
+	m = marshalObject(t, WorkspaceMember{UserID: "u-1", Role: RoleViewer})
+	assertHasKey(t, m, "user_id")
+	assertMissingKey(t, m, "UserID")
+}
+
+func TestItemShareJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, ItemShare{ID: "s-1", ItemID: "item-1", ViewCount: 2})
+
+	assertHasKey(t, m, "item_id")
+	assertHasKey(t, m, "expires_at")
+	assertHasKey(t, m, "view_count")
+	assertHasKey(t, m, "last_viewed_at")
+	assertMissingKey(t, m, "token_hash")
+	assertMissingKey(t, m, "ViewCount")
+}
+
+func TestSubscriptionJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, Subscription{ID: "s-1", FeedURL: "https://example.com/feed", UserID: "u-1", ETag: `"v1"`, SeenEntryIDs: []string{"a"}})
+
+	assertHasKey(t, m, "feed_url")
+	assertHasKey(t, m, "item_count")
+	assertHasKey(t, m, "last_error")
+	assertHasKey(t, m, "next_poll_at")
+	assertMissingKey(t, m, "user_id")
+	assertMissingKey(t, m, "ETag")
+	assertMissingKey(t, m, "SeenEntryIDs")
+}
+
+func TestWebhookJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, WebhookDelivery{ID: "d-1", WebhookID: "w-1", Secret: "s", Payload: []byte(`{}`)})
+
+	assertHasKey(t, m, "webhook_id")
+	assertHasKey(t, m, "response_status")
+	assertHasKey(t, m, "next_attempt_at")
+	assertMissingKey(t, m, "secret")
+	assertMissingKey(t, m, "Secret")
+	assertMissingKey(t, m, "Payload")
+}
+
+func TestPersonalTokenJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, PersonalToken{ID: "t-1", Name: "cli", Scope: "read"})
+
+	assertHasKey(t, m, "scope")
+	assertHasKey(t, m, "created_at")
+	assertHasKey(t, m, "last_used_at")
+	assertMissingKey(t, m, "token_hash")
+	assertMissingKey(t, m, "LastUsedAt")
+}
+
+func TestExtensionDeviceJSONUsesSnakeCase(t *testing.T) {
+	m := marshalObject(t, ExtensionDevice{ID: "d-1", Name: "Chrome"})
+
+	assertHasKey(t, m, "created_at")
+	assertHasKey(t, m, "last_used_at")
+	assertMissingKey(t, m, "refresh_hash")
+	assertMissingKey(t, m, "access_jti")
+}
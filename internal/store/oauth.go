@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrOAuthRequestPending = errors.New("oauth_request_pending")
+	ErrOAuthRequestDenied  = errors.New("oauth_request_denied")
+	ErrOAuthRequestUsed    = errors.New("oauth_request_used")
+)
+
+// OAuthApp is a registered third-party client identified by its consumer key.
+type OAuthApp struct {
+	ID          string    `json:"id"`
+	OwnerUserID string    `json:"owner_user_id"`
+	Name        string    `json:"name"`
+	ConsumerKey string    `json:"consumer_key"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OAuthRequest is a short-lived request token awaiting the user's decision.
+type OAuthRequest struct {
+	Code        string
+	AppID       string
+	AppName     string
+	RedirectURI string
+	State       string
+	UserID      string
+	Status      string
+	ExpiresAt   time.Time
+}
+
+// OAuthToken describes an access token granted to an app, without its secret.
+type OAuthToken struct {
+	ID         string     `json:"id"`
+	AppID      string     `json:"app_id"`
+	AppName    string     `json:"app_name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (s *Store) CreateOAuthApp(ctx context.Context, ownerUserID, name, consumerKey string) (OAuthApp, error) {
+	row := s.DB.QueryRow(ctx, `
+		INSERT INTO oauth_apps (owner_user_id, name, consumer_key)
+		VALUES ($1, $2, $3)
+		RETURNING id, owner_user_id, name, consumer_key, created_at
+	`, ownerUserID, name, consumerKey)
+	var app OAuthApp
+	if err := row.Scan(&app.ID, &app.OwnerUserID, &app.Name, &app.ConsumerKey, &app.CreatedAt); err != nil {
+		return OAuthApp{}, err
+	}
+	return app, nil
+}
+
+func (s *Store) ListOAuthApps(ctx context.Context, ownerUserID string) ([]OAuthApp, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, owner_user_id, name, consumer_key, created_at
+		FROM oauth_apps
+		WHERE owner_user_id=$1
+		ORDER BY created_at DESC
+	`, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := []OAuthApp{}
+	for rows.Next() {
+		var app OAuthApp
+		if err := rows.Scan(&app.ID, &app.OwnerUserID, &app.Name, &app.ConsumerKey, &app.CreatedAt); err != nil {
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+// DeleteOAuthApp removes an app owned by the user together with every token issued to it.
+func (s *Store) DeleteOAuthApp(ctx context.Context, ownerUserID, appID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM oauth_apps WHERE id=$1 AND owner_user_id=$2`, appID, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetOAuthAppByConsumerKey(ctx context.Context, consumerKey string) (OAuthApp, error) {
+	row := s.DB.QueryRow(ctx, `
+		SELECT id, owner_user_id, name, consumer_key, created_at
+		FROM oauth_apps
+		WHERE consumer_key=$1
+	`, consumerKey)
+	var app OAuthApp
+	if err := row.Scan(&app.ID, &app.OwnerUserID, &app.Name, &app.ConsumerKey, &app.CreatedAt); err != nil {
+		return OAuthApp{}, err
+	}
+	return app, nil
+}
+
+func (s *Store) CreateOAuthRequest(ctx context.Context, appID, code, redirectURI, state string, ttl time.Duration) error {
+	_, err := s.DB.Exec(ctx, `
+		INSERT INTO oauth_request_tokens (code, app_id, redirect_uri, state, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + ($5::bigint * INTERVAL '1 second'))
+	`, code, appID, redirectURI, state, int64(ttl.Seconds()))
+	return err
+}
+
+// GetOAuthRequest returns an unexpired request token.
+func (s *Store) GetOAuthRequest(ctx context.Context, code string) (OAuthRequest, error) {
+	row := s.DB.QueryRow(ctx, `
+		SELECT r.code, r.app_id, a.name, r.redirect_uri, r.state, COALESCE(r.user_id::text, ''), r.status, r.expires_at
+		FROM oauth_request_tokens r
+		JOIN oauth_apps a ON a.id=r.app_id
+		WHERE r.code=$1 AND r.expires_at > NOW()
+	`, code)
+	var req OAuthRequest
+	if err := row.Scan(&req.Code, &req.AppID, &req.AppName, &req.RedirectURI, &req.State, &req.UserID, &req.Status, &req.ExpiresAt); err != nil {
+		return OAuthRequest{}, err
+	}
+	return req, nil
+}
+
+// DecideOAuthRequest records the user's approval or denial of a pending request token.
+func (s *Store) DecideOAuthRequest(ctx context.Context, code, userID string, approved bool) error {
+	status := "denied"
+	if approved {
+		status = "approved"
+	}
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE oauth_request_tokens
+		SET status=$1, user_id=$2
+		WHERE code=$3 AND status='pending' AND expires_at > NOW()
+	`, status, userID, code)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ConsumeOAuthRequest exchanges an approved request token for an access token whose
+// hash is tokenHash. Each request token can be exchanged once.
+func (s *Store) ConsumeOAuthRequest(ctx context.Context, appID, code, tokenHash string) (OAuthRequest, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return OAuthRequest{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var req OAuthRequest
+	err = tx.QueryRow(ctx, `
+		SELECT code, app_id, redirect_uri, state, COALESCE(user_id::text, ''), status, expires_at
+		FROM oauth_request_tokens
+		WHERE code=$1 AND app_id=$2 AND expires_at > NOW()
+		FOR UPDATE
+	`, code, appID).Scan(&req.Code, &req.AppID, &req.RedirectURI, &req.State, &req.UserID, &req.Status, &req.ExpiresAt)
+	if err != nil {
+		return OAuthRequest{}, err
+	}
+
+	switch req.Status {
+	case "pending":
+		err = ErrOAuthRequestPending
+	case "denied":
+		err = ErrOAuthRequestDenied
+	case "used":
+		err = ErrOAuthRequestUsed
+	}
+	if err != nil {
+		return OAuthRequest{}, err
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE oauth_request_tokens SET status='used' WHERE code=$1`, code); err != nil {
+		return OAuthRequest{}, err
+	}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO oauth_access_tokens (app_id, user_id, token_hash)
+		VALUES ($1, $2, $3)
+	`, appID, req.UserID, tokenHash); err != nil {
+		return OAuthRequest{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return OAuthRequest{}, err
+	}
+	return req, nil
+}
+
+// GetUserByOAuthToken resolves an app access token and records its use.
+func (s *Store) GetUserByOAuthToken(ctx context.Context, consumerKey, tokenHash string) (User, error) {
+	row := s.DB.QueryRow(ctx, `
+		UPDATE oauth_access_tokens t
+		SET last_used_at=NOW()
+		FROM oauth_apps a, users u
+		WHERE t.token_hash=$1 AND a.id=t.app_id AND a.consumer_key=$2 AND u.id=t.user_id
+		RETURNING u.id, u.google_sub, u.email, u.name, u.avatar_url
+	`, tokenHash, consumerKey)
+	var u User
+	if err := row.Scan(&u.ID, &u.GoogleSub, &u.Email, &u.Name, &u.AvatarURL); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (s *Store) ListOAuthTokens(ctx context.Context, userID string) ([]OAuthToken, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT t.id, t.app_id, a.name, t.created_at, t.last_used_at
+		FROM oauth_access_tokens t
+		JOIN oauth_apps a ON a.id=t.app_id
+		WHERE t.user_id=$1
+		ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []OAuthToken{}
+	for rows.Next() {
+		var t OAuthToken
+		if err := rows.Scan(&t.ID, &t.AppID, &t.AppName, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Store) RevokeOAuthToken(ctx context.Context, userID, tokenID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM oauth_access_tokens WHERE id=$1 AND user_id=$2`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) CleanupExpiredOAuthRequests(ctx context.Context) (int64, error) {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM oauth_request_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
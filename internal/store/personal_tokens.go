@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PersonalToken describes a personal access token, without its secret.
+type PersonalToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (s *Store) CreatePersonalToken(ctx context.Context, userID, name, scope, tokenHash string) (PersonalToken, error) {
+	var t PersonalToken
+	err := s.DB.QueryRow(ctx, `
+		INSERT INTO personal_tokens (user_id, name, scope, token_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, name, scope, created_at, last_used_at
+	`, userID, name, scope, tokenHash).Scan(&t.ID, &t.Name, &t.Scope, &t.CreatedAt, &t.LastUsedAt)
+	if err != nil {
+		return PersonalToken{}, err
+	}
+	return t, nil
+}
+
+func (s *Store) ListPersonalTokens(ctx context.Context, userID string) ([]PersonalToken, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT id, name, scope, created_at, last_used_at
+		FROM personal_tokens
+		WHERE user_id=$1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalToken{}
+	for rows.Next() {
+		var t PersonalToken
+		if err := rows.Scan(&t.ID, &t.Name, &t.Scope, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Store) RevokePersonalToken(ctx context.Context, userID, tokenID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM personal_tokens WHERE id::text=$1 AND user_id=$2`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserByPersonalToken resolves a personal access token to its user and scope, and
+// records its use.
+func (s *Store) GetUserByPersonalToken(ctx context.Context, tokenHash string) (User, string, error) {
+	var u User
+	var scope string
+	err := s.DB.QueryRow(ctx, `
+		WITH t AS (
+			UPDATE personal_tokens SET last_used_at=NOW() WHERE token_hash=$1 RETURNING user_id, scope
+		)
+		SELECT u.id, u.google_sub, u.email, u.name, u.avatar_url, t.scope FROM users u JOIN t ON t.user_id=u.id
+	`, tokenHash).Scan(&u.ID, &u.GoogleSub, &u.Email, &u.Name, &u.AvatarURL, &scope)
+	if err != nil {
+		return User{}, "", err
+	}
+	return u, scope, nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ItemShare is a public read-only link to one item. The token itself is never stored.
+type ItemShare struct {
+	ID           string     `json:"id"`
+	ItemID       string     `json:"item_id"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	ViewCount    int        `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the link no longer opens.
+func (s ItemShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(now)
+}
+
+// SharedItem is what a share link reveals: the page itself, without the owner's tags,
+// notes or highlights.
+type SharedItem struct {
+	Title        string
+	URL          string
+	CanonicalURL string
+	ContentFull  string
+}
+
+// CreateItemShare stores a share link for an item the user may change.
+func (s *Store) CreateItemShare(ctx context.Context, userID, itemID, tokenHash string, expiresAt *time.Time) (ItemShare, error) {
+	share := ItemShare{ItemID: itemID, ExpiresAt: expiresAt}
+	err := s.DB.QueryRow(ctx, `
+		INSERT INTO item_shares (item_id, created_by, token_hash, expires_at)
+		SELECT id, $2, $3, $4 FROM items
+		WHERE id::text=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL
+		RETURNING id, created_at
+	`, itemID, userID, tokenHash, expiresAt).Scan(&share.ID, &share.CreatedAt)
+	if err != nil {
+		return ItemShare{}, err
+	}
+	return share, nil
+}
+
+// ListItemShares returns the item's unrevoked links, newest first, including expired ones.
+func (s *Store) ListItemShares(ctx context.Context, userID, itemID string) ([]ItemShare, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT sh.id, sh.item_id, sh.expires_at, sh.view_count, sh.last_viewed_at, sh.created_at
+		FROM item_shares sh
+		JOIN items i ON i.id=sh.item_id
+		WHERE i.id::text=$1 AND `+itemAccess("i.", "$2", true)+` AND sh.revoked_at IS NULL
+		ORDER BY sh.created_at DESC
+	`, itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []ItemShare{}
+	for rows.Next() {
+		var sh ItemShare
+		if err := rows.Scan(&sh.ID, &sh.ItemID, &sh.ExpiresAt, &sh.ViewCount, &sh.LastViewedAt, &sh.CreatedAt); err != nil {
+			return nil, err
+		}
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+func (s *Store) RevokeItemShare(ctx context.Context, userID, itemID, shareID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE item_shares sh SET revoked_at=NOW()
+		FROM items i
+		WHERE sh.id::text=$1 AND sh.item_id::text=$2 AND i.id=sh.item_id
+			AND `+itemAccess("i.", "$3", true)+` AND sh.revoked_at IS NULL
+	`, shareID, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// OpenSharedItem resolves a live, unexpired link and counts the view. Links to trashed
+// items stop working until the item is restored.
+func (s *Store) OpenSharedItem(ctx context.Context, tokenHash string) (SharedItem, error) {
+	var item SharedItem
+	err := s.DB.QueryRow(ctx, `
+		UPDATE item_shares sh SET view_count=sh.view_count+1, last_viewed_at=NOW()
+		FROM items i
+		LEFT JOIN item_contents c ON c.item_id=i.id
+		WHERE sh.token_hash=$1 AND i.id=sh.item_id AND i.deleted_at IS NULL
+			AND sh.revoked_at IS NULL AND (sh.expires_at IS NULL OR sh.expires_at > NOW())
+		RETURNING i.title, i.url, i.canonical_url, COALESCE(c.content_full, '')
+	`, tokenHash).Scan(&item.Title, &item.URL, &item.CanonicalURL, &item.ContentFull)
+	if err != nil {
+		return SharedItem{}, err
+	}
+	return item, nil
+}
@@ -35,23 +35,47 @@ type Tag struct {
 }
 
 type Item struct {
-	ID               string    `json:"id"`
-	UserID           string    `json:"user_id"`
-	URL              string    `json:"url"`
-	CanonicalURL     string    `json:"canonical_url"`
-	CanonicalHash    string    `json:"canonical_hash"`
-	Title            string    `json:"title"`
-	Excerpt          string    `json:"excerpt"`
-	FetchStatus      string    `json:"fetch_status"`
-	FetchError       string    `json:"fetch_error"`
-	CreatedAt        time.Time `json:"created_at"`
-	RefetchRequested bool      `json:"refetch_requested"`
+	ID               string     `json:"id"`
+	UserID           string     `json:"user_id"`
+	URL              string     `json:"url"`
+	CanonicalURL     string     `json:"canonical_url"`
+	CanonicalHash    string     `json:"canonical_hash"`
+	Title            string     `json:"title"`
+	Excerpt          string     `json:"excerpt"`
+	FetchStatus      string     `json:"fetch_status"`
+	FetchError       string     `json:"fetch_error"`
+	Charset          string     `json:"charset"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	RefetchRequested bool       `json:"refetch_requested"`
+	State            string     `json:"state"`
+	ArchivedAt       *time.Time `json:"archived_at"`
+	Favorite         bool       `json:"favorite"`
+	FavoritedAt      *time.Time `json:"favorited_at"`
+	DeletedAt        *time.Time `json:"deleted_at"`
+	Note             string     `json:"note"`
+	TitleEdited      bool       `json:"title_edited"`
+	ExcerptEdited    bool       `json:"excerpt_edited"`
+	WorkspaceID      *string    `json:"workspace_id"`
+	ItemMetadata
+}
+
+// ItemMetadata is what the page declared about itself on the last successful fetch.
+// Fields are empty (PublishedAt nil) when the page did not say.
+type ItemMetadata struct {
+	SiteName    string     `json:"site_name"`
+	Author      string     `json:"author"`
+	PublishedAt *time.Time `json:"published_at"`
+	ImageURL    string     `json:"image_url"`
+	Language    string     `json:"language"`
 }
 
 type ItemDetail struct {
 	Item
-	ContentFull string `json:"content_full"`
-	Tags        []Tag  `json:"tags"`
+	ContentFull string      `json:"content_full"`
+	ContentHTML string      `json:"content_html"`
+	Tags        []Tag       `json:"tags"`
+	Highlights  []Highlight `json:"highlights"`
 }
 
 type ItemListRow struct {
@@ -99,7 +123,9 @@ func (s *Store) GetUserByID(ctx context.Context, id string) (User, error) {
 }
 
 // CreateItem inserts a new item. tagNames should already be normalized for both display and key.
-func (s *Store) CreateItem(ctx context.Context, userID, url, canonicalURL, canonicalHash string, tagNames []string) (string, bool, error) {
+// A non-empty workspaceID saves the item into that shared workspace, which needs the editor
+// role; duplicates are then detected across the workspace instead of the user's library.
+func (s *Store) CreateItem(ctx context.Context, userID, workspaceID, url, canonicalURL, canonicalHash string, tagNames []string) (string, bool, error) {
 	var itemID string
 	created := false
 
@@ -113,46 +139,62 @@ func (s *Store) CreateItem(ctx context.Context, userID, url, canonicalURL, canon
 		}
 	}()
 
-	row := tx.QueryRow(ctx, `
-		INSERT INTO items (user_id, url, canonical_url, canonical_hash, fetch_status, refetch_requested)
-		VALUES ($1, $2, $3, $4, 'pending', false)
-		ON CONFLICT (user_id, canonical_hash) DO NOTHING
-		RETURNING id
-	`, userID, url, canonicalURL, canonicalHash)
-	if err = row.Scan(&itemID); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			row = tx.QueryRow(ctx, `SELECT id FROM items WHERE user_id=$1 AND canonical_hash=$2`, userID, canonicalHash)
-			if err = row.Scan(&itemID); err != nil {
-				return "", false, err
-			}
-			created = false
-		} else {
+	if workspaceID != "" {
+		var role string
+		err = tx.QueryRow(ctx, `
+			SELECT role FROM workspace_members WHERE workspace_id::text=$1 AND user_id=$2
+		`, workspaceID, userID).Scan(&role)
+		if errors.Is(err, pgx.ErrNoRows) || err == nil && !RoleAtLeast(role, RoleEditor) {
+			err = ErrWorkspaceForbidden
+		}
+		if err != nil {
+			return "", false, err
+		}
+		row := tx.QueryRow(ctx, `
+			INSERT INTO items (user_id, workspace_id, url, canonical_url, canonical_hash, fetch_status, refetch_requested)
+			VALUES ($1, $2, $3, $4, $5, 'pending', false)
+			ON CONFLICT (workspace_id, canonical_hash) WHERE deleted_at IS NULL AND workspace_id IS NOT NULL DO NOTHING
+			RETURNING id
+		`, userID, workspaceID, url, canonicalURL, canonicalHash)
+		if err = row.Scan(&itemID); errors.Is(err, pgx.ErrNoRows) {
+			row = tx.QueryRow(ctx, `SELECT id FROM items WHERE workspace_id=$1 AND canonical_hash=$2 AND deleted_at IS NULL`, workspaceID, canonicalHash)
+			err = row.Scan(&itemID)
+		} else if err == nil {
+			created = true
+		}
+		if err != nil {
 			return "", false, err
 		}
 	} else {
-		created = true
+		row := tx.QueryRow(ctx, `
+			INSERT INTO items (user_id, url, canonical_url, canonical_hash, fetch_status, refetch_requested)
+			VALUES ($1, $2, $3, $4, 'pending', false)
+			ON CONFLICT (user_id, canonical_hash) WHERE deleted_at IS NULL AND workspace_id IS NULL DO NOTHING
+			RETURNING id
+		`, userID, url, canonicalURL, canonicalHash)
+		if err = row.Scan(&itemID); errors.Is(err, pgx.ErrNoRows) {
+			row = tx.QueryRow(ctx, `SELECT id FROM items WHERE user_id=$1 AND canonical_hash=$2 AND deleted_at IS NULL AND workspace_id IS NULL`, userID, canonicalHash)
+			err = row.Scan(&itemID)
+		} else if err == nil {
+			created = true
+		}
+		if err != nil {
+			return "", false, err
+		}
 	}
 
 	if created && len(tagNames) > 0 {
-		for _, name := range tagNames {
-			var tagID string
-			row = tx.QueryRow(ctx, `
-				INSERT INTO tags (name, normalized_name)
-				VALUES ($1, $2)
-				ON CONFLICT (normalized_name) DO UPDATE SET name=EXCLUDED.name
-				RETURNING id
-			`, name, name)
-			if err = row.Scan(&tagID); err != nil {
-				return "", false, err
-			}
-			_, err = tx.Exec(ctx, `
-				INSERT INTO item_tags (item_id, tag_id)
-				VALUES ($1, $2)
-				ON CONFLICT DO NOTHING
-			`, itemID, tagID)
-			if err != nil {
-				return "", false, err
-			}
+		scope := tagScope{UserID: userID}
+		if workspaceID != "" {
+			scope = tagScope{WorkspaceID: workspaceID}
+		}
+		if err = attachTags(ctx, tx, scope, itemID, tagNames); err != nil {
+			return "", false, err
+		}
+	}
+	if created {
+		if err = enqueueWebhooks(ctx, tx, WebhookItemCreated, itemID); err != nil {
+			return "", false, err
 		}
 	}
 
@@ -163,7 +205,34 @@ func (s *Store) CreateItem(ctx context.Context, userID, url, canonicalURL, canon
 	return itemID, created, nil
 }
 
-func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int, q, tag, sort string) ([]ItemListRow, Pagination, error) {
+const (
+	ItemStateUnread   = "unread"
+	ItemStateArchived = "archived"
+)
+
+// ItemFilter narrows ListItems results. Zero values disable the corresponding filter.
+type ItemFilter struct {
+	Query    string
+	Tag      string
+	State    string
+	Favorite *bool
+	Untagged bool
+	Domain   string
+	// Since lists items changed at or after the time. Items trashed since then are
+	// included too, so that syncing clients learn about deletions.
+	Since time.Time
+	Sort  string
+	// Trashed lists only items in the trash; otherwise trashed items are excluded.
+	Trashed bool
+	// Collection limits results to one collection; Sort "position" then follows its
+	// manual order.
+	Collection string
+	// Workspace lists a shared workspace's items instead of the user's own library. The
+	// user must be a member.
+	Workspace string
+}
+
+func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int, filter ItemFilter) ([]ItemListRow, Pagination, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -172,26 +241,84 @@ func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int,
 	}
 	offset := (page - 1) * perPage
 
-	where := []string{"i.user_id = $1"}
+	items, total, err := s.ListItemsRange(ctx, userID, filter, offset, perPage)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+	return items, Pagination{Page: page, PerPage: perPage, Total: total}, nil
+}
+
+// ListItemsRange returns up to limit items starting at offset along with the total match count.
+func (s *Store) ListItemsRange(ctx context.Context, userID string, filter ItemFilter, offset, limit int) ([]ItemListRow, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = 30
+	}
+	q := filter.Query
+
+	where := []string{"i.user_id = $1 AND i.workspace_id IS NULL", "i.deleted_at IS NULL"}
+	if filter.Trashed {
+		where[1] = "i.deleted_at IS NOT NULL"
+	}
 	args := []interface{}{userID}
 	argPos := 2
+	if filter.Workspace != "" {
+		where[0] = fmt.Sprintf("i.workspace_id::text = $%d AND %s", argPos, itemAccess("i.", "$1", false))
+		args = append(args, filter.Workspace)
+		argPos++
+	}
 
 	if q != "" {
-		where = append(where, fmt.Sprintf("(i.title ILIKE $%d OR i.excerpt ILIKE $%d OR c.content_search ILIKE $%d OR i.canonical_url ILIKE $%d OR t.normalized_name ILIKE $%d)", argPos, argPos, argPos, argPos, argPos))
+		where = append(where, fmt.Sprintf("(i.title ILIKE $%d OR i.excerpt ILIKE $%d OR i.note ILIKE $%d OR c.content_search ILIKE $%d OR i.canonical_url ILIKE $%d OR t.normalized_name ILIKE $%d)", argPos, argPos, argPos, argPos, argPos, argPos))
 		args = append(args, "%"+q+"%")
 		argPos++
 	}
-	if tag != "" {
+	if filter.Tag != "" {
 		where = append(where, fmt.Sprintf("t.normalized_name = $%d", argPos))
-		args = append(args, tag)
+		args = append(args, filter.Tag)
+		argPos++
+	}
+	if filter.State != "" {
+		where = append(where, fmt.Sprintf("i.state = $%d", argPos))
+		args = append(args, filter.State)
+		argPos++
+	}
+	if filter.Favorite != nil {
+		where = append(where, fmt.Sprintf("i.favorite = $%d", argPos))
+		args = append(args, *filter.Favorite)
+		argPos++
+	}
+	if filter.Untagged {
+		where = append(where, "NOT EXISTS (SELECT 1 FROM item_tags ut WHERE ut.item_id=i.id)")
+	}
+	if filter.Domain != "" {
+		host := "split_part(split_part(i.canonical_url, '://', 2), '/', 1)"
+		// Compared literally, so that LIKE wildcards in the filter match nothing.
+		where = append(where, fmt.Sprintf("(%s = $%d OR right(%s, length($%d::text) + 1) = '.' || $%d)", host, argPos, host, argPos, argPos))
+		args = append(args, strings.ToLower(filter.Domain))
+		argPos++
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, fmt.Sprintf("i.updated_at >= $%d", argPos))
+		if !filter.Trashed {
+			where[1] = fmt.Sprintf("(i.deleted_at IS NULL OR i.deleted_at >= $%d)", argPos)
+		}
+		args = append(args, filter.Since)
+		argPos++
+	}
+	orderBy := itemOrderBy(filter.Sort, q != "")
+	if filter.Collection != "" {
+		where = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM collection_items ci WHERE ci.item_id=i.id AND ci.collection_id::text=$%d)", argPos))
+		if filter.Sort == "position" {
+			orderBy = fmt.Sprintf("(SELECT ci.position FROM collection_items ci WHERE ci.item_id=i.id AND ci.collection_id::text=$%d) ASC, i.created_at DESC", argPos)
+		}
+		args = append(args, filter.Collection)
 		argPos++
 	}
 
 	whereSQL := strings.Join(where, " AND ")
-	orderBy := "i.created_at DESC"
-	if sort == "relevance" && q != "" {
-		orderBy = "score DESC, i.created_at DESC"
-	}
 
 	countSQL := fmt.Sprintf(`
 		SELECT COUNT(DISTINCT i.id)
@@ -203,12 +330,15 @@ func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int,
 	`, whereSQL)
 	var total int
 	if err := s.DB.QueryRow(ctx, countSQL, args...).Scan(&total); err != nil {
-		return nil, Pagination{}, err
+		return nil, 0, err
 	}
 
 	selectSQL := fmt.Sprintf(`
 		SELECT i.id, i.user_id, i.url, i.canonical_url, i.canonical_hash, i.title, i.excerpt,
-			i.fetch_status, COALESCE(i.fetch_error,''), i.created_at, i.refetch_requested,
+			i.fetch_status, COALESCE(i.fetch_error,''), i.charset, i.created_at, i.updated_at, i.refetch_requested,
+			i.state, i.archived_at, i.favorite, i.favorited_at, i.deleted_at,
+			i.note, i.title_edited, i.excerpt_edited, i.workspace_id,
+			i.site_name, i.author, i.published_at, i.image_url, i.language,
 			COALESCE(array_agg(DISTINCT t.id) FILTER (WHERE t.id IS NOT NULL), '{}') AS tag_ids,
 			COALESCE(array_agg(DISTINCT t.name) FILTER (WHERE t.name IS NOT NULL), '{}') AS tag_names,
 			COALESCE(array_agg(DISTINCT t.normalized_name) FILTER (WHERE t.normalized_name IS NOT NULL), '{}') AS tag_norms,
@@ -228,19 +358,15 @@ func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int,
 		GROUP BY i.id, c.content_search
 		ORDER BY %s
 		LIMIT %d OFFSET %d
-	`, argPos, argPos, argPos, argPos, argPos, whereSQL, orderBy, perPage, offset)
+	`, argPos, argPos, argPos, argPos, argPos, whereSQL, orderBy, limit, offset)
 
 	argsSelect := make([]interface{}, 0, len(args)+1)
 	argsSelect = append(argsSelect, args...)
-	if q == "" {
-		argsSelect = append(argsSelect, "")
-	} else {
-		argsSelect = append(argsSelect, q)
-	}
+	argsSelect = append(argsSelect, q)
 
 	rows, err := s.DB.Query(ctx, selectSQL, argsSelect...)
 	if err != nil {
-		return nil, Pagination{}, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -252,8 +378,11 @@ func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int,
 		var tagNorms []string
 		var score float64
 		if err := rows.Scan(&row.ID, &row.UserID, &row.URL, &row.CanonicalURL, &row.CanonicalHash, &row.Title, &row.Excerpt,
-			&row.FetchStatus, &row.FetchError, &row.CreatedAt, &row.RefetchRequested, &tagIDs, &tagNames, &tagNorms, &score); err != nil {
-			return nil, Pagination{}, err
+			&row.FetchStatus, &row.FetchError, &row.Charset, &row.CreatedAt, &row.UpdatedAt, &row.RefetchRequested,
+			&row.State, &row.ArchivedAt, &row.Favorite, &row.FavoritedAt, &row.DeletedAt,
+			&row.Note, &row.TitleEdited, &row.ExcerptEdited, &row.WorkspaceID,
+			&row.SiteName, &row.Author, &row.PublishedAt, &row.ImageURL, &row.Language, &tagIDs, &tagNames, &tagNorms, &score); err != nil {
+			return nil, 0, err
 		}
 		row.Tags = make([]Tag, 0, len(tagIDs))
 		for i := range tagIDs {
@@ -262,17 +391,38 @@ func (s *Store) ListItems(ctx context.Context, userID string, page, perPage int,
 		items = append(items, row)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, Pagination{}, err
+		return nil, 0, err
 	}
 
-	return items, Pagination{Page: page, PerPage: perPage, Total: total}, nil
+	return items, total, nil
+}
+
+func itemOrderBy(sort string, hasQuery bool) string {
+	switch sort {
+	case "relevance":
+		if hasQuery {
+			return "score DESC, i.created_at DESC"
+		}
+	case "oldest":
+		return "i.created_at ASC"
+	case "title":
+		return "i.title ASC, i.created_at DESC"
+	case "site":
+		return "i.canonical_url ASC, i.created_at DESC"
+	case "deleted":
+		return "i.deleted_at DESC NULLS LAST, i.created_at DESC"
+	}
+	return "i.created_at DESC"
 }
 
 func (s *Store) GetItemDetail(ctx context.Context, userID, itemID string) (ItemDetail, error) {
 	row := s.DB.QueryRow(ctx, `
 		SELECT i.id, i.user_id, i.url, i.canonical_url, i.canonical_hash, i.title, i.excerpt,
-			i.fetch_status, COALESCE(i.fetch_error,''), i.created_at, i.refetch_requested,
-			COALESCE(c.content_full,''),
+			i.fetch_status, COALESCE(i.fetch_error,''), i.charset, i.created_at, i.updated_at, i.refetch_requested,
+			i.state, i.archived_at, i.favorite, i.favorited_at,
+			i.note, i.title_edited, i.excerpt_edited, i.workspace_id,
+			i.site_name, i.author, i.published_at, i.image_url, i.language,
+			COALESCE(c.content_full,''), COALESCE(c.content_html,''),
 			COALESCE(array_agg(DISTINCT t.id) FILTER (WHERE t.id IS NOT NULL), '{}') AS tag_ids,
 			COALESCE(array_agg(DISTINCT t.name) FILTER (WHERE t.name IS NOT NULL), '{}') AS tag_names,
 			COALESCE(array_agg(DISTINCT t.normalized_name) FILTER (WHERE t.normalized_name IS NOT NULL), '{}') AS tag_norms
@@ -280,24 +430,34 @@ func (s *Store) GetItemDetail(ctx context.Context, userID, itemID string) (ItemD
 		LEFT JOIN item_contents c ON c.item_id=i.id
 		LEFT JOIN item_tags it ON it.item_id=i.id
 		LEFT JOIN tags t ON t.id=it.tag_id
-		WHERE i.user_id=$1 AND i.id=$2
-		GROUP BY i.id, c.content_full
+		WHERE `+itemAccess("i.", "$1", false)+` AND i.id=$2 AND i.deleted_at IS NULL
+		GROUP BY i.id, c.content_full, c.content_html
 	`, userID, itemID)
 	var detail ItemDetail
 	var tagIDs []string
 	var tagNames []string
 	var tagNorms []string
 	if err := row.Scan(&detail.ID, &detail.UserID, &detail.URL, &detail.CanonicalURL, &detail.CanonicalHash, &detail.Title, &detail.Excerpt,
-		&detail.FetchStatus, &detail.FetchError, &detail.CreatedAt, &detail.RefetchRequested, &detail.ContentFull, &tagIDs, &tagNames, &tagNorms); err != nil {
+		&detail.FetchStatus, &detail.FetchError, &detail.Charset, &detail.CreatedAt, &detail.UpdatedAt, &detail.RefetchRequested,
+		&detail.State, &detail.ArchivedAt, &detail.Favorite, &detail.FavoritedAt,
+		&detail.Note, &detail.TitleEdited, &detail.ExcerptEdited, &detail.WorkspaceID,
+		&detail.SiteName, &detail.Author, &detail.PublishedAt, &detail.ImageURL, &detail.Language, &detail.ContentFull, &detail.ContentHTML, &tagIDs, &tagNames, &tagNorms); err != nil {
 		return ItemDetail{}, err
 	}
 	detail.Tags = make([]Tag, 0, len(tagIDs))
 	for i := range tagIDs {
 		detail.Tags = append(detail.Tags, Tag{ID: tagIDs[i], Name: tagNames[i], NormalizedName: tagNorms[i]})
 	}
+	highlights, err := s.itemHighlights(ctx, detail.ID)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+	detail.Highlights = highlights
 	return detail, nil
 }
 
+// DeleteItem moves the item to the trash. Its content and tags are kept so it can be
+// restored until the trash is emptied or purged.
 func (s *Store) DeleteItem(ctx context.Context, userID, itemID string) error {
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
@@ -309,37 +469,62 @@ func (s *Store) DeleteItem(ctx context.Context, userID, itemID string) error {
 		}
 	}()
 
-	_, err = tx.Exec(ctx, `DELETE FROM item_contents WHERE item_id=$1 AND EXISTS (SELECT 1 FROM items WHERE id=$1 AND user_id=$2)`, itemID, userID)
+	ct, err := tx.Exec(ctx, `
+		UPDATE items SET deleted_at=NOW(), updated_at=NOW()
+		WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL
+	`, itemID, userID)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec(ctx, `DELETE FROM item_tags WHERE item_id=$1 AND EXISTS (SELECT 1 FROM items WHERE id=$1 AND user_id=$2)`, itemID, userID)
-	if err != nil {
+	if ct.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+	if err = enqueueWebhooks(ctx, tx, WebhookItemDeleted, itemID); err != nil {
 		return err
 	}
-	ct, err := tx.Exec(ctx, `DELETE FROM items WHERE id=$1 AND user_id=$2`, itemID, userID)
+	return tx.Commit(ctx)
+}
+
+func (s *Store) RequestRefetch(ctx context.Context, userID, itemID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE items SET refetch_requested=true WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL
+	`, itemID, userID)
 	if err != nil {
 		return err
 	}
 	if ct.RowsAffected() == 0 {
 		return pgx.ErrNoRows
 	}
-	_, err = tx.Exec(ctx, `
-		DELETE FROM tags t
-		WHERE NOT EXISTS (SELECT 1 FROM item_tags it WHERE it.tag_id=t.id)
-	`)
+	return nil
+}
+
+// SetItemArchived moves the item to the archive, or back to the unread queue.
+func (s *Store) SetItemArchived(ctx context.Context, userID, itemID string, archived bool) error {
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE items
+		SET state = CASE WHEN $3 THEN 'archived' ELSE 'unread' END,
+			archived_at = CASE WHEN $3 THEN COALESCE(archived_at, NOW()) ELSE NULL END,
+			updated_at = NOW()
+		WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL
+	`, itemID, userID, archived)
 	if err != nil {
 		return err
 	}
-
-	err = tx.Commit(ctx)
-	return err
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
 }
 
-func (s *Store) RequestRefetch(ctx context.Context, userID, itemID string) error {
+func (s *Store) SetItemFavorite(ctx context.Context, userID, itemID string, favorite bool) error {
 	ct, err := s.DB.Exec(ctx, `
-		UPDATE items SET refetch_requested=true WHERE id=$1 AND user_id=$2
-	`, itemID, userID)
+		UPDATE items
+		SET favorite = $3,
+			favorited_at = CASE WHEN $3 THEN COALESCE(favorited_at, NOW()) ELSE NULL END,
+			updated_at = NOW()
+		WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL
+	`, itemID, userID, favorite)
 	if err != nil {
 		return err
 	}
@@ -350,6 +535,32 @@ func (s *Store) RequestRefetch(ctx context.Context, userID, itemID string) error
 }
 
 func (s *Store) ReplaceItemTags(ctx context.Context, userID, itemID string, tagNames []string) ([]Tag, error) {
+	return s.editItemTags(ctx, userID, itemID, func(tx pgx.Tx, scope tagScope) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM item_tags WHERE item_id=$1`, itemID); err != nil {
+			return err
+		}
+		return attachTags(ctx, tx, scope, itemID, tagNames)
+	})
+}
+
+// AddItemTags links tagNames to the item, keeping its existing tags.
+func (s *Store) AddItemTags(ctx context.Context, userID, itemID string, tagNames []string) ([]Tag, error) {
+	return s.editItemTags(ctx, userID, itemID, func(tx pgx.Tx, scope tagScope) error {
+		return attachTags(ctx, tx, scope, itemID, tagNames)
+	})
+}
+
+// RemoveItemTags unlinks tagNames from the item. Unknown names are ignored.
+func (s *Store) RemoveItemTags(ctx context.Context, userID, itemID string, tagNames []string) ([]Tag, error) {
+	return s.editItemTags(ctx, userID, itemID, func(tx pgx.Tx, _ tagScope) error {
+		return detachTags(ctx, tx, itemID, tagNames)
+	})
+}
+
+// editItemTags runs edit inside a transaction scoped to an item the user may change, sweeps
+// orphaned tags and returns the item's resulting tag set. edit receives the namespace the
+// item's tags live in: the workspace for shared items, otherwise the user's own.
+func (s *Store) editItemTags(ctx context.Context, userID, itemID string, edit func(tx pgx.Tx, scope tagScope) error) ([]Tag, error) {
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
 		return nil, err
@@ -360,46 +571,114 @@ func (s *Store) ReplaceItemTags(ctx context.Context, userID, itemID string, tagN
 		}
 	}()
 
-	var existingItemID string
-	if err = tx.QueryRow(ctx, `SELECT id FROM items WHERE id=$1 AND user_id=$2`, itemID, userID).Scan(&existingItemID); err != nil {
+	var ownerID string
+	var workspaceID *string
+	if err = tx.QueryRow(ctx, `UPDATE items SET updated_at=NOW() WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NULL RETURNING user_id, workspace_id`, itemID, userID).Scan(&ownerID, &workspaceID); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, pgx.ErrNoRows
 		}
 		return nil, err
 	}
 
-	_, err = tx.Exec(ctx, `DELETE FROM item_tags WHERE item_id=$1`, itemID)
+	scope := itemTagScope(ownerID, workspaceID)
+	if err = edit(tx, scope); err != nil {
+		return nil, err
+	}
+	if err = enqueueWebhooks(ctx, tx, WebhookItemTagged, itemID); err != nil {
+		return nil, err
+	}
+
+	if err = deleteOrphanTags(ctx, tx, scope); err != nil {
+		return nil, err
+	}
+
+	tags, err := listItemTags(ctx, tx, itemID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// tagScope is a tag namespace: a workspace's when WorkspaceID is set, otherwise the user's.
+type tagScope struct {
+	UserID      string
+	WorkspaceID string
+}
+
+// itemTagScope returns the namespace of an item's tags from its user_id and workspace_id.
+// Shared items never use the saving member's namespace, so that members cannot reach
+// them through their personal tags.
+func itemTagScope(userID string, workspaceID *string) tagScope {
+	if workspaceID != nil {
+		return tagScope{WorkspaceID: *workspaceID}
+	}
+	return tagScope{UserID: userID}
+}
+
+// attachTags links the item to tags in scope, creating them there as needed.
+func attachTags(ctx context.Context, tx pgx.Tx, scope tagScope, itemID string, tagNames []string) error {
 	for _, name := range tagNames {
 		var tagID string
-		if err = tx.QueryRow(ctx, `
-			INSERT INTO tags (name, normalized_name)
-			VALUES ($1, $2)
-			ON CONFLICT (normalized_name) DO UPDATE SET name=EXCLUDED.name
-			RETURNING id
-		`, name, name).Scan(&tagID); err != nil {
-			return nil, err
+		var err error
+		if scope.WorkspaceID != "" {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO tags (workspace_id, name, normalized_name)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (workspace_id, normalized_name) WHERE workspace_id IS NOT NULL DO UPDATE SET name=EXCLUDED.name
+				RETURNING id
+			`, scope.WorkspaceID, name, name).Scan(&tagID)
+		} else {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO tags (user_id, name, normalized_name)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (user_id, normalized_name) DO UPDATE SET name=EXCLUDED.name
+				RETURNING id
+			`, scope.UserID, name, name).Scan(&tagID)
+		}
+		if err != nil {
+			return err
 		}
-		if _, err = tx.Exec(ctx, `
+		if _, err := tx.Exec(ctx, `
 			INSERT INTO item_tags (item_id, tag_id)
 			VALUES ($1, $2)
 			ON CONFLICT DO NOTHING
 		`, itemID, tagID); err != nil {
-			return nil, err
+			return err
 		}
 	}
+	return nil
+}
 
-	_, err = tx.Exec(ctx, `
-		DELETE FROM tags t
-		WHERE NOT EXISTS (SELECT 1 FROM item_tags it WHERE it.tag_id=t.id)
-	`)
-	if err != nil {
-		return nil, err
+func detachTags(ctx context.Context, tx pgx.Tx, itemID string, tagNames []string) error {
+	if len(tagNames) == 0 {
+		return nil
 	}
+	_, err := tx.Exec(ctx, `
+		DELETE FROM item_tags it
+		USING tags t
+		WHERE it.tag_id=t.id AND it.item_id=$1 AND t.normalized_name = ANY($2)
+	`, itemID, tagNames)
+	return err
+}
 
+// deleteOrphanTags removes the tags in scope that no longer label any item.
+func deleteOrphanTags(ctx context.Context, tx pgx.Tx, scope tagScope) error {
+	column, owner := "t.user_id", scope.UserID
+	if scope.WorkspaceID != "" {
+		column, owner = "t.workspace_id", scope.WorkspaceID
+	}
+	_, err := tx.Exec(ctx, `
+		DELETE FROM tags t
+		WHERE `+column+`=$1 AND NOT EXISTS (SELECT 1 FROM item_tags it WHERE it.tag_id=t.id)
+	`, owner)
+	return err
+}
+
+func listItemTags(ctx context.Context, tx pgx.Tx, itemID string) ([]Tag, error) {
 	rows, err := tx.Query(ctx, `
 		SELECT t.id, t.name, t.normalized_name
 		FROM tags t
@@ -415,28 +694,21 @@ func (s *Store) ReplaceItemTags(ctx context.Context, userID, itemID string, tagN
 	tags := []Tag{}
 	for rows.Next() {
 		var t Tag
-		if err = rows.Scan(&t.ID, &t.Name, &t.NormalizedName); err != nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.NormalizedName); err != nil {
 			return nil, err
 		}
 		tags = append(tags, t)
 	}
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	if err = tx.Commit(ctx); err != nil {
-		return nil, err
-	}
-	return tags, nil
+	return tags, rows.Err()
 }
 
-func (s *Store) SuggestTags(ctx context.Context, q string) ([]Tag, error) {
+func (s *Store) SuggestTags(ctx context.Context, userID, q string) ([]Tag, error) {
 	rows, err := s.DB.Query(ctx, `
 		SELECT id, name, normalized_name FROM tags
-		WHERE normalized_name ILIKE $1
+		WHERE user_id=$1 AND normalized_name ILIKE $2
 		ORDER BY normalized_name
 		LIMIT 20
-	`, "%"+q+"%")
+	`, userID, "%"+q+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -458,8 +730,8 @@ func (s *Store) ListTagsWithCount(ctx context.Context, userID string) ([]Tag, er
 		SELECT t.id, t.name, t.normalized_name, COUNT(it.item_id) AS count
 		FROM tags t
 		JOIN item_tags it ON it.tag_id=t.id
-		JOIN items i ON i.id=it.item_id
-		WHERE i.user_id=$1
+		JOIN items i ON i.id=it.item_id AND i.deleted_at IS NULL AND i.workspace_id IS NULL
+		WHERE t.user_id=$1
 		GROUP BY t.id
 		ORDER BY t.normalized_name
 	`, userID)
@@ -479,7 +751,9 @@ func (s *Store) ListTagsWithCount(ctx context.Context, userID string) ([]Tag, er
 	return tags, rows.Err()
 }
 
-// ClaimItemsForFetch selects up to limit items and marks them as fetching.
+// ClaimItemsForFetch selects up to limit items and marks them as fetching. Items are
+// taken round-robin across users, and bulk-imported items only after interactive saves
+// and refetch requests, so one large import cannot starve everyone else's queue.
 func (s *Store) ClaimItemsForFetch(ctx context.Context, limit int) ([]Item, error) {
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
@@ -494,10 +768,21 @@ func (s *Store) ClaimItemsForFetch(ctx context.Context, limit int) ([]Item, erro
 	rows, err := tx.Query(ctx, `
 		SELECT id, user_id, url, refetch_requested
 		FROM items
-		WHERE fetch_status='pending' OR refetch_requested=true
-		ORDER BY created_at ASC
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, priority, ROW_NUMBER() OVER (PARTITION BY user_id, priority ORDER BY created_at ASC) AS turn
+				FROM (
+					SELECT id, user_id, created_at,
+						CASE WHEN refetch_requested THEN 0 ELSE fetch_priority END AS priority
+					FROM items
+					WHERE (fetch_status='pending' OR refetch_requested=true) AND deleted_at IS NULL
+				) queued
+			) ranked
+			ORDER BY priority ASC, turn ASC
+			LIMIT $1
+		)
+		AND (fetch_status='pending' OR refetch_requested=true)
 		FOR UPDATE SKIP LOCKED
-		LIMIT $1
 	`, limit)
 	if err != nil {
 		return nil, err
@@ -533,7 +818,7 @@ func (s *Store) ClaimItemsForFetch(ctx context.Context, limit int) ([]Item, erro
 	return items, nil
 }
 
-func (s *Store) UpdateFetchSuccess(ctx context.Context, itemID, title, excerpt, contentFull, contentSearch string, contentBytes int) error {
+func (s *Store) UpdateFetchSuccess(ctx context.Context, itemID, title, excerpt, contentFull, contentHTML, contentSearch string, contentBytes int, charset string, meta ItemMetadata) error {
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
 		return err
@@ -546,30 +831,55 @@ func (s *Store) UpdateFetchSuccess(ctx context.Context, itemID, title, excerpt,
 
 	_, err = tx.Exec(ctx, `
 		UPDATE items
-		SET title=$1, excerpt=$2, fetch_status='success', fetch_error='', fetched_at=NOW(), refetch_requested=false
+		SET title=CASE WHEN title_edited THEN title ELSE COALESCE(NULLIF($1, ''), title) END,
+			excerpt=CASE WHEN excerpt_edited THEN excerpt ELSE $2 END,
+			charset=$4, site_name=$5, author=$6, published_at=$7, image_url=$8, language=$9,
+			fetch_status='success', fetch_error='', fetched_at=NOW(), refetch_requested=false, updated_at=NOW()
 		WHERE id=$3
-	`, title, excerpt, itemID)
+	`, title, excerpt, itemID, charset, meta.SiteName, meta.Author, meta.PublishedAt, meta.ImageURL, meta.Language)
 	if err != nil {
 		return err
 	}
 
 	_, err = tx.Exec(ctx, `
-		INSERT INTO item_contents (item_id, content_full, content_search, content_bytes)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (item_id) DO UPDATE SET content_full=EXCLUDED.content_full, content_search=EXCLUDED.content_search, content_bytes=EXCLUDED.content_bytes
-	`, itemID, contentFull, contentSearch, contentBytes)
+		INSERT INTO item_contents (item_id, content_full, content_html, content_search, content_bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (item_id) DO UPDATE SET content_full=EXCLUDED.content_full, content_html=EXCLUDED.content_html, content_search=EXCLUDED.content_search, content_bytes=EXCLUDED.content_bytes
+	`, itemID, contentFull, contentHTML, contentSearch, contentBytes)
 	if err != nil {
 		return err
 	}
+	if err = reanchorHighlights(ctx, tx, itemID, contentFull); err != nil {
+		return err
+	}
+	if err = enqueueWebhooks(ctx, tx, WebhookItemFetched, itemID); err != nil {
+		return err
+	}
 
 	return tx.Commit(ctx)
 }
 
 func (s *Store) UpdateFetchFailure(ctx context.Context, itemID, reason string) error {
-	_, err := s.DB.Exec(ctx, `
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
 		UPDATE items
-		SET fetch_status='failed', fetch_error=$1, refetch_requested=false
+		SET fetch_status='failed', fetch_error=$1, refetch_requested=false, updated_at=NOW()
 		WHERE id=$2
 	`, reason, itemID)
-	return err
+	if err != nil {
+		return err
+	}
+	if err = enqueueWebhooks(ctx, tx, WebhookItemFetchFailed, itemID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDomainFilterMatchesHostsLiterally(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	for _, u := range []string{
+		"https://example.com/a",
+		"https://blog.example.com/b",
+		"https://badexample.com/c",
+		"https://example_com.org/d",
+	} {
+		createTestItem(t, s, alice.ID, "", u)
+	}
+
+	cases := map[string]int{
+		"example.com": 2,
+		"EXAMPLE.com": 2,
+		"%":           0,
+		"%.com":       0,
+		"example_com": 0,
+		"_xample.com": 0,
+	}
+	for domain, want := range cases {
+		_, total, err := s.ListItemsRange(ctx, alice.ID, ItemFilter{Domain: domain}, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != want {
+			t.Fatalf("domain %q matched %d items, want %d", domain, total, want)
+		}
+	}
+}
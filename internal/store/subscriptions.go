@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrSubscriptionExists = errors.New("subscription_exists")
+
+// Subscription is a followed feed. Entries seen for the first time are saved to the
+// user's library with Tags. LastError and ErrorCount describe consecutive failed polls.
+type Subscription struct {
+	ID            string     `json:"id"`
+	FeedURL       string     `json:"feed_url"`
+	Title         string     `json:"title"`
+	SiteURL       string     `json:"site_url"`
+	Tags          []string   `json:"tags"`
+	ItemCount     int        `json:"item_count"`
+	LastError     string     `json:"last_error"`
+	ErrorCount    int        `json:"error_count"`
+	LastPolledAt  *time.Time `json:"last_polled_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	NextPollAt    time.Time  `json:"next_poll_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	// Poll state, loaded only for the worker.
+	UserID       string   `json:"-"`
+	ETag         string   `json:"-"`
+	LastModified string   `json:"-"`
+	SeenEntryIDs []string `json:"-"`
+}
+
+// DisplayTitle falls back to the feed URL until the first poll has read the title.
+func (s Subscription) DisplayTitle() string {
+	if s.Title != "" {
+		return s.Title
+	}
+	return s.FeedURL
+}
+
+// TagList joins the tags for form fields.
+func (s Subscription) TagList() string {
+	return strings.Join(s.Tags, ", ")
+}
+
+// SubscriptionPoll is the outcome of a successful poll. Title, SiteURL and SeenEntryIDs
+// are left unchanged when empty or nil, as after a 304 response.
+type SubscriptionPoll struct {
+	Title        string
+	SiteURL      string
+	ETag         string
+	LastModified string
+	SeenEntryIDs []string
+	Saved        int
+}
+
+const subscriptionColumns = `
+	su.id, su.feed_url, su.title, su.site_url, su.tags, su.item_count, su.last_error, su.error_count,
+	su.last_polled_at, su.last_success_at, su.next_poll_at, su.created_at`
+
+func scanSubscription(row pgx.Row, extra ...interface{}) (Subscription, error) {
+	var sub Subscription
+	dest := []interface{}{&sub.ID, &sub.FeedURL, &sub.Title, &sub.SiteURL, &sub.Tags, &sub.ItemCount, &sub.LastError, &sub.ErrorCount,
+		&sub.LastPolledAt, &sub.LastSuccessAt, &sub.NextPollAt, &sub.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+func (s *Store) ListSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions su
+		WHERE su.user_id=$1
+		ORDER BY lower(COALESCE(NULLIF(su.title, ''), su.feed_url)), su.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// CreateSubscription follows feedURL; it is polled on the worker's next tick. Tags must
+// already be normalized. Following the same URL twice returns ErrSubscriptionExists.
+func (s *Store) CreateSubscription(ctx context.Context, userID, feedURL, title, siteURL string, tags []string) (Subscription, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	sub, err := scanSubscription(s.DB.QueryRow(ctx, `
+		INSERT INTO subscriptions AS su (user_id, feed_url, title, site_url, tags)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+subscriptionColumns, userID, feedURL, title, siteURL, tags))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			err = ErrSubscriptionExists
+		}
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// SetSubscriptionTags replaces the tags applied to items saved from now on.
+func (s *Store) SetSubscriptionTags(ctx context.Context, userID, subscriptionID string, tags []string) (Subscription, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	return scanSubscription(s.DB.QueryRow(ctx, `
+		UPDATE subscriptions su SET tags=$3
+		WHERE su.id::text=$1 AND su.user_id=$2
+		RETURNING `+subscriptionColumns, subscriptionID, userID, tags))
+}
+
+// DeleteSubscription unfollows the feed. Items already saved from it are kept.
+func (s *Store) DeleteSubscription(ctx context.Context, userID, subscriptionID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM subscriptions WHERE id::text=$1 AND user_id=$2`, subscriptionID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDueSubscriptions returns feeds whose next poll is due, with their poll state. The
+// claim pushes next_poll_at out by lease so that a crashed poll is retried later rather
+// than by a concurrent worker.
+func (s *Store) ClaimDueSubscriptions(ctx context.Context, limit int, lease time.Duration) ([]Subscription, error) {
+	rows, err := s.DB.Query(ctx, `
+		UPDATE subscriptions su SET next_poll_at=NOW() + ($2::bigint * INTERVAL '1 second')
+		WHERE su.id IN (
+			SELECT id FROM subscriptions
+			WHERE next_poll_at <= NOW()
+			ORDER BY next_poll_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+subscriptionColumns+`, su.user_id, su.etag, su.last_modified, su.seen_entry_ids
+	`, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		var userID, etag, lastModified string
+		var seen []string
+		sub, err := scanSubscription(rows, &userID, &etag, &lastModified, &seen)
+		if err != nil {
+			return nil, err
+		}
+		sub.UserID, sub.ETag, sub.LastModified, sub.SeenEntryIDs = userID, etag, lastModified, seen
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *Store) UpdateSubscriptionSuccess(ctx context.Context, subscriptionID string, poll SubscriptionPoll, nextPoll time.Time) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE subscriptions
+		SET title=COALESCE(NULLIF($2, ''), title),
+			site_url=COALESCE(NULLIF($3, ''), site_url),
+			etag=$4, last_modified=$5,
+			seen_entry_ids=COALESCE($6, seen_entry_ids),
+			item_count=item_count + $7,
+			last_error='', error_count=0,
+			last_polled_at=NOW(), last_success_at=NOW(), next_poll_at=$8
+		WHERE id=$1
+	`, subscriptionID, poll.Title, poll.SiteURL, poll.ETag, poll.LastModified, poll.SeenEntryIDs, poll.Saved, nextPoll)
+	return err
+}
+
+// UpdateSubscriptionFailure records a failed poll. Validators and seen entries are kept,
+// so the entries are picked up once the feed recovers.
+func (s *Store) UpdateSubscriptionFailure(ctx context.Context, subscriptionID, reason string, nextPoll time.Time) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE subscriptions
+		SET last_error=$2, error_count=error_count + 1, last_polled_at=NOW(), next_poll_at=$3
+		WHERE id=$1
+	`, subscriptionID, reason, nextPoll)
+	return err
+}
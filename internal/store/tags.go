@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrTagExists    = errors.New("tag_exists")
+	ErrTagMergeSelf = errors.New("tag_merge_self")
+)
+
+func (s *Store) GetTag(ctx context.Context, userID, tagID string) (Tag, error) {
+	return scanTagWithCount(s.DB.QueryRow(ctx, `
+		SELECT t.id, t.name, t.normalized_name, (SELECT COUNT(*) FROM item_tags it WHERE it.tag_id=t.id)
+		FROM tags t
+		WHERE t.id=$1 AND t.user_id=$2
+	`, tagID, userID))
+}
+
+// GetTagByName looks a tag up by its normalized name in the user's namespace.
+func (s *Store) GetTagByName(ctx context.Context, userID, normalizedName string) (Tag, error) {
+	return scanTagWithCount(s.DB.QueryRow(ctx, `
+		SELECT t.id, t.name, t.normalized_name, (SELECT COUNT(*) FROM item_tags it WHERE it.tag_id=t.id)
+		FROM tags t
+		WHERE t.normalized_name=$1 AND t.user_id=$2
+	`, normalizedName, userID))
+}
+
+// RenameTag changes the tag's name on every item it labels. Renaming onto another of
+// the user's tags fails with ErrTagExists; use MergeTag for that.
+func (s *Store) RenameTag(ctx context.Context, userID, tagID, newName string) (Tag, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	t, err := scanTagWithCount(tx.QueryRow(ctx, `
+		UPDATE tags t
+		SET name=$3, normalized_name=$3
+		WHERE t.id=$1 AND t.user_id=$2
+		RETURNING t.id, t.name, t.normalized_name, (SELECT COUNT(*) FROM item_tags it WHERE it.tag_id=t.id)
+	`, tagID, userID, newName))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			err = ErrTagExists
+		}
+		return Tag{}, err
+	}
+	touched, err := touchTaggedItems(ctx, tx, tagID)
+	if err != nil {
+		return Tag{}, err
+	}
+	if err = announceTagged(ctx, tx, touched); err != nil {
+		return Tag{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Tag{}, err
+	}
+	return t, nil
+}
+
+// MergeTag relabels every item tagged with sourceID as targetID and removes the source
+// tag. Items that already carry both keep a single link.
+func (s *Store) MergeTag(ctx context.Context, userID, sourceID, targetID string) (Tag, error) {
+	if sourceID == targetID {
+		return Tag{}, ErrTagMergeSelf
+	}
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var found int
+	if err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM tags WHERE id IN ($1, $2) AND user_id=$3 FOR UPDATE
+		) owned
+	`, sourceID, targetID, userID).Scan(&found); err != nil {
+		return Tag{}, err
+	}
+	if found != 2 {
+		err = pgx.ErrNoRows
+		return Tag{}, err
+	}
+
+	touched, err := touchTaggedItems(ctx, tx, sourceID)
+	if err != nil {
+		return Tag{}, err
+	}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO item_tags (item_id, tag_id)
+		SELECT it.item_id, $2 FROM item_tags it
+		JOIN items i ON i.id=it.item_id AND i.workspace_id IS NULL
+		WHERE it.tag_id=$1
+		ON CONFLICT DO NOTHING
+	`, sourceID, targetID); err != nil {
+		return Tag{}, err
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM tags WHERE id=$1`, sourceID); err != nil {
+		return Tag{}, err
+	}
+	if err = announceTagged(ctx, tx, touched); err != nil {
+		return Tag{}, err
+	}
+
+	t, err := scanTagWithCount(tx.QueryRow(ctx, `
+		SELECT t.id, t.name, t.normalized_name, (SELECT COUNT(*) FROM item_tags it WHERE it.tag_id=t.id)
+		FROM tags t
+		WHERE t.id=$1
+	`, targetID))
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Tag{}, err
+	}
+	return t, nil
+}
+
+// DeleteTag removes the tag from every item and deletes it.
+func (s *Store) DeleteTag(ctx context.Context, userID, tagID string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	touched, err := touchTaggedItems(ctx, tx, tagID)
+	if err != nil {
+		return err
+	}
+	ct, err := tx.Exec(ctx, `DELETE FROM tags WHERE id=$1 AND user_id=$2`, tagID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+	if err = announceTagged(ctx, tx, touched); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// touchTaggedItems bumps updated_at on the tag's items so incremental syncs pick up the
+// change, and returns their IDs. Personal tags only ever label personal items; workspace
+// items are never touched.
+func touchTaggedItems(ctx context.Context, tx pgx.Tx, tagID string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE items SET updated_at=NOW()
+		WHERE id IN (SELECT item_id FROM item_tags WHERE tag_id=$1) AND workspace_id IS NULL
+		RETURNING id
+	`, tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// announceTagged queues item.tagged for items whose tags a rename, merge or delete changed.
+// It runs after the change so that the payloads carry the new tag set.
+func announceTagged(ctx context.Context, tx pgx.Tx, itemIDs []string) error {
+	for _, itemID := range itemIDs {
+		if err := enqueueWebhooks(ctx, tx, WebhookItemTagged, itemID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanTagWithCount(row pgx.Row) (Tag, error) {
+	var t Tag
+	if err := row.Scan(&t.ID, &t.Name, &t.NormalizedName, &t.Count); err != nil {
+		return Tag{}, err
+	}
+	return t, nil
+}
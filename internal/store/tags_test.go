@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTagsAreIsolatedPerUser(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	createTestItem(t, s, alice.ID, "", "https://example.com/a", "go", "web")
+	// Alice's display casing, as kept by the per-user split for tags created before it.
+	if _, err := s.DB.Exec(ctx, `UPDATE tags SET name='Go' WHERE user_id=$1 AND normalized_name='go'`, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	createTestItem(t, s, bob.ID, "", "https://example.com/b", "go")
+
+	aliceGo, err := s.GetTagByName(ctx, alice.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobGo, err := s.GetTagByName(ctx, bob.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliceGo.ID == bobGo.ID {
+		t.Fatalf("users share tag %s", aliceGo.ID)
+	}
+	if aliceGo.Name != "Go" || bobGo.Name != "go" {
+		t.Fatalf("display names = %q / %q, want Go / go", aliceGo.Name, bobGo.Name)
+	}
+	if aliceGo.Count != 1 || bobGo.Count != 1 {
+		t.Fatalf("counts = %d / %d, want 1 / 1", aliceGo.Count, bobGo.Count)
+	}
+
+	suggested, err := s.SuggestTags(ctx, bob.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tagNames(suggested), ","); got != "go" {
+		t.Fatalf("bob's suggestions = %q, want only his own tag", got)
+	}
+	if _, err := s.GetTag(ctx, bob.ID, aliceGo.ID); err == nil {
+		t.Fatalf("bob can read alice's tag")
+	}
+	assertNoTag(t, s, bob.ID, "web")
+
+	if _, err := s.RenameTag(ctx, bob.ID, bobGo.ID, "golang"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RenameTag(ctx, bob.ID, aliceGo.ID, "hijacked"); err == nil {
+		t.Fatalf("bob renamed alice's tag")
+	}
+	listed, err := s.ListTagsWithCount(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tagNames(listed), ","); got != "Go,web" {
+		t.Fatalf("alice's tags = %q, want Go,web", got)
+	}
+}
+
+func TestTagEditsSweepOnlyTheOwnersOrphans(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	aliceItem := createTestItem(t, s, alice.ID, "", "https://example.com/a", "go", "draft")
+	createTestItem(t, s, alice.ID, "", "https://example.com/a2", "go")
+	bobItem := createTestItem(t, s, bob.ID, "", "https://example.com/b", "draft")
+
+	tags, err := s.ReplaceItemTags(ctx, alice.ID, aliceItem, []string{"go", "rust"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tagNames(tags), ","); got != "go,rust" {
+		t.Fatalf("item tags = %q, want go,rust", got)
+	}
+	assertNoTag(t, s, alice.ID, "draft")
+	if goTag, err := s.GetTagByName(ctx, alice.ID, "go"); err != nil || goTag.Count != 2 {
+		t.Fatalf("alice's go tag should survive on her other item: %+v err=%v", goTag, err)
+	}
+	if bobDraft, err := s.GetTagByName(ctx, bob.ID, "draft"); err != nil || bobDraft.Count != 1 {
+		t.Fatalf("bob's same-named tag should be untouched: %+v err=%v", bobDraft, err)
+	}
+
+	if _, err := s.RemoveItemTags(ctx, bob.ID, bobItem, []string{"draft"}); err != nil {
+		t.Fatal(err)
+	}
+	assertNoTag(t, s, bob.ID, "draft")
+	if _, err := s.RemoveItemTags(ctx, bob.ID, aliceItem, []string{"go"}); err == nil {
+		t.Fatalf("bob edited alice's item")
+	}
+
+	if err := s.DeleteItem(ctx, alice.ID, aliceItem); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EmptyTrash(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertNoTag(t, s, alice.ID, "rust")
+	if _, err := s.GetTagByName(ctx, alice.ID, "go"); err != nil {
+		t.Fatalf("go still labels alice's other item: %v", err)
+	}
+}
+
+func TestUserTagsMigrationSplitsSharedTags(t *testing.T) {
+	s := newEmptyTestStore(t)
+	ctx := context.Background()
+	migrateTestStore(t, s, "", "006")
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	var sharedID string
+	if err := s.DB.QueryRow(ctx, `INSERT INTO tags (name, normalized_name) VALUES ('Go', 'go') RETURNING id`).Scan(&sharedID); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []string{alice.ID, bob.ID} {
+		if _, err := s.DB.Exec(ctx, `
+			WITH i AS (
+				INSERT INTO items (user_id, url, canonical_url, canonical_hash)
+				VALUES ($1, 'https://example.com/a', 'https://example.com/a', 'hash-a')
+				RETURNING id
+			)
+			INSERT INTO item_tags (item_id, tag_id) SELECT id, $2 FROM i
+		`, userID, sharedID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	migrateTestStore(t, s, "006", "")
+
+	aliceGo, err := s.GetTagByName(ctx, alice.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobGo, err := s.GetTagByName(ctx, bob.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliceGo.ID == bobGo.ID || aliceGo.ID == sharedID || bobGo.ID == sharedID {
+		t.Fatalf("expected two new private tags, got %s and %s from %s", aliceGo.ID, bobGo.ID, sharedID)
+	}
+	if aliceGo.Name != "Go" || bobGo.Name != "Go" || aliceGo.Count != 1 || bobGo.Count != 1 {
+		t.Fatalf("split tags lost their name or items: %+v %+v", aliceGo, bobGo)
+	}
+	var left int
+	if err := s.DB.QueryRow(ctx, `SELECT COUNT(*) FROM tags WHERE id=$1`, sharedID).Scan(&left); err != nil || left != 0 {
+		t.Fatalf("shared tag should be gone: count=%d err=%v", left, err)
+	}
+}
+
+func TestTagWideEditsAnnounceItemTagged(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	hook, err := s.CreateWebhook(ctx, alice.ID, "https://hooks.example.com/alice", "secret", []string{WebhookItemTagged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestItem(t, s, alice.ID, "", "https://example.com/a", "go")
+	createTestItem(t, s, alice.ID, "", "https://example.com/b", "go", "web")
+
+	// announced consumes the queued item.tagged deliveries and returns their tag sets, sorted.
+	announced := func() []string {
+		t.Helper()
+		rows, err := s.DB.Query(ctx, `
+			DELETE FROM webhook_deliveries WHERE webhook_id=$1 AND event=$2
+			RETURNING payload->'item'->>'tags'
+		`, hook.ID, WebhookItemTagged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		got := []string{}
+		for rows.Next() {
+			var tags string
+			if err := rows.Scan(&tags); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, tags)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	goTag, err := s.GetTagByName(ctx, alice.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RenameTag(ctx, alice.ID, goTag.ID, "golang"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(announced(), " "); got != `["golang"] ["golang", "web"]` {
+		t.Fatalf("after rename = %s", got)
+	}
+
+	webTag, err := s.GetTagByName(ctx, alice.ID, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MergeTag(ctx, alice.ID, webTag.ID, goTag.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(announced(), " "); got != `["golang"]` {
+		t.Fatalf("after merge = %s", got)
+	}
+
+	if err := s.DeleteTag(ctx, alice.ID, goTag.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(announced(), " "); got != "[] []" {
+		t.Fatalf("after delete = %s", got)
+	}
+}
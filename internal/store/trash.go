@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrItemExists is returned when restoring or editing an item would give the user two
+// live items with the same canonical URL.
+var ErrItemExists = errors.New("item_exists")
+
+// RestoreItem takes the item out of the trash.
+func (s *Store) RestoreItem(ctx context.Context, userID, itemID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE items SET deleted_at=NULL, updated_at=NOW()
+		WHERE id=$1 AND `+itemAccess("", "$2", true)+` AND deleted_at IS NOT NULL
+	`, itemID, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrItemExists
+		}
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// EmptyTrash permanently deletes the user's trashed items with their content and tag links.
+// Trashed workspace items are left to PurgeTrash.
+func (s *Store) EmptyTrash(ctx context.Context, userID string) (int64, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	ct, err := tx.Exec(ctx, `DELETE FROM items WHERE user_id=$1 AND workspace_id IS NULL AND deleted_at IS NOT NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err = deleteOrphanTags(ctx, tx, tagScope{UserID: userID}); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
+
+// PurgeTrash permanently deletes items that have been in the trash longer than retention.
+func (s *Store) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM items
+		WHERE deleted_at IS NOT NULL AND deleted_at < NOW() - ($1::bigint * INTERVAL '1 second')
+		RETURNING user_id, workspace_id
+	`, int64(retention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	scopes := map[tagScope]struct{}{}
+	for rows.Next() {
+		var userID string
+		var workspaceID *string
+		if err = rows.Scan(&userID, &workspaceID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		scopes[itemTagScope(userID, workspaceID)] = struct{}{}
+		removed++
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for scope := range scopes {
+		if err = deleteOrphanTags(ctx, tx, scope); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Webhook events. Payloads carry the item as it is after the change.
+const (
+	WebhookItemCreated     = "item.created"
+	WebhookItemFetched     = "item.fetched"
+	WebhookItemFetchFailed = "item.fetch_failed"
+	WebhookItemTagged      = "item.tagged"
+	WebhookItemDeleted     = "item.deleted"
+)
+
+// WebhookEvents lists every event in display order.
+var WebhookEvents = []string{WebhookItemCreated, WebhookItemFetched, WebhookItemFetchFailed, WebhookItemTagged, WebhookItemDeleted}
+
+func ValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint that receives the listed events for items the user saved,
+// including items saved to shared workspaces. The signing secret is never returned.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w Webhook) Wants(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one queued or attempted event. ResponseStatus is nil until an
+// endpoint has answered.
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	WebhookURL     string     `json:"webhook_url"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"response_status"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	// Loaded only for the worker.
+	Secret  string `json:"-"`
+	Payload []byte `json:"-"`
+}
+
+const webhookColumns = `wh.id, wh.url, wh.events, wh.active, wh.created_at`
+
+func scanWebhook(row pgx.Row) (Webhook, error) {
+	var w Webhook
+	if err := row.Scan(&w.ID, &w.URL, &w.Events, &w.Active, &w.CreatedAt); err != nil {
+		return Webhook{}, err
+	}
+	return w, nil
+}
+
+func (s *Store) ListWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks wh
+		WHERE wh.user_id=$1
+		ORDER BY wh.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *Store) GetWebhook(ctx context.Context, userID, webhookID string) (Webhook, error) {
+	return scanWebhook(s.DB.QueryRow(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks wh
+		WHERE wh.id::text=$1 AND wh.user_id=$2
+	`, webhookID, userID))
+}
+
+// CreateWebhook registers an endpoint. Events must be valid webhook events.
+func (s *Store) CreateWebhook(ctx context.Context, userID, url, secret string, events []string) (Webhook, error) {
+	return scanWebhook(s.DB.QueryRow(ctx, `
+		INSERT INTO webhooks AS wh (user_id, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+webhookColumns, userID, url, secret, events))
+}
+
+// UpdateWebhook changes the URL, events and/or active flag; nil values are kept.
+// Pausing a webhook does not cancel deliveries already queued.
+func (s *Store) UpdateWebhook(ctx context.Context, userID, webhookID string, url *string, events []string, active *bool) (Webhook, error) {
+	return scanWebhook(s.DB.QueryRow(ctx, `
+		UPDATE webhooks wh
+		SET url=COALESCE($3, wh.url), events=COALESCE($4, wh.events), active=COALESCE($5, wh.active)
+		WHERE wh.id::text=$1 AND wh.user_id=$2
+		RETURNING `+webhookColumns, webhookID, userID, url, events, active))
+}
+
+// DeleteWebhook removes the endpoint with its queue and log.
+func (s *Store) DeleteWebhook(ctx context.Context, userID, webhookID string) error {
+	ct, err := s.DB.Exec(ctx, `DELETE FROM webhooks WHERE id::text=$1 AND user_id=$2`, webhookID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the newest deliveries of one webhook, or of all of the
+// user's webhooks when webhookID is empty.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, userID, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT d.id, d.webhook_id, wh.url, d.event, d.status, d.attempts, d.response_status, d.last_error,
+			d.next_attempt_at, d.delivered_at, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhooks wh ON wh.id=d.webhook_id
+		WHERE wh.user_id=$1 AND ($2='' OR wh.id::text=$2)
+		ORDER BY d.created_at DESC
+		LIMIT $3
+	`, userID, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.WebhookURL, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RetryWebhookDelivery queues a finished delivery again with a fresh attempt budget.
+func (s *Store) RetryWebhookDelivery(ctx context.Context, userID, webhookID, deliveryID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		UPDATE webhook_deliveries d
+		SET status='pending', attempts=0, next_attempt_at=NOW()
+		FROM webhooks wh
+		WHERE wh.id=d.webhook_id AND d.id::text=$1 AND wh.id::text=$2 AND wh.user_id=$3 AND d.status <> 'pending'
+	`, deliveryID, webhookID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// enqueueWebhooks queues event for the active webhooks of the user who saved the item,
+// and for a workspace item only while that user is still a member of the workspace. It
+// runs in the caller's transaction, so only committed changes are announced and a queued
+// event is not lost if the process stops.
+func enqueueWebhooks(ctx context.Context, tx pgx.Tx, event, itemID string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT wh.id, $1, jsonb_build_object(
+			'event', $1::text,
+			'occurred_at', NOW(),
+			'item', jsonb_build_object(
+				'id', i.id,
+				'url', i.url,
+				'canonical_url', i.canonical_url,
+				'title', i.title,
+				'excerpt', i.excerpt,
+				'site_name', i.site_name,
+				'author', i.author,
+				'published_at', i.published_at,
+				'image_url', i.image_url,
+				'language', i.language,
+				'state', i.state,
+				'favorite', i.favorite,
+				'fetch_status', i.fetch_status,
+				'fetch_error', i.fetch_error,
+				'workspace_id', i.workspace_id,
+				'created_at', i.created_at,
+				'deleted_at', i.deleted_at,
+				'tags', COALESCE((
+					SELECT jsonb_agg(t.name ORDER BY t.name)
+					FROM item_tags it JOIN tags t ON t.id=it.tag_id
+					WHERE it.item_id=i.id
+				), '[]'::jsonb)))
+		FROM items i
+		JOIN webhooks wh ON wh.user_id=i.user_id AND wh.active AND $1=ANY(wh.events)
+		WHERE i.id=$2 AND (i.workspace_id IS NULL OR EXISTS (
+			SELECT 1 FROM workspace_members wm WHERE wm.workspace_id=i.workspace_id AND wm.user_id=wh.user_id
+		))
+	`, event, itemID)
+	return err
+}
+
+// ClaimWebhookDeliveries returns due deliveries with their endpoint and payload. The
+// claim pushes next_attempt_at out by lease so that an interrupted send is retried later.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	rows, err := s.DB.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at=NOW() + ($2::bigint * INTERVAL '1 second')
+		FROM webhooks wh
+		WHERE wh.id=d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status='pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, wh.url, wh.secret, d.event, d.attempts, d.payload::text, d.created_at
+	`, limit, int64(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.WebhookURL, &d.Secret, &d.Event, &d.Attempts, &payload, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *Store) MarkWebhookDelivered(ctx context.Context, deliveryID string, responseStatus int) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status='delivered', attempts=attempts + 1, response_status=$2, last_error='', delivered_at=NOW()
+		WHERE id=$1
+	`, deliveryID, responseStatus)
+	return err
+}
+
+// MarkWebhookFailed records a failed attempt. The delivery is retried at retryAt, or
+// given up on when retryAt is nil. responseStatus is 0 when no response was received.
+func (s *Store) MarkWebhookFailed(ctx context.Context, deliveryID string, responseStatus int, reason string, retryAt *time.Time) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status=CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			attempts=attempts + 1, response_status=NULLIF($2, 0), last_error=$3,
+			next_attempt_at=COALESCE($4, next_attempt_at)
+		WHERE id=$1
+	`, deliveryID, responseStatus, reason, retryAt)
+	return err
+}
+
+// PurgeWebhookDeliveries deletes finished deliveries older than maxAge.
+func (s *Store) PurgeWebhookDeliveries(ctx context.Context, maxAge time.Duration) (int64, error) {
+	ct, err := s.DB.Exec(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND created_at < NOW() - ($1::bigint * INTERVAL '1 second')
+	`, int64(maxAge.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWebhooksStopForRemovedWorkspaceMembers(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	ws, err := s.CreateWorkspace(ctx, alice.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkspaceMember(ctx, alice.ID, ws.ID, "bob@example.com", RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := s.CreateWebhook(ctx, bob.ID, "https://hooks.example.com/bob", "secret", []string{WebhookItemCreated, WebhookItemTagged, WebhookItemDeleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := createTestItem(t, s, bob.ID, ws.ID, "https://example.com/shared")
+	if err := s.RemoveWorkspaceMember(ctx, alice.ID, ws.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddItemTags(ctx, alice.ID, shared, []string{"go"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteItem(ctx, alice.ID, shared); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := s.ListWebhookDeliveries(ctx, bob.ID, hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != WebhookItemCreated {
+		t.Fatalf("removed member got deliveries %+v, want only the item.created from before removal", deliveries)
+	}
+}
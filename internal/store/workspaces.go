@@ -0,0 +1,357 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrWorkspaceForbidden = errors.New("workspace_forbidden")
+	ErrLastOwner          = errors.New("last_owner")
+	ErrMemberExists       = errors.New("member_exists")
+	ErrUnknownUser        = errors.New("unknown_user")
+)
+
+// Workspace is a shared library. Role is the requesting user's role in it.
+type Workspace struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidRole reports whether role is one of the workspace roles.
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// RoleAtLeast reports whether role grants at least the access of min.
+func RoleAtLeast(role, min string) bool {
+	rank := map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+	return rank[role] > 0 && rank[role] >= rank[min]
+}
+
+// itemAccess returns the SQL condition under which the user bound to userParam may read
+// (or, with write, change) an item: their own personal items, and items of workspaces
+// they belong to, where changes need the editor or owner role. alias prefixes the items
+// columns, e.g. "i.".
+func itemAccess(alias, userParam string, write bool) string {
+	roles := ""
+	if write {
+		roles = " AND wm.role IN ('owner', 'editor')"
+	}
+	return fmt.Sprintf(`(%[1]sworkspace_id IS NULL AND %[1]suser_id=%[2]s OR %[1]sworkspace_id IN (
+		SELECT wm.workspace_id FROM workspace_members wm WHERE wm.user_id=%[2]s%[3]s))`, alias, userParam, roles)
+}
+
+func (s *Store) CreateWorkspace(ctx context.Context, userID, name string) (Workspace, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return Workspace{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	ws := Workspace{Name: name, Role: RoleOwner, MemberCount: 1}
+	if err = tx.QueryRow(ctx, `
+		INSERT INTO workspaces (name, created_by) VALUES ($1, $2) RETURNING id, created_at
+	`, name, userID).Scan(&ws.ID, &ws.CreatedAt); err != nil {
+		return Workspace{}, err
+	}
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, 'owner')
+	`, ws.ID, userID); err != nil {
+		return Workspace{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Workspace{}, err
+	}
+	return ws, nil
+}
+
+const workspaceColumns = `
+	w.id, w.name, m.role,
+	(SELECT COUNT(*) FROM workspace_members c WHERE c.workspace_id=w.id),
+	w.created_at`
+
+func scanWorkspace(row pgx.Row) (Workspace, error) {
+	var ws Workspace
+	if err := row.Scan(&ws.ID, &ws.Name, &ws.Role, &ws.MemberCount, &ws.CreatedAt); err != nil {
+		return Workspace{}, err
+	}
+	return ws, nil
+}
+
+func (s *Store) ListWorkspaces(ctx context.Context, userID string) ([]Workspace, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+workspaceColumns+`
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id=w.id
+		WHERE m.user_id=$1
+		ORDER BY lower(w.name), w.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetWorkspace returns the workspace with the user's role, or pgx.ErrNoRows when the
+// user is not a member.
+func (s *Store) GetWorkspace(ctx context.Context, userID, workspaceID string) (Workspace, error) {
+	return scanWorkspace(s.DB.QueryRow(ctx, `
+		SELECT `+workspaceColumns+`
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id=w.id
+		WHERE w.id::text=$1 AND m.user_id=$2
+	`, workspaceID, userID))
+}
+
+// DeleteWorkspace removes the workspace and its items. Only owners may delete it.
+func (s *Store) DeleteWorkspace(ctx context.Context, userID, workspaceID string) error {
+	ct, err := s.DB.Exec(ctx, `
+		DELETE FROM workspaces w
+		USING workspace_members m
+		WHERE w.id::text=$1 AND m.workspace_id=w.id AND m.user_id=$2 AND m.role='owner'
+	`, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT u.id, COALESCE(u.email, ''), COALESCE(u.name, ''), m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id=m.user_id
+		WHERE m.workspace_id=$1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, lower(u.email)
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []WorkspaceMember{}
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Name, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddWorkspaceMember adds an existing user, found by email, with role. Only owners may
+// add members.
+func (s *Store) AddWorkspaceMember(ctx context.Context, actorID, workspaceID, email, role string) (WorkspaceMember, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return WorkspaceMember{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = requireWorkspaceOwner(ctx, tx, actorID, workspaceID); err != nil {
+		return WorkspaceMember{}, err
+	}
+	var m WorkspaceMember
+	err = tx.QueryRow(ctx, `
+		SELECT id, COALESCE(email, ''), COALESCE(name, '') FROM users WHERE lower(email)=lower($1)
+	`, email).Scan(&m.UserID, &m.Email, &m.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrUnknownUser
+		}
+		return WorkspaceMember{}, err
+	}
+	m.Role = role
+	err = tx.QueryRow(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3) RETURNING created_at
+	`, workspaceID, m.UserID, role).Scan(&m.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			err = ErrMemberExists
+		}
+		return WorkspaceMember{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return WorkspaceMember{}, err
+	}
+	return m, nil
+}
+
+// SetWorkspaceMemberRole changes a member's role. Only owners may change roles, and the
+// last owner cannot be demoted.
+func (s *Store) SetWorkspaceMemberRole(ctx context.Context, actorID, workspaceID, memberID, role string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = requireWorkspaceOwner(ctx, tx, actorID, workspaceID); err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		if err = keepAnOwner(ctx, tx, workspaceID, memberID); err != nil {
+			return err
+		}
+	}
+	ct, err := tx.Exec(ctx, `
+		UPDATE workspace_members SET role=$3 WHERE workspace_id::text=$1 AND user_id::text=$2
+	`, workspaceID, memberID, role)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveWorkspaceMember removes a member. Owners may remove anyone and any member may
+// leave; the last owner cannot leave. Items the member saved stay in the workspace.
+func (s *Store) RemoveWorkspaceMember(ctx context.Context, actorID, workspaceID, memberID string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if actorID != memberID {
+		if err = requireWorkspaceOwner(ctx, tx, actorID, workspaceID); err != nil {
+			return err
+		}
+	}
+	if err = keepAnOwner(ctx, tx, workspaceID, memberID); err != nil {
+		return err
+	}
+	ct, err := tx.Exec(ctx, `
+		DELETE FROM workspace_members WHERE workspace_id::text=$1 AND user_id::text=$2
+	`, workspaceID, memberID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// requireWorkspaceOwner locks the workspace's member list and checks that actorID owns it.
+func requireWorkspaceOwner(ctx context.Context, tx pgx.Tx, actorID, workspaceID string) error {
+	var role string
+	err := tx.QueryRow(ctx, `
+		SELECT role FROM workspace_members WHERE workspace_id::text=$1 AND user_id=$2 FOR UPDATE
+	`, workspaceID, actorID).Scan(&role)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		return ErrWorkspaceForbidden
+	}
+	return nil
+}
+
+// keepAnOwner fails with ErrLastOwner if memberID is the workspace's only owner.
+func keepAnOwner(ctx context.Context, tx pgx.Tx, workspaceID, memberID string) error {
+	var others int
+	var isOwner bool
+	err := tx.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE role='owner' AND user_id::text<>$2),
+			COALESCE(bool_or(role='owner' AND user_id::text=$2), false)
+		FROM workspace_members WHERE workspace_id::text=$1
+	`, workspaceID, memberID).Scan(&others, &isOwner)
+	if err != nil {
+		return err
+	}
+	if isOwner && others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// ListWorkspaceTags counts the workspace's tags on its live items.
+func (s *Store) ListWorkspaceTags(ctx context.Context, workspaceID string) ([]Tag, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT t.id, t.name, t.normalized_name, COUNT(it.item_id)
+		FROM tags t
+		JOIN item_tags it ON it.tag_id=t.id
+		JOIN items i ON i.id=it.item_id AND i.deleted_at IS NULL
+		WHERE t.workspace_id=$1
+		GROUP BY t.id
+		ORDER BY t.normalized_name
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.NormalizedName, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
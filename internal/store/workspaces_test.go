@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	cases := []struct {
+		role, min string
+		want      bool
+	}{
+		{RoleOwner, RoleEditor, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleViewer, RoleEditor, false},
+		{RoleViewer, RoleViewer, true},
+		{RoleEditor, RoleOwner, false},
+		{"", RoleViewer, false},
+		{"admin", RoleViewer, false},
+	}
+	for _, tc := range cases {
+		if got := RoleAtLeast(tc.role, tc.min); got != tc.want {
+			t.Fatalf("RoleAtLeast(%q, %q) = %v, want %v", tc.role, tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestWorkspaceItemWritesNeedAnEditor(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	carol := createTestUser(t, s, "carol")
+	dave := createTestUser(t, s, "dave")
+	ws, err := s.CreateWorkspace(ctx, alice.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkspaceMember(ctx, alice.ID, ws.ID, "bob@example.com", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkspaceMember(ctx, alice.ID, ws.ID, "carol@example.com", RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	shared := createTestItem(t, s, alice.ID, ws.ID, "https://example.com/shared")
+
+	title := "Renamed"
+	for _, u := range []User{bob, dave} {
+		if err := s.UpdateItem(ctx, u.ID, shared, ItemEdit{Title: &title}); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("%s updated the shared item: err=%v", u.Name, err)
+		}
+		if err := s.DeleteItem(ctx, u.ID, shared); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("%s deleted the shared item: err=%v", u.Name, err)
+		}
+	}
+	if _, err := s.GetItemDetail(ctx, bob.ID, shared); err != nil {
+		t.Fatalf("a viewer should still read the item: %v", err)
+	}
+
+	if err := s.UpdateItem(ctx, carol.ID, shared, ItemEdit{Title: &title}); err != nil {
+		t.Fatalf("an editor should update the item: %v", err)
+	}
+	if err := s.DeleteItem(ctx, carol.ID, shared); err != nil {
+		t.Fatalf("an editor should delete the item: %v", err)
+	}
+}
+
+func TestItemTagScopeKeepsWorkspaceTagsOutOfMemberNamespaces(t *testing.T) {
+	ws := "ws-1"
+	if got := itemTagScope("user-1", &ws); got != (tagScope{WorkspaceID: "ws-1"}) {
+		t.Fatalf("workspace item scope = %+v", got)
+	}
+	if got := itemTagScope("user-1", nil); got != (tagScope{UserID: "user-1"}) {
+		t.Fatalf("personal item scope = %+v", got)
+	}
+}
+
+func TestWorkspaceItemsUseTheWorkspaceTagNamespace(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	ws, err := s.CreateWorkspace(ctx, alice.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkspaceMember(ctx, alice.ID, ws.ID, "bob@example.com", RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	createTestItem(t, s, alice.ID, "", "https://example.com/own", "go")
+	shared := createTestItem(t, s, bob.ID, ws.ID, "https://example.com/shared", "go", "team")
+	if _, err := s.AddItemTags(ctx, alice.ID, shared, []string{"design"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoTag(t, s, bob.ID, "go")
+	assertNoTag(t, s, bob.ID, "team")
+	assertNoTag(t, s, alice.ID, "design")
+	if suggested, err := s.SuggestTags(ctx, bob.ID, ""); err != nil || len(suggested) != 0 {
+		t.Fatalf("bob's suggestions should be empty: %v err=%v", tagNames(suggested), err)
+	}
+	personal, err := s.ListTagsWithCount(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(personal) != 1 || personal[0].Name != "go" || personal[0].Count != 1 {
+		t.Fatalf("alice's personal tags = %+v, want go on one item", personal)
+	}
+	wsTags, err := s.ListWorkspaceTags(ctx, ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tagNames(wsTags), ","); got != "design,go,team" {
+		t.Fatalf("workspace tags = %q, want design,go,team", got)
+	}
+
+	if err := s.SetWorkspaceMemberRole(ctx, alice.ID, ws.ID, bob.ID, RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RemoveItemTags(ctx, bob.ID, shared, []string{"team"}); err == nil {
+		t.Fatalf("a viewer changed the shared item's tags")
+	}
+	if _, err := s.RemoveItemTags(ctx, alice.ID, shared, []string{"team"}); err != nil {
+		t.Fatal(err)
+	}
+	if wsTags, err = s.ListWorkspaceTags(ctx, ws.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tagNames(wsTags), ","); got != "design,go" {
+		t.Fatalf("unused workspace tag should be swept, got %q", got)
+	}
+}
+
+func TestWorkspaceTagsSurviveMigrationReruns(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	alice := createTestUser(t, s, "alice")
+	ws, err := s.CreateWorkspace(ctx, alice.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestItem(t, s, alice.ID, "", "https://example.com/own", "go")
+	createTestItem(t, s, alice.ID, ws.ID, "https://example.com/shared", "go", "team")
+	before, err := s.ListWorkspaceTags(ctx, ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceGo, err := s.GetTagByName(ctx, alice.ID, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The README applies migrations by re-running every file; 001 is the only one that is
+	// not re-runnable. 006 must not mistake workspace tags for legacy shared tags.
+	migrateTestStore(t, s, "002", "")
+	after, err := s.ListWorkspaceTags(ctx, ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 2 || len(after) != 2 || after[0].ID != before[0].ID || after[1].ID != before[1].ID {
+		t.Fatalf("re-running migrations changed workspace tags: %+v -> %+v", before, after)
+	}
+	if again, err := s.GetTagByName(ctx, alice.ID, "go"); err != nil || again.ID != aliceGo.ID || again.Count != 1 {
+		t.Fatalf("re-running migrations changed alice's tag: %+v err=%v", again, err)
+	}
+	assertNoTag(t, s, alice.ID, "team")
+}
@@ -0,0 +1,290 @@
+// Package subscription parses RSS, Atom and JSON feeds and OPML subscription lists, and
+// polls feeds with conditional GET.
+package subscription
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var ErrNotFeed = errors.New("not_a_feed")
+
+// maxEntries caps the entries read from one document.
+const maxEntries = 500
+
+// maxSeenIDs bounds the entry IDs remembered per subscription. It only needs to cover
+// what a feed still lists; items that scroll back in are deduplicated by URL anyway.
+const maxSeenIDs = 1000
+
+type Feed struct {
+	Title   string
+	SiteURL string
+	Entries []Entry
+}
+
+// Entry is one feed entry. ID is the entry's guid/id, falling back to its URL, which is
+// absolute and http(s).
+type Entry struct {
+	ID    string
+	URL   string
+	Title string
+}
+
+// Parse detects RSS (0.9x, 1.0 and 2.0), Atom and JSON Feed documents. Relative links
+// are resolved against base, the URL the feed was fetched from.
+func Parse(data []byte, base *url.URL) (Feed, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed, base)
+	}
+
+	dec := newXMLDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return Feed{}, ErrNotFeed
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss", "RDF":
+			var doc rssDoc
+			if err := dec.DecodeElement(&doc, &start); err != nil {
+				return Feed{}, fmt.Errorf("%w: %v", ErrNotFeed, err)
+			}
+			return doc.feed(base), nil
+		case "feed":
+			var doc atomDoc
+			if err := dec.DecodeElement(&doc, &start); err != nil {
+				return Feed{}, fmt.Errorf("%w: %v", ErrNotFeed, err)
+			}
+			return doc.feed(base), nil
+		default:
+			return Feed{}, ErrNotFeed
+		}
+	}
+}
+
+// NewEntries returns the entries whose IDs are not in seen, oldest first so that items
+// saved from one poll keep the feed's order.
+func NewEntries(feed Feed, seen []string) []Entry {
+	known := make(map[string]struct{}, len(seen))
+	for _, id := range seen {
+		known[id] = struct{}{}
+	}
+	var out []Entry
+	for i := len(feed.Entries) - 1; i >= 0; i-- {
+		if _, ok := known[feed.Entries[i].ID]; !ok {
+			out = append(out, feed.Entries[i])
+		}
+	}
+	return out
+}
+
+// SeenIDs returns the IDs to remember after a poll: the feed's current entries followed
+// by previously seen ones, so an entry that briefly drops out is not saved again.
+func SeenIDs(feed Feed, previous []string) []string {
+	ids := make([]string, 0, len(feed.Entries)+len(previous))
+	added := map[string]struct{}{}
+	for _, e := range feed.Entries {
+		ids = append(ids, e.ID)
+		added[e.ID] = struct{}{}
+	}
+	for _, id := range previous {
+		if _, ok := added[id]; ok {
+			continue
+		}
+		added[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxSeenIDs {
+		ids = ids[:maxSeenIDs]
+	}
+	return ids
+}
+
+// newXMLDecoder accepts the legacy encodings feeds still declare, such as ISO-8859-1.
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return dec
+}
+
+// xmlText keeps the element name so that RSS <link> can be told apart from atom:link.
+type xmlText struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []xmlText `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 puts items next to the channel rather than inside it.
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title string    `xml:"title"`
+	Links []xmlText `xml:"link"`
+	GUID  string    `xml:"guid"`
+	About string    `xml:"about,attr"`
+}
+
+func (d rssDoc) feed(base *url.URL) Feed {
+	f := Feed{Title: cleanText(d.Channel.Title), SiteURL: resolve(base, textLink(d.Channel.Links))}
+	items := append(d.Channel.Items, d.Items...)
+	for _, it := range items {
+		link := textLink(it.Links)
+		guid := strings.TrimSpace(it.GUID)
+		if guid == "" {
+			guid = strings.TrimSpace(it.About)
+		}
+		if link == "" && (strings.HasPrefix(guid, "http://") || strings.HasPrefix(guid, "https://")) {
+			// A guid that is a URL doubles as the permalink when <link> is missing.
+			link = guid
+		}
+		f.add(base, guid, link, it.Title)
+	}
+	return f
+}
+
+func textLink(links []xmlText) string {
+	for _, l := range links {
+		if v := strings.TrimSpace(l.Value); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type atomDoc struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	ID    string     `xml:"id"`
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+func (d atomDoc) feed(base *url.URL) Feed {
+	f := Feed{Title: cleanText(d.Title), SiteURL: resolve(base, alternateLink(d.Links))}
+	for _, e := range d.Entries {
+		f.add(base, e.ID, alternateLink(e.Links), e.Title)
+	}
+	return f
+}
+
+// alternateLink picks rel="alternate" (the default when rel is absent).
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+type jsonFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID          json.RawMessage `json:"id"`
+		URL         string          `json:"url"`
+		ExternalURL string          `json:"external_url"`
+		Title       string          `json:"title"`
+	} `json:"items"`
+}
+
+func parseJSONFeed(data []byte, base *url.URL) (Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Feed{}, fmt.Errorf("%w: %v", ErrNotFeed, err)
+	}
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return Feed{}, ErrNotFeed
+	}
+	f := Feed{Title: cleanText(doc.Title), SiteURL: resolve(base, doc.HomePageURL)}
+	for _, it := range doc.Items {
+		// The spec requires string IDs, but some publishers emit numbers.
+		var id string
+		if err := json.Unmarshal(it.ID, &id); err != nil {
+			id = string(it.ID)
+		}
+		link := it.URL
+		if link == "" {
+			link = it.ExternalURL
+		}
+		f.add(base, id, link, it.Title)
+	}
+	return f, nil
+}
+
+// add appends an entry with a usable web URL, skipping repeated IDs.
+func (f *Feed) add(base *url.URL, id, link, title string) {
+	link = resolve(base, link)
+	if link == "" || len(f.Entries) >= maxEntries {
+		return
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		id = link
+	}
+	for _, e := range f.Entries {
+		if e.ID == id {
+			return
+		}
+	}
+	f.Entries = append(f.Entries, Entry{ID: id, URL: link, Title: cleanText(title)})
+}
+
+// resolve makes ref absolute against base and returns "" unless it is an http(s) URL.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,193 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParseRSS(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title> Example  Blog </title>
+  <atom:link href="https://example.com/feed.xml" rel="self"/>
+  <link>https://example.com/</link>
+  <item><title>Second</title><link>/posts/2</link><guid isPermaLink="false">post-2</guid></item>
+  <item><title>First</title><guid>https://example.com/posts/1</guid></item>
+  <item><title>No link</title><guid isPermaLink="false">post-0</guid></item>
+</channel>
+</rss>`)
+	feed, err := Parse(data, mustURL(t, "https://example.com/feed.xml"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if feed.Title != "Example Blog" || feed.SiteURL != "https://example.com/" {
+		t.Fatalf("unexpected feed metadata: %+v", feed)
+	}
+	want := []Entry{
+		{ID: "post-2", URL: "https://example.com/posts/2", Title: "Second"},
+		{ID: "https://example.com/posts/1", URL: "https://example.com/posts/1", Title: "First"},
+	}
+	if len(feed.Entries) != len(want) {
+		t.Fatalf("entries = %+v, want %+v", feed.Entries, want)
+	}
+	for i := range want {
+		if feed.Entries[i] != want[i] {
+			t.Fatalf("entry %d = %+v, want %+v", i, feed.Entries[i], want[i])
+		}
+	}
+}
+
+func TestParseRSS1AndLatin1(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">` +
+		"<channel><title>Caf\xe9</title><link>https://cafe.example/</link></channel>" +
+		`<item rdf:about="https://cafe.example/a"><title>A</title><link>https://cafe.example/a</link></item>` +
+		`</rdf:RDF>`)
+	feed, err := Parse(data, mustURL(t, "https://cafe.example/index.rdf"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if feed.Title != "Café" {
+		t.Fatalf("title = %q", feed.Title)
+	}
+	if len(feed.Entries) != 1 || feed.Entries[0].ID != "https://cafe.example/a" {
+		t.Fatalf("entries = %+v", feed.Entries)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	data := []byte(`<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Blog</title>
+  <link rel="self" href="/atom.xml"/>
+  <link href="https://atom.example/"/>
+  <entry>
+    <id>tag:atom.example,2024:1</id>
+    <title type="html">Hello &amp; welcome</title>
+    <link rel="replies" href="/1/comments"/>
+    <link rel="alternate" href="/1"/>
+  </entry>
+</feed>`)
+	feed, err := Parse(data, mustURL(t, "https://atom.example/atom.xml"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if feed.SiteURL != "https://atom.example/" {
+		t.Fatalf("site = %q", feed.SiteURL)
+	}
+	want := Entry{ID: "tag:atom.example,2024:1", URL: "https://atom.example/1", Title: "Hello & welcome"}
+	if len(feed.Entries) != 1 || feed.Entries[0] != want {
+		t.Fatalf("entries = %+v, want %+v", feed.Entries, want)
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	data := []byte(`{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON Blog",
+  "home_page_url": "https://json.example/",
+  "items": [
+    {"id": 42, "url": "https://json.example/42"},
+    {"id": "ext", "external_url": "https://elsewhere.example/x", "title": "Linked"},
+    {"id": "none"}
+  ]
+}`)
+	feed, err := Parse(data, mustURL(t, "https://json.example/feed.json"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].ID != "42" || feed.Entries[1].URL != "https://elsewhere.example/x" {
+		t.Fatalf("entries = %+v", feed.Entries)
+	}
+}
+
+func TestParseRejectsNonFeeds(t *testing.T) {
+	for _, data := range []string{
+		"<!DOCTYPE html><html><body>hi</body></html>",
+		`{"version": "1"}`,
+		"",
+	} {
+		if _, err := Parse([]byte(data), nil); !errors.Is(err, ErrNotFeed) {
+			t.Fatalf("Parse(%q) err = %v, want ErrNotFeed", data, err)
+		}
+	}
+}
+
+func TestNewEntriesAndSeenIDs(t *testing.T) {
+	feed := Feed{Entries: []Entry{{ID: "c"}, {ID: "b"}, {ID: "a"}}}
+	got := NewEntries(feed, []string{"a", "old"})
+	if len(got) != 2 || got[0].ID != "b" || got[1].ID != "c" {
+		t.Fatalf("NewEntries = %+v, want b then c", got)
+	}
+	seen := SeenIDs(feed, []string{"a", "old"})
+	want := []string{"c", "b", "a", "old"}
+	if len(seen) != len(want) {
+		t.Fatalf("SeenIDs = %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("SeenIDs = %v, want %v", seen, want)
+		}
+	}
+}
+
+func TestPollSendsValidators(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		_, _ = w.Write([]byte(`<rss><channel><item><link>/p</link></item></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	p := &Poller{Client: srv.Client(), MaxBytes: 1 << 20}
+	res, err := p.Poll(context.Background(), srv.URL+"/feed", "", "")
+	if err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if res.NotModified || res.ETag != `"v1"` || len(res.Feed.Entries) != 1 || res.Feed.Entries[0].URL != srv.URL+"/p" {
+		t.Fatalf("first poll = %+v", res)
+	}
+	res, err = p.Poll(context.Background(), srv.URL+"/feed", res.ETag, res.LastModified)
+	if err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if !res.NotModified || res.ETag != `"v1"` || res.LastModified == "" {
+		t.Fatalf("second poll = %+v", res)
+	}
+}
+
+func TestNextPollBacksOff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Hour},
+		{1, 2 * time.Hour},
+		{3, 8 * time.Hour},
+		{10, 24 * time.Hour},
+	}
+	for _, tc := range cases {
+		if got := NextPoll(now, time.Hour, tc.failures).Sub(now); got != tc.want {
+			t.Fatalf("NextPoll(%d failures) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"altpocket/internal/tag"
+)
+
+var ErrMalformedOPML = errors.New("invalid_file")
+
+// Outline is one subscription in an OPML file. Tags come from the enclosing folders and
+// the category attribute, normalized.
+type Outline struct {
+	Title   string
+	FeedURL string
+	SiteURL string
+	Tags    []string
+}
+
+type opmlDoc struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title string `xml:"title"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// ParseOPML returns the feeds listed in an OPML document, flattening folders. Outlines
+// without an xmlUrl are treated as folders.
+func ParseOPML(data []byte) ([]Outline, error) {
+	var doc opmlDoc
+	if err := newXMLDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedOPML, err)
+	}
+	var out []Outline
+	var walk func(items []opmlOutline, folders []string)
+	walk = func(items []opmlOutline, folders []string) {
+		for _, o := range items {
+			title := cleanText(o.Title)
+			if title == "" {
+				title = cleanText(o.Text)
+			}
+			feedURL := strings.TrimSpace(o.XMLURL)
+			if feedURL == "" {
+				walk(o.Outlines, append(folders[:len(folders):len(folders)], title))
+				continue
+			}
+			out = append(out, Outline{
+				Title:   title,
+				FeedURL: feedURL,
+				SiteURL: strings.TrimSpace(o.HTMLURL),
+				Tags:    tag.NormalizeAll(append(append([]string{}, folders...), categoryTags(o.Category)...)),
+			})
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+	return out, nil
+}
+
+// categoryTags reads the comma-separated category attribute, using the last segment of
+// slash-delimited paths such as "/Tech/Go".
+func categoryTags(category string) []string {
+	var tags []string
+	for _, c := range strings.Split(category, ",") {
+		parts := strings.Split(strings.TrimSpace(c), "/")
+		if last := strings.TrimSpace(parts[len(parts)-1]); last != "" {
+			tags = append(tags, last)
+		}
+	}
+	return tags
+}
+
+// WriteOPML writes an OPML 2.0 document listing the outlines flat, with tags in the
+// category attribute.
+func WriteOPML(w io.Writer, title string, outlines []Outline) error {
+	doc := opmlDoc{Version: "2.0"}
+	doc.Head.Title = title
+	for _, o := range outlines {
+		text := o.Title
+		if text == "" {
+			text = o.FeedURL
+		}
+		doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{
+			Text:     text,
+			Title:    o.Title,
+			Type:     "rss",
+			XMLURL:   o.FeedURL,
+			HTMLURL:  o.SiteURL,
+			Category: strings.Join(o.Tags, ","),
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,61 @@
+package subscription
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseOPMLFlattensFolders(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<opml version="2.0">
+  <head><title>Reader export</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog" category="/Lang/Go,News"/>
+    </outline>
+    <outline text="Loose" xmlUrl=" https://loose.example/rss "/>
+  </body>
+</opml>`)
+	got, err := ParseOPML(data)
+	if err != nil {
+		t.Fatalf("ParseOPML: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("outlines = %+v", got)
+	}
+	if got[0].Title != "Go Blog" || got[0].SiteURL != "https://go.dev/blog" || strings.Join(got[0].Tags, ",") != "tech,go,news" {
+		t.Fatalf("first outline = %+v", got[0])
+	}
+	if got[1].FeedURL != "https://loose.example/rss" || len(got[1].Tags) != 0 {
+		t.Fatalf("second outline = %+v", got[1])
+	}
+}
+
+func TestParseOPMLRejectsOtherXML(t *testing.T) {
+	if _, err := ParseOPML([]byte(`<rss><channel/></rss>`)); !errors.Is(err, ErrMalformedOPML) {
+		t.Fatalf("err = %v, want ErrMalformedOPML", err)
+	}
+}
+
+func TestWriteOPMLRoundTrips(t *testing.T) {
+	in := []Outline{
+		{Title: "A & B", FeedURL: "https://a.example/feed?x=1&y=2", SiteURL: "https://a.example/", Tags: []string{"go", "web"}},
+		{FeedURL: "https://b.example/rss"},
+	}
+	var buf bytes.Buffer
+	if err := WriteOPML(&buf, "altpocket subscriptions", in); err != nil {
+		t.Fatalf("WriteOPML: %v", err)
+	}
+	out, err := ParseOPML(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseOPML: %v\n%s", err, buf.String())
+	}
+	if len(out) != 2 || out[0].Title != "A & B" || out[0].FeedURL != in[0].FeedURL || strings.Join(out[0].Tags, ",") != "go,web" {
+		t.Fatalf("round trip = %+v\n%s", out, buf.String())
+	}
+	if out[1].Title != "https://b.example/rss" {
+		t.Fatalf("untitled outline = %+v", out[1])
+	}
+}
@@ -0,0 +1,98 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"altpocket/internal/fetcher"
+)
+
+// maxBackoff caps the delay between polls of a failing feed.
+const maxBackoff = 24 * time.Hour
+
+// Poller fetches feeds. Client is normally the fetcher's client, so feeds are subject to
+// the same redirect limit and destination checks as article fetches.
+type Poller struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+// PollResult carries the validators to send next time. Feed is empty when the server
+// answered 304 Not Modified.
+type PollResult struct {
+	NotModified  bool
+	ETag         string
+	LastModified string
+	Feed         Feed
+}
+
+// Poll fetches feedURL, sending the validators from the previous poll. Failures use the
+// fetcher's errors (ErrBadStatus, ErrTooLarge) or ErrNotFeed.
+func (p *Poller) Poll(ctx context.Context, feedURL, etag, lastModified string) (PollResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return PollResult{}, err
+	}
+	req.Header.Set("User-Agent", "altpocket/1.0")
+	req.Header.Set("Accept", "application/atom+xml, application/rss+xml, application/feed+json, application/xml;q=0.9, application/json;q=0.8, text/xml;q=0.8, */*;q=0.5")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return PollResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		res := PollResult{NotModified: true, ETag: etag, LastModified: lastModified}
+		if v := resp.Header.Get("ETag"); v != "" {
+			res.ETag = v
+		}
+		if v := resp.Header.Get("Last-Modified"); v != "" {
+			res.LastModified = v
+		}
+		return res, nil
+	}
+	if resp.StatusCode >= 400 {
+		return PollResult{}, fmt.Errorf("%w: %d", fetcher.ErrBadStatus, resp.StatusCode)
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, p.MaxBytes+1))
+	if err != nil {
+		return PollResult{}, err
+	}
+	// A truncated document would not parse, so an oversized feed is an error.
+	if int64(len(buf)) > p.MaxBytes {
+		return PollResult{}, fetcher.ErrTooLarge
+	}
+	feed, err := Parse(buf, resp.Request.URL)
+	if err != nil {
+		return PollResult{}, err
+	}
+	return PollResult{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Feed:         feed,
+	}, nil
+}
+
+// NextPoll schedules the next poll, doubling interval for each consecutive failure up
+// to maxBackoff.
+func NextPoll(now time.Time, interval time.Duration, failures int) time.Time {
+	delay := interval
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return now.Add(delay)
+}
@@ -13,3 +13,22 @@ func Normalize(name string) string {
 	}
 	return strings.ToLower(norm.NFKC.String(trimmed))
 }
+
+// NormalizeAll normalizes names, dropping empty ones and duplicates while keeping the
+// first occurrence's position.
+func NormalizeAll(names []string) []string {
+	out := make([]string, 0, len(names))
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		n := Normalize(name)
+		if n == "" {
+			continue
+		}
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		out = append(out, n)
+	}
+	return out
+}
@@ -20,3 +20,16 @@ func TestNormalize(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeAll(t *testing.T) {
+	got := NormalizeAll([]string{" Go ", "", "go", "ＡＢＣ", "  ", "Web"})
+	want := []string{"go", "abc", "web"}
+	if len(got) != len(want) {
+		t.Fatalf("NormalizeAll = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("NormalizeAll = %v, want %v", got, want)
+		}
+	}
+}
@@ -24,28 +24,33 @@ func New(templateDir string) (*Renderer, error) {
 		},
 	}
 
-	layout := filepath.Join(templateDir, "layout.html")
-	items := filepath.Join(templateDir, "items.html")
-	detail := filepath.Join(templateDir, "item_detail.html")
-	quickAdd := filepath.Join(templateDir, "quick_add.html")
-
-	itemsTpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles(layout, items)
-	if err != nil {
-		return nil, err
-	}
-	detailTpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles(layout, detail)
-	if err != nil {
-		return nil, err
+	pages := map[string]string{
+		"items":           "items.html",
+		"detail":          "item_detail.html",
+		"quick_add":       "quick_add.html",
+		"settings":        "settings.html",
+		"oauth_authorize": "oauth_authorize.html",
+		"import":          "import.html",
+		"tags":            "tags.html",
+		"trash":           "trash.html",
+		"collections":     "collections.html",
+		"collection":      "collection.html",
+		"workspaces":      "workspaces.html",
+		"shared":          "shared.html",
+		"subscriptions":   "subscriptions.html",
+		"webhooks":        "webhooks.html",
 	}
-	quickAddTpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles(layout, quickAdd)
-	if err != nil {
-		return nil, err
+
+	layout := filepath.Join(templateDir, "layout.html")
+	templates := make(map[string]*template.Template, len(pages))
+	for name, file := range pages {
+		tpl, err := template.New("layout.html").Funcs(funcMap).ParseFiles(layout, filepath.Join(templateDir, file))
+		if err != nil {
+			return nil, err
+		}
+		templates[name] = tpl
 	}
-	return &Renderer{templates: map[string]*template.Template{
-		"items":     itemsTpl,
-		"detail":    detailTpl,
-		"quick_add": quickAddTpl,
-	}}, nil
+	return &Renderer{templates: templates}, nil
 }
 
 func resolveAssetVersion() string {
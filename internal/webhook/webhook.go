@@ -0,0 +1,104 @@
+// Package webhook signs and sends webhook deliveries.
+//
+// Each request carries the raw JSON payload and these headers:
+//
+//	X-Altpocket-Event      the event name, e.g. item.created
+//	X-Altpocket-Delivery   the delivery ID, stable across retries
+//	X-Altpocket-Timestamp  Unix seconds when the attempt was made
+//	X-Altpocket-Signature  sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret>
+//
+// Receivers should recompute the signature, compare it in constant time and reject
+// stale timestamps.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxAttempts is the number of sends before a delivery is marked failed. With Backoff
+// the last attempt is made about eight and a half hours after the first.
+const MaxAttempts = 10
+
+const (
+	baseBackoff = time.Minute
+	maxBackoff  = 6 * time.Hour
+)
+
+var ErrBadStatus = errors.New("bad_status")
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts deliveries. Redirects are not followed, so a 3xx counts as a failure.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender sends through transport, normally the fetcher's, so webhook targets are
+// subject to the same network restrictions as fetched pages.
+func NewSender(transport http.RoundTripper) *Sender {
+	return &Sender{Client: &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send posts payload and returns the response status, or 0 when there was no response.
+// Any status outside 2xx is reported as ErrBadStatus.
+func (s *Sender) Send(ctx context.Context, url, secret, event, deliveryID string, payload []byte, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "altpocket-webhooks/1.0")
+	req.Header.Set("X-Altpocket-Event", event)
+	req.Header.Set("X-Altpocket-Delivery", deliveryID)
+	req.Header.Set("X-Altpocket-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Altpocket-Signature", Sign(secret, ts, payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w: %d", ErrBadStatus, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay before the next attempt after attempts failed sends:
+// one minute, doubling each time, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
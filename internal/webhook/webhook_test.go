@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignMatchesKnownVector(t *testing.T) {
+	// printf '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	got := Sign("secret", 1700000000, []byte(`{"a":1}`))
+	want := "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	if got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+	if Sign("secret", 1700000001, []byte(`{"a":1}`)) == got {
+		t.Fatal("signature should cover the timestamp")
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var gotHeaders http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewSender(srv.Client().Transport)
+	payload := []byte(`{"event":"item.created"}`)
+	status, err := s.Send(context.Background(), srv.URL, "secret", "item.created", "d-1", payload, now)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v", status, err)
+	}
+	if string(gotBody) != string(payload) {
+		t.Fatalf("body = %s", gotBody)
+	}
+	if gotHeaders.Get("X-Altpocket-Event") != "item.created" || gotHeaders.Get("X-Altpocket-Delivery") != "d-1" || gotHeaders.Get("X-Altpocket-Timestamp") != "1700000000" {
+		t.Fatalf("headers = %v", gotHeaders)
+	}
+	if gotHeaders.Get("X-Altpocket-Signature") != Sign("secret", now.Unix(), payload) {
+		t.Fatalf("signature = %q", gotHeaders.Get("X-Altpocket-Signature"))
+	}
+}
+
+func TestSendTreatsRedirectsAsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	status, err := NewSender(srv.Client().Transport).Send(context.Background(), srv.URL, "s", "item.deleted", "d", []byte(`{}`), time.Now())
+	if status != http.StatusFound || !errors.Is(err, ErrBadStatus) {
+		t.Fatalf("Send = %d, %v; want 302 and ErrBadStatus", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+	for _, tc := range cases {
+		if got := Backoff(tc.attempts); got != tc.want {
+			t.Fatalf("Backoff(%d) = %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
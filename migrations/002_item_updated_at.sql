@@ -0,0 +1,10 @@
+-- The column is added nullable and only unset rows are backfilled, so re-running this
+-- file does not rewind updated_at on items changed since.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+UPDATE items SET updated_at = COALESCE(fetched_at, created_at) WHERE updated_at IS NULL;
+
+ALTER TABLE items ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE items ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS items_user_updated_idx ON items (user_id, updated_at DESC);
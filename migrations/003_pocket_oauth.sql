@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS oauth_apps (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  owner_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  consumer_key TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS oauth_apps_owner_idx ON oauth_apps (owner_user_id);
+
+CREATE TABLE IF NOT EXISTS oauth_request_tokens (
+  code TEXT PRIMARY KEY,
+  app_id UUID NOT NULL REFERENCES oauth_apps(id) ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  state TEXT NOT NULL DEFAULT '',
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending',
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT oauth_request_tokens_status_check CHECK (status IN ('pending', 'approved', 'denied', 'used'))
+);
+
+CREATE TABLE IF NOT EXISTS oauth_access_tokens (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  app_id UUID NOT NULL REFERENCES oauth_apps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS oauth_access_tokens_user_idx ON oauth_access_tokens (user_id);
@@ -0,0 +1,10 @@
+ALTER TABLE items ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'unread';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS favorite BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS favorited_at TIMESTAMPTZ;
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_state_check;
+ALTER TABLE items ADD CONSTRAINT items_state_check CHECK (state IN ('unread', 'archived'));
+
+CREATE INDEX IF NOT EXISTS items_user_state_created_idx ON items (user_id, state, created_at DESC);
+CREATE INDEX IF NOT EXISTS items_user_favorite_idx ON items (user_id, created_at DESC) WHERE favorite;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  filename TEXT NOT NULL DEFAULT '',
+  format TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'queued',
+  total INT NOT NULL DEFAULT 0,
+  processed INT NOT NULL DEFAULT 0,
+  created_count INT NOT NULL DEFAULT 0,
+  duplicate_count INT NOT NULL DEFAULT 0,
+  invalid_count INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  started_at TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  CONSTRAINT import_jobs_format_check CHECK (format IN ('pocket_html', 'pocket_csv')),
+  CONSTRAINT import_jobs_status_check CHECK (status IN ('queued', 'running', 'done'))
+);
+
+CREATE INDEX IF NOT EXISTS import_jobs_user_created_idx ON import_jobs (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS import_jobs_active_idx ON import_jobs (created_at) WHERE status IN ('queued', 'running');
+
+-- Parsed rows waiting to be turned into items; the worker deletes them as it goes.
+CREATE TABLE IF NOT EXISTS import_entries (
+  job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+  position INT NOT NULL,
+  url TEXT NOT NULL,
+  canonical_url TEXT NOT NULL,
+  canonical_hash TEXT NOT NULL,
+  title TEXT NOT NULL DEFAULT '',
+  time_added TIMESTAMPTZ,
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  archived BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (job_id, position)
+);
+
+-- Bulk imports queue their fetches behind interactive saves.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS fetch_priority SMALLINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS items_fetch_queue_idx ON items (fetch_priority, user_id, created_at)
+  WHERE fetch_status = 'pending' OR refetch_requested;
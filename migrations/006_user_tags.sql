@@ -0,0 +1,31 @@
+-- Tags become per-user: every user that used a shared tag gets a private copy and their
+-- item_tags rows are repointed to it.
+BEGIN;
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_normalized_name_key;
+
+CREATE TEMP TABLE tag_split ON COMMIT DROP AS
+SELECT DISTINCT t.id AS old_id, i.user_id, t.name, t.normalized_name, t.created_at
+FROM tags t
+JOIN item_tags it ON it.tag_id = t.id
+JOIN items i ON i.id = it.item_id
+WHERE t.user_id IS NULL;
+
+ALTER TABLE tag_split ADD COLUMN new_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+INSERT INTO tags (id, user_id, name, normalized_name, created_at)
+SELECT new_id, user_id, name, normalized_name, created_at FROM tag_split;
+
+UPDATE item_tags it
+SET tag_id = s.new_id
+FROM tag_split s, items i
+WHERE it.tag_id = s.old_id AND i.id = it.item_id AND i.user_id = s.user_id;
+
+DELETE FROM tags WHERE user_id IS NULL;
+
+ALTER TABLE tags ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_user_normalized_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_user_normalized_name_key UNIQUE (user_id, normalized_name);
+
+COMMIT;
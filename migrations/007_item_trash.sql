@@ -0,0 +1,7 @@
+ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Trashed items no longer block saving the same URL again.
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_user_id_canonical_hash_key;
+CREATE UNIQUE INDEX IF NOT EXISTS items_user_canonical_live_idx ON items (user_id, canonical_hash) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS items_user_deleted_idx ON items (user_id, deleted_at DESC) WHERE deleted_at IS NOT NULL;
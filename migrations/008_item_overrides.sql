@@ -0,0 +1,4 @@
+-- Titles and excerpts edited by the user are kept when the item is refetched.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS title_edited BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS excerpt_edited BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS note TEXT NOT NULL DEFAULT '';
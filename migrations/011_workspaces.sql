@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name TEXT NOT NULL,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+  workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (workspace_id, user_id),
+  CONSTRAINT workspace_members_role_check CHECK (role IN ('owner', 'editor', 'viewer'))
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_user_idx ON workspace_members (user_id);
+
+-- items.user_id stays the member who saved the item; workspace_id, when set, makes the
+-- item part of that workspace's shared library instead of the user's own.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+
+-- Personal items dedupe per user, workspace items per workspace.
+DROP INDEX IF EXISTS items_user_canonical_live_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS items_user_canonical_live_idx ON items (user_id, canonical_hash) WHERE deleted_at IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS items_workspace_canonical_live_idx ON items (workspace_id, canonical_hash) WHERE deleted_at IS NULL AND workspace_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS items_workspace_created_idx ON items (workspace_id, created_at DESC) WHERE workspace_id IS NOT NULL;
+
+-- Workspace items are tagged in the workspace's own namespace, never the saving member's:
+-- a tag belongs to exactly one user or one workspace.
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+ALTER TABLE tags ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_namespace_check;
+ALTER TABLE tags ADD CONSTRAINT tags_namespace_check CHECK ((user_id IS NULL) <> (workspace_id IS NULL));
+CREATE UNIQUE INDEX IF NOT EXISTS tags_workspace_normalized_name_idx ON tags (workspace_id, normalized_name) WHERE workspace_id IS NOT NULL;
+
+-- An item may only carry tags from its own namespace. Besides guarding the store, this makes
+-- a re-run of 006, which treats every tag without a user_id as a legacy shared tag, fail and
+-- roll back instead of moving workspace tags into members' namespaces.
+CREATE OR REPLACE FUNCTION item_tags_check_namespace() RETURNS trigger AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM items i JOIN tags t ON t.id = NEW.tag_id
+    WHERE i.id = NEW.item_id
+      AND CASE WHEN i.workspace_id IS NULL THEN t.user_id = i.user_id ELSE t.workspace_id = i.workspace_id END
+  ) THEN
+    RAISE EXCEPTION 'tag % is not in the namespace of item %', NEW.tag_id, NEW.item_id;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS item_tags_namespace_check ON item_tags;
+CREATE TRIGGER item_tags_namespace_check BEFORE INSERT OR UPDATE ON item_tags
+FOR EACH ROW EXECUTE FUNCTION item_tags_check_namespace();
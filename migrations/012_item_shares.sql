@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS item_shares (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  item_id UUID NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+  created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  -- Only the SHA-256 of the link token is stored; the link is shown once when created.
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ,
+  view_count INT NOT NULL DEFAULT 0,
+  last_viewed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS item_shares_item_idx ON item_shares (item_id, created_at DESC);
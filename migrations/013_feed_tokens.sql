@@ -0,0 +1,8 @@
+-- One secret feed token per user. Feed readers cannot send cookies, so the token in the
+-- feed URL is the credential; only its SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS feed_tokens (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,23 @@
+-- Feeds a user follows. The worker polls due feeds with the stored validators and saves
+-- entries it has not seen before as items with the subscription's tags.
+CREATE TABLE IF NOT EXISTS subscriptions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  feed_url TEXT NOT NULL,
+  title TEXT NOT NULL DEFAULT '',
+  site_url TEXT NOT NULL DEFAULT '',
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  etag TEXT NOT NULL DEFAULT '',
+  last_modified TEXT NOT NULL DEFAULT '',
+  seen_entry_ids TEXT[] NOT NULL DEFAULT '{}',
+  item_count INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  error_count INT NOT NULL DEFAULT 0,
+  last_polled_at TIMESTAMPTZ,
+  last_success_at TIMESTAMPTZ,
+  next_poll_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, feed_url)
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_due_idx ON subscriptions (next_poll_at);
@@ -0,0 +1,14 @@
+-- Personal access tokens for scripts and CLIs. Only the SHA-256 of the token is stored;
+-- the token itself is shown once when it is created.
+CREATE TABLE IF NOT EXISTS personal_tokens (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  scope TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT personal_tokens_scope_check CHECK (scope IN ('read', 'write', 'admin'))
+);
+
+CREATE INDEX IF NOT EXISTS personal_tokens_user_idx ON personal_tokens (user_id);
@@ -0,0 +1,24 @@
+-- One row per signed-in browser extension install. The refresh token rotates on every use
+-- and only its SHA-256 is stored; previous_refresh_hash detects a replayed token.
+CREATE TABLE IF NOT EXISTS extension_devices (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  refresh_hash TEXT NOT NULL UNIQUE,
+  previous_refresh_hash TEXT,
+  refresh_expires_at TIMESTAMPTZ NOT NULL,
+  access_jti TEXT NOT NULL DEFAULT '',
+  access_expires_at TIMESTAMPTZ,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS extension_devices_user_idx ON extension_devices (user_id);
+CREATE INDEX IF NOT EXISTS extension_devices_previous_idx ON extension_devices (previous_refresh_hash);
+
+-- Access tokens revoked before they expire, keyed by jti. Rows are purged once the token
+-- would have expired anyway.
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+  jti TEXT PRIMARY KEY,
+  expires_at TIMESTAMPTZ NOT NULL
+);
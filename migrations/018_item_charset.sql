@@ -0,0 +1,2 @@
+-- Encoding the page was decoded from on the last successful fetch, e.g. shift_jis.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS charset TEXT NOT NULL DEFAULT '';
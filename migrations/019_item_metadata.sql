@@ -0,0 +1,7 @@
+-- Metadata the page declares about itself (OpenGraph, Twitter card, JSON-LD, <meta>),
+-- refreshed on every successful fetch.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS site_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Sanitized article HTML for the reader view; content_full stays the plain text used
+-- for search and highlights.
+ALTER TABLE item_contents ADD COLUMN IF NOT EXISTS content_html TEXT NOT NULL DEFAULT '';
@@ -106,8 +106,9 @@ function Invoke-PsqlScalar([string]$Sql) {
 function New-JwtHs256([string]$Secret, [string]$UserId) {
   $now = [DateTimeOffset]::UtcNow.ToUnixTimeSeconds()
   $exp = $now + 86400
+  $jti = [guid]::NewGuid().ToString("N")
   $headerJson = '{"alg":"HS256","typ":"JWT"}'
-  $payloadJson = ('{"sub":"{0}","iat":{1},"exp":{2}}' -f $UserId, $now, $exp)
+  $payloadJson = ('{{"jti":"{0}","sub":"{1}","iat":{2},"exp":{3}}}' -f $jti, $UserId, $now, $exp)
 
   $headerB64 = ConvertTo-Base64Url ([Text.Encoding]::UTF8.GetBytes($headerJson))
   $payloadB64 = ConvertTo-Base64Url ([Text.Encoding]::UTF8.GetBytes($payloadJson))
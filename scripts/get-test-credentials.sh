@@ -48,12 +48,13 @@ derive_compose_service_env() {
 jwt_hs256() {
   local secret="$1"
   local user_id="$2"
-  local now exp header payload unsigned signature
+  local now exp jti header payload unsigned signature
 
   now=$(date +%s)
   exp=$((now + 86400))
+  jti="$(openssl rand -hex 16)"
   header='{"alg":"HS256","typ":"JWT"}'
-  payload=$(printf '{"sub":"%s","iat":%d,"exp":%d}' "$user_id" "$now" "$exp")
+  payload=$(printf '{"jti":"%s","sub":"%s","iat":%d,"exp":%d}' "$jti" "$user_id" "$now" "$exp")
 
   unsigned="$(printf '%s' "$header" | b64url).$(printf '%s' "$payload" | b64url)"
   signature=$(printf '%s' "$unsigned" | openssl dgst -sha256 -hmac "$secret" -binary | b64url)
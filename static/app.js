@@ -28,20 +28,224 @@
     });
   });
 
+  document.querySelectorAll('button.item-state').forEach((btn) => {
+    btn.addEventListener('click', async () => {
+      const id = btn.dataset.itemId;
+      const action = btn.dataset.action;
+      if (!id || !action) return;
+      btn.disabled = true;
+      const res = await fetch(`/v1/items/${id}/${action}`, { method: 'POST', headers });
+      if (res.ok) {
+        if (btn.dataset.redirect) {
+          window.location = btn.dataset.redirect;
+        } else {
+          window.location.reload();
+        }
+      } else if (res.status === 409) {
+        btn.disabled = false;
+        alert('This page has been saved again since it was trashed.');
+      } else {
+        btn.disabled = false;
+        alert(`Failed to ${action}`);
+      }
+    });
+  });
+
+  document.querySelectorAll('form[data-confirm]').forEach((form) => {
+    form.addEventListener('submit', (event) => {
+      if (!confirm(form.dataset.confirm)) event.preventDefault();
+    });
+  });
+
   document.querySelectorAll('button.delete').forEach((btn) => {
     btn.addEventListener('click', async () => {
       const id = btn.dataset.itemId;
       if (!id) return;
-      if (!confirm('Delete this item?')) return;
       const res = await fetch(`/v1/items/${id}`, { method: 'DELETE', headers });
       if (res.ok) {
-        window.location = '/ui/items';
+        const params = new URLSearchParams({ trashed: id });
+        if (btn.dataset.workspace) params.set('workspace', btn.dataset.workspace);
+        window.location = `/ui/items?${params}`;
       } else {
         alert('Failed to delete');
       }
     });
   });
 
+  const highlightRoot = document.querySelector('[data-highlight-root]');
+  const highlightCreateBtn = document.querySelector('[data-highlight-create]');
+  if (highlightRoot && highlightCreateBtn) {
+    const selectedRange = () => {
+      const sel = window.getSelection();
+      if (!sel || sel.rangeCount === 0 || sel.isCollapsed) return null;
+      const range = sel.getRangeAt(0);
+      if (!highlightRoot.contains(range.commonAncestorContainer)) return null;
+      return range;
+    };
+
+    document.addEventListener('selectionchange', () => {
+      highlightCreateBtn.disabled = !selectedRange();
+    });
+
+    highlightCreateBtn.addEventListener('click', async () => {
+      const range = selectedRange();
+      if (!range) return;
+      const quote = range.toString();
+      if (!quote.trim()) return;
+      // The server counts offsets in code points, not UTF-16 units.
+      const before = document.createRange();
+      before.selectNodeContents(highlightRoot);
+      before.setEnd(range.startContainer, range.startOffset);
+      const start = Array.from(before.toString()).length;
+      const note = prompt('Note (optional)', '');
+      if (note === null) return;
+
+      highlightCreateBtn.disabled = true;
+      const res = await fetch(`/v1/items/${highlightRoot.dataset.itemId}/highlights`, {
+        method: 'POST',
+        headers: {
+          ...headers,
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ quote, start, note }),
+      });
+      if (res.ok) {
+        window.location.reload();
+      } else if (res.status === 409) {
+        alert('That text overlaps an existing highlight.');
+      } else {
+        alert('Failed to save highlight');
+      }
+    });
+  }
+
+  document.querySelectorAll('button.highlight-delete').forEach((btn) => {
+    btn.addEventListener('click', async () => {
+      const { itemId, highlightId } = btn.dataset;
+      if (!itemId || !highlightId) return;
+      if (!confirm('Delete this highlight?')) return;
+      const res = await fetch(`/v1/items/${itemId}/highlights/${highlightId}`, { method: 'DELETE', headers });
+      if (res.ok) {
+        window.location.reload();
+      } else {
+        alert('Failed to delete highlight');
+      }
+    });
+  });
+
+  const sharePanel = document.querySelector('[data-share-item]');
+  if (sharePanel) {
+    const createBtn = sharePanel.querySelector('[data-share-create]');
+    const output = sharePanel.querySelector('[data-share-url]');
+    createBtn.addEventListener('click', async () => {
+      const days = sharePanel.querySelector('[data-share-expiry]').value;
+      const body = {};
+      if (days) body.expires_at = new Date(Date.now() + Number(days) * 86400000).toISOString();
+      createBtn.disabled = true;
+      const res = await fetch(`/v1/items/${sharePanel.dataset.shareItem}/share`, {
+        method: 'POST',
+        headers: { ...headers, 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+      });
+      createBtn.disabled = false;
+      if (!res.ok) {
+        alert('Failed to create link');
+        return;
+      }
+      const data = await res.json();
+      output.value = data.url;
+      output.hidden = false;
+      output.select();
+    });
+  }
+
+  document.querySelectorAll('button.share-revoke').forEach((btn) => {
+    btn.addEventListener('click', async () => {
+      const { itemId, shareId } = btn.dataset;
+      if (!itemId || !shareId) return;
+      if (!confirm('Revoke this link? It stops working immediately.')) return;
+      const res = await fetch(`/v1/items/${itemId}/share/${shareId}`, { method: 'DELETE', headers });
+      if (res.ok) {
+        window.location.reload();
+      } else {
+        alert('Failed to revoke link');
+      }
+    });
+  });
+
+  const collectionList = document.querySelector('.collection-list[data-collection-id]');
+  if (collectionList) {
+    const collectionID = collectionList.dataset.collectionId;
+    const entries = () => Array.from(collectionList.querySelectorAll('.collection-entry'));
+    let dragged = null;
+
+    const saveOrder = async () => {
+      const res = await fetch(`/v1/collections/${collectionID}/order`, {
+        method: 'PUT',
+        headers: {
+          ...headers,
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ item_ids: entries().map((li) => li.dataset.itemId) }),
+      });
+      if (!res.ok) {
+        alert('Failed to save the new order');
+        window.location.reload();
+      }
+    };
+
+    collectionList.addEventListener('dragstart', (event) => {
+      dragged = event.target.closest('.collection-entry');
+      if (!dragged) return;
+      dragged.classList.add('dragging');
+      event.dataTransfer.effectAllowed = 'move';
+    });
+
+    collectionList.addEventListener('dragover', (event) => {
+      if (!dragged) return;
+      event.preventDefault();
+      const over = event.target.closest('.collection-entry');
+      if (!over || over === dragged) return;
+      const rect = over.getBoundingClientRect();
+      const after = event.clientY > rect.top + rect.height / 2;
+      collectionList.insertBefore(dragged, after ? over.nextSibling : over);
+    });
+
+    collectionList.addEventListener('dragend', () => {
+      if (!dragged) return;
+      dragged.classList.remove('dragging');
+      dragged = null;
+      saveOrder();
+    });
+
+    collectionList.querySelectorAll('[data-collection-move]').forEach((btn) => {
+      btn.addEventListener('click', () => {
+        const li = btn.closest('.collection-entry');
+        if (btn.dataset.collectionMove === 'up' && li.previousElementSibling) {
+          collectionList.insertBefore(li, li.previousElementSibling);
+        } else if (btn.dataset.collectionMove === 'down' && li.nextElementSibling) {
+          collectionList.insertBefore(li.nextElementSibling, li);
+        } else {
+          return;
+        }
+        btn.focus();
+        saveOrder();
+      });
+    });
+
+    collectionList.querySelectorAll('[data-collection-remove]').forEach((btn) => {
+      btn.addEventListener('click', async () => {
+        const li = btn.closest('.collection-entry');
+        const res = await fetch(`/v1/collections/${collectionID}/items/${li.dataset.itemId}`, { method: 'DELETE', headers });
+        if (res.ok) {
+          li.remove();
+        } else {
+          alert('Failed to remove the item');
+        }
+      });
+    });
+  }
+
   const form = document.querySelector('.search-form');
   if (form) {
     form.querySelectorAll('select').forEach((sel) => {
@@ -432,4 +636,109 @@
       quickAddSuggestionsEl.innerHTML = '';
     });
   }
+
+  const batchToolbar = document.querySelector('[data-batch-toolbar]');
+  if (batchToolbar) {
+    const boxes = Array.from(document.querySelectorAll('input.item-select'));
+    const allBox = batchToolbar.querySelector('[data-batch-all]');
+    const countEl = batchToolbar.querySelector('[data-batch-count]');
+    const buttons = Array.from(batchToolbar.querySelectorAll('[data-batch-action]'));
+    const selectedIDs = () => boxes.filter((box) => box.checked).map((box) => box.value);
+
+    const refreshSelection = () => {
+      const count = selectedIDs().length;
+      countEl.textContent = `${count} selected`;
+      buttons.forEach((btn) => {
+        btn.disabled = count === 0;
+      });
+      allBox.checked = count > 0 && count === boxes.length;
+      allBox.indeterminate = count > 0 && count < boxes.length;
+      boxes.forEach((box) => {
+        box.closest('.item-card')?.classList.toggle('selected', box.checked);
+      });
+    };
+
+    boxes.forEach((box) => box.addEventListener('change', refreshSelection));
+    allBox.addEventListener('change', () => {
+      boxes.forEach((box) => {
+        box.checked = allBox.checked;
+      });
+      refreshSelection();
+    });
+
+    buttons.forEach((btn) => {
+      btn.addEventListener('click', async () => {
+        const ids = selectedIDs();
+        if (ids.length === 0) return;
+        const action = btn.dataset.batchAction;
+        const op = { action, item_ids: ids };
+        if (action === 'add_tags' || action === 'remove_tags') {
+          const label = action === 'add_tags' ? 'Tags to add' : 'Tags to remove';
+          const input = prompt(`${label} (comma separated)`);
+          if (!input) return;
+          op.tags = input.split(',').map(normalizeTagName).filter(Boolean);
+          if (op.tags.length === 0) return;
+        }
+        if (action === 'delete' && !confirm(`Move ${ids.length} item(s) to trash?`)) return;
+
+        buttons.forEach((b) => {
+          b.disabled = true;
+        });
+        try {
+          const res = await fetch('/v1/items/batch', {
+            method: 'POST',
+            headers: {
+              ...headers,
+              'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ actions: [op] }),
+          });
+          if (!res.ok) {
+            alert('Failed to update the selected items');
+            refreshSelection();
+            return;
+          }
+          const data = await res.json();
+          const failed = (data.results || []).filter((r) => !r.ok).length;
+          if (failed > 0) {
+            alert(`${failed} item(s) could not be updated`);
+          }
+          window.location.reload();
+        } catch {
+          alert('Failed to update the selected items');
+          refreshSelection();
+        }
+      });
+    });
+
+    refreshSelection();
+  }
+
+  const importList = document.querySelector('[data-import-poll]');
+  if (importList) {
+    const pollImports = async () => {
+      let data;
+      try {
+        const res = await fetch('/v1/imports');
+        if (!res.ok) return;
+        data = await res.json();
+      } catch {
+        return;
+      }
+      let active = false;
+      (data.imports || []).forEach((job) => {
+        const row = importList.querySelector(`[data-import-id="${job.id}"]`);
+        if (!row) return;
+        row.querySelector('.import-status').textContent = job.status;
+        const progress = row.querySelector('.import-progress');
+        progress.max = job.total;
+        progress.value = job.processed;
+        row.querySelector('.import-counts').textContent =
+          `${job.processed} / ${job.total} processed · ${job.created_count} added · ${job.duplicate_count} already saved · ${job.invalid_count} invalid`;
+        if (job.status === 'queued' || job.status === 'running') active = true;
+      });
+      if (active) window.setTimeout(pollImports, 5000);
+    };
+    window.setTimeout(pollImports, 5000);
+  }
 })();